@@ -2,20 +2,119 @@ package main
 
 import (
 	"avana/internal/config"
-	"avana/internal/events"
-	"avana/internal/users"
+	"avana/internal/migrate"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
 )
 
+const usage = `usage: migrations [-config file] [-dir migrations] <command>
+
+commands:
+  up              apply every pending migration
+  down N          roll back the last N migrations (default 1)
+  redo            roll back the last migration and apply it again
+  status          list migrations and when they were applied
+  create <name>   write an empty up/down pair for a new migration
+  check           compare the models with the migrated schema
+`
 
-func init() {
-	config.ConnectToDb()
-}
 
 func main() {
-	config.DB.AutoMigrate(
-		&users.User{},
-		&events.Event{},
-		&events.Attendee{},
-		&events.Ticket{},
-	)
-}
\ No newline at end of file
+	configPath := flag.String("config", "", "path to a yaml or toml config file")
+	dir := flag.String("dir", "migrations", "directory holding the migration files")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// create only touches the filesystem
+	if args[0] == "create" {
+		if len(args) != 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		up, down, err := migrate.Create(*dir, args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("created %s\ncreated %s\n", up, down)
+		return
+	}
+
+	// only the database has to be configured to migrate it
+	config.ConnectToDb(config.MustLoadDatabase(*configPath))
+
+	migrations, err := migrate.Load(*dir)
+	if err != nil {
+		log.Fatalf("loading migrations: %v", err)
+	}
+	migrator := migrate.New(config.DB, migrations)
+
+	switch args[0] {
+	case "up":
+		ran, err := migrator.Up()
+		for _, migration := range ran {
+			fmt.Printf("applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(ran) == 0 {
+			fmt.Println("nothing to apply")
+		}
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				log.Fatalf("down needs a positive number, got %q", args[1])
+			}
+		}
+		ran, err := migrator.Down(n)
+		for _, migration := range ran {
+			fmt.Printf("reverted %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "redo":
+		migration, err := migrator.Redo()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("redid %04d_%s\n", migration.Version, migration.Name)
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-40s %s\n", status.Version, status.Name, applied)
+		}
+	case "check":
+		problems, err := migrate.Check(config.DB, migrate.Models()...)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		if len(problems) > 0 {
+			os.Exit(1)
+		}
+		fmt.Println("models and schema agree")
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
@@ -3,42 +3,88 @@ package main
 import (
 	"avana/internal/config"
 	"avana/internal/events"
-	"avana/internal/middlewares"
+	"avana/internal/mailer"
+	"avana/internal/payments"
+	"avana/internal/server"
+	"avana/internal/tokens"
 	"avana/internal/users"
-
-	"github.com/gin-gonic/gin"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
-func init() {
-	config.ConnectToDb()
+func main() {
+	configPath := flag.String("config", "", "path to a yaml or toml config file")
+	flag.Parse()
+
+	cfg := config.MustLoad(*configPath)
+	config.ConnectToDb(cfg.Database)
+	provider, err := newPaymentProvider(cfg.Payments)
+	if err != nil {
+		log.Fatalf("loading payment provider: %v", err)
+	}
+
+	// use smtp when it is configured, otherwise drop mails in a folder
+	var mail mailer.Mailer
+	if cfg.Mail.SMTPHost != "" {
+		mail = mailer.NewSMTPMailer(cfg.Mail.SMTPHost, cfg.Mail.SMTPPort,
+			cfg.Mail.SMTPUsername, cfg.Mail.SMTPPassword, cfg.Mail.From)
+	} else {
+		mail = mailer.NewFileMailer(cfg.Mail.OutboxDir, cfg.Mail.From)
+	}
+
+	keys, err := tokens.NewKeySet(cfg.Auth)
+	if err != nil {
+		log.Fatalf("loading signing keys: %v", err)
+	}
+
+	signer, err := tokens.LoadTicketSigner(cfg.Auth.TicketKeyFile)
+	if err != nil {
+		log.Fatalf("loading ticket signing key: %v", err)
+	}
+
+	deps := server.Dependencies{
+		Config: cfg,
+		Keys: keys,
+		Users: users.NewGormStore(config.DB),
+		Events: events.NewGormStore(config.DB),
+		Payments: provider,
+		Mailer: mail,
+		TicketSigner: signer,
+	}
+
+	// return abandoned checkout holds and unpaid orders to the inventory
+	events.ReservationTTL = time.Duration(cfg.Server.ReservationTTLMinutes) * time.Minute
+	events.OrderTTL = time.Duration(cfg.Server.OrderTTLMinutes) * time.Minute
+	events.OrderCurrency = cfg.Payments.Currency
+	stop := make(chan struct{})
+	swept := events.StartReservationSweeper(deps.Events, time.Minute, stop)
+
+	// on ctrl-c or a termination signal, let the sweeper finish its run
+	// before exiting
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		close(stop)
+		<-swept
+		os.Exit(0)
+	}()
+
+	r := server.NewRouter(deps)
+	log.Fatal(r.Run(cfg.Server.Addr()))
 }
 
-func main() {
-	r := gin.Default()
-
-	usergroup := r.Group("/user")
-	usergroup.POST("/create",users.CreateUser)
-	usergroup.POST("/login",users.Login)
-	usergroup.POST("/otp",users.GetOtp)
-	usergroup.POST("/otp/verify",users.VerifyOtp)
-	usergroup.POST("/password/change",users.ChangePassword)
-
-
-	eventgroup := r.Group("/event")
-	eventgroup.POST("/create",middlewares.RequireAuth,events.CreateEvent)
-	eventgroup.GET("/:id",events.GetEventByID)
-	eventgroup.GET("/all",events.GetAllEvent)
-	eventgroup.GET("/:id/ticket/all",events.GetAllTickets)
-	eventgroup.GET("/ticket/:id",events.GetTicketById)
-	eventgroup.PATCH("/update/:id",middlewares.RequireAuth,events.UpdateEvent)
-	eventgroup.POST("/:id/ticket/create",middlewares.RequireAuth,events.AddTicket)
-	eventgroup.PATCH("/ticket/:id",middlewares.RequireAuth,events.UpdateTicket)
-	eventgroup.DELETE("/ticket/:id",middlewares.RequireAuth,events.DeleteTicket)
-	eventgroup.DELETE("/:id",middlewares.RequireAuth, events.DeleteEvent)
-	eventgroup.POST("/ticket/:id/buy", middlewares.RequireAuth,events.BuyTicket)
-	eventgroup.GET("/:id/attendees",middlewares.RequireAuth,events.GetTotalAttendees)
-	eventgroup.GET("/:id/reviews", events.GetAllReviews)
-	
-
-	r.Run(":8000")
-}
\ No newline at end of file
+// newPaymentProvider builds the configured gateway. The fake provider is
+// never one of them, it confirms every payment.
+func newPaymentProvider(cfg config.PaymentsConfig) (payments.PaymentProvider, error) {
+	switch cfg.Provider {
+	case config.PaymentProviderStripe:
+		return payments.NewStripeProvider(cfg.SecretKey, cfg.WebhookSecret, cfg.IdempotencyPrefix), nil
+	}
+	return nil, fmt.Errorf("unknown payment provider %q", cfg.Provider)
+}
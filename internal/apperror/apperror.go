@@ -0,0 +1,101 @@
+package apperror
+
+import (
+	"avana/internal/utils"
+	"net/http"
+)
+
+// Kind says what went wrong in terms a client can act on, it decides the status code.
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindInvalid
+	KindUnauthenticated
+	KindForbidden
+	KindNotFound
+	KindConflict
+	KindGone
+	KindPaymentRequired
+	KindTooManyRequests
+	KindUpstream
+)
+
+var kindStatus = map[Kind]int{
+	KindInternal: http.StatusInternalServerError,
+	KindInvalid: http.StatusBadRequest,
+	KindUnauthenticated: http.StatusUnauthorized,
+	KindForbidden: http.StatusForbidden,
+	KindNotFound: http.StatusNotFound,
+	KindConflict: http.StatusConflict,
+	KindGone: http.StatusGone,
+	KindPaymentRequired: http.StatusPaymentRequired,
+	KindTooManyRequests: http.StatusTooManyRequests,
+	KindUpstream: http.StatusBadGateway,
+}
+
+// Error is an error a client can see. Code is stable and meant for
+// programs, Message is meant for people and may change.
+type Error struct {
+	Kind Kind
+	Code string
+	Message string
+	Fields []FieldError
+	cause error
+}
+
+// FieldError points at one invalid field of a request.
+type FieldError struct {
+	Field string		`json:"field"`
+	Code string			`json:"code"`
+	Message string		`json:"message"`
+}
+
+func New(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+func (e *Error) Status() int {
+	return kindStatus[e.Kind]
+}
+
+// WithFields returns a copy of e that carries field details. errors.Is
+// still matches the copy against e.
+func (e *Error) WithFields(fields ...FieldError) *Error {
+	copy := *e
+	copy.Fields = fields
+	copy.cause = e
+	return &copy
+}
+
+var (
+	ErrInternal = New(KindInternal, "internal_error", "Something went wrong, try again later")
+	ErrInvalidRequest = New(KindInvalid, "invalid_request", utils.ReadRequestError)
+	ErrValidation = New(KindInvalid, "validation_failed", "The request has invalid fields")
+	ErrUnauthenticated = New(KindUnauthenticated, "unauthenticated", utils.ValidateTokenError)
+	ErrForbidden = New(KindForbidden, "forbidden", utils.IncorrecPermission)
+	ErrNotFound = New(KindNotFound, "not_found", "The record does not exist")
+	ErrConflict = New(KindConflict, "already_exists", utils.ExistingDataError)
+)
+
+// Validation reports every invalid field of a request at once.
+func Validation(fields ...FieldError) *Error {
+	return ErrValidation.WithFields(fields...)
+}
+
+// InvalidParam reports a path parameter that could not be read.
+func InvalidParam(name string) *Error {
+	return ErrInvalidRequest.WithFields(FieldError{
+		Field: name,
+		Code: "invalid",
+		Message: "must be a positive number",
+	})
+}
@@ -0,0 +1,37 @@
+package apperror
+
+import (
+	"avana/internal/validation"
+	"encoding/json"
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// FromBinding turns an error from gin's binding into a validation error,
+// listing every failed field at once when the validator reports them.
+func FromBinding(err error) *Error {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make([]FieldError, 0, len(validationErrs))
+		for _, fieldErr := range validationErrs {
+			fields = append(fields, FieldError{
+				Field: validation.Field(fieldErr),
+				Code: fieldErr.Tag(),
+				Message: validation.Message(fieldErr),
+			})
+		}
+		return Validation(fields...)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return Validation(FieldError{
+			Field: typeErr.Field,
+			Code: "type",
+			Message: "must be a " + typeErr.Type.String(),
+		})
+	}
+
+	return ErrInvalidRequest
+}
@@ -0,0 +1,50 @@
+package apperror
+
+import (
+	"errors"
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Write renders err as the error envelope:
+//
+//	{"error": {"code": "...", "message": "...", "fields": [...]}}
+//
+// Errors that are not an *Error are logged and reported as internal errors
+// so their details never reach the client.
+func Write(c *gin.Context, err error) {
+	WriteWith(c, err, nil)
+}
+
+// WriteWith renders err like Write, adding extra top-level keys that
+// describe the state the request ran into.
+func WriteWith(c *gin.Context, err error, extra gin.H) {
+	var appErr *Error
+	if !errors.As(err, &appErr) {
+		log.Printf("%s %s: %v", c.Request.Method, c.FullPath(), err)
+		appErr = ErrInternal
+	}
+
+	body := gin.H{
+		"code": appErr.Code,
+		"message": appErr.Message,
+	}
+	if len(appErr.Fields) > 0 {
+		body["fields"] = appErr.Fields
+	}
+
+	response := gin.H{
+		"error": body,
+	}
+	for key, value := range extra {
+		response[key] = value
+	}
+	c.JSON(appErr.Status(), response)
+}
+
+// Abort renders err and stops the remaining handlers, for middlewares.
+func Abort(c *gin.Context, err error) {
+	Write(c, err)
+	c.Abort()
+}
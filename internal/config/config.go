@@ -0,0 +1,328 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileEnv names the environment variable that points at an optional config file.
+const ConfigFileEnv = "AVANA_CONFIG"
+
+type Config struct {
+	Server ServerConfig			`yaml:"server" toml:"server"`
+	Database DatabaseConfig		`yaml:"database" toml:"database"`
+	Auth AuthConfig				`yaml:"auth" toml:"auth"`
+	Mail MailConfig				`yaml:"mail" toml:"mail"`
+	Payments PaymentsConfig		`yaml:"payments" toml:"payments"`
+}
+
+type ServerConfig struct {
+	Port int									`yaml:"port" toml:"port"`
+	ReservationTTLMinutes int					`yaml:"reservationTtlMinutes" toml:"reservationTtlMinutes"`
+	OrderTTLMinutes int							`yaml:"orderTtlMinutes" toml:"orderTtlMinutes"`
+}
+
+type DatabaseConfig struct {
+	DSN string			`yaml:"dsn" toml:"dsn"`
+}
+
+type AuthConfig struct {
+	JWTSecret string			`yaml:"jwtSecret" toml:"jwtSecret"`
+	AccessTokenTTLMinutes int	`yaml:"accessTokenTtlMinutes" toml:"accessTokenTtlMinutes"`
+	RefreshTokenTTLDays int		`yaml:"refreshTokenTtlDays" toml:"refreshTokenTtlDays"`
+	KeysDir string				`yaml:"keysDir" toml:"keysDir"`
+	ActiveKeyID string			`yaml:"activeKeyId" toml:"activeKeyId"`
+	AcceptHMACTokens bool		`yaml:"acceptHmacTokens" toml:"acceptHmacTokens"`
+	// TicketKeyFile is the Ed25519 pem key that signs ticket codes
+	TicketKeyFile string		`yaml:"ticketKeyFile" toml:"ticketKeyFile"`
+}
+
+type MailConfig struct {
+	SMTPHost string			`yaml:"smtpHost" toml:"smtpHost"`
+	SMTPPort int			`yaml:"smtpPort" toml:"smtpPort"`
+	SMTPUsername string		`yaml:"smtpUsername" toml:"smtpUsername"`
+	SMTPPassword string		`yaml:"smtpPassword" toml:"smtpPassword"`
+	From string				`yaml:"from" toml:"from"`
+	OutboxDir string		`yaml:"outboxDir" toml:"outboxDir"`
+}
+
+// PaymentProviderStripe is the payment provider charged through in production.
+const PaymentProviderStripe = "stripe"
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+type PaymentsConfig struct {
+	// Provider names the gateway to charge through, the fake provider is
+	// only for tests and cannot be configured
+	Provider string			`yaml:"provider" toml:"provider"`
+	SecretKey string		`yaml:"secretKey" toml:"secretKey"`
+	WebhookSecret string	`yaml:"webhookSecret" toml:"webhookSecret"`
+	// IdempotencyPrefix sets this deployment's idempotency keys apart from
+	// others charging through the same account, such as staging and a
+	// developer's machine
+	IdempotencyPrefix string	`yaml:"idempotencyPrefix" toml:"idempotencyPrefix"`
+	// Currency is the ISO 4217 code orders are charged in
+	Currency string			`yaml:"currency" toml:"currency"`
+}
+
+// Load builds the configuration from defaults, then the file at path (if
+// any, .yaml/.yml or .toml), then environment variables, and validates it.
+// An empty path falls back to the AVANA_CONFIG environment variable.
+func Load(path string) (*Config, error) {
+	cfg, err := read(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// MustLoad is Load for the command entrypoints, it exits when the configuration is invalid.
+func MustLoad(path string) *Config {
+	cfg, err := Load(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		os.Exit(1)
+	}
+	return cfg
+}
+
+// LoadDatabase reads the configuration the same way as Load but only
+// validates the database settings, for commands that never serve requests.
+func LoadDatabase(path string) (DatabaseConfig, error) {
+	cfg, err := read(path)
+	if err != nil {
+		return DatabaseConfig{}, err
+	}
+
+	if err := cfg.Database.Validate(); err != nil {
+		return DatabaseConfig{}, err
+	}
+
+	return cfg.Database, nil
+}
+
+// MustLoadDatabase is LoadDatabase for the command entrypoints, it exits
+// when the database settings are invalid.
+func MustLoadDatabase(path string) DatabaseConfig {
+	cfg, err := LoadDatabase(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		os.Exit(1)
+	}
+	return cfg
+}
+
+// read layers the file and the environment over the defaults.
+func read(path string) (Config, error) {
+	cfg := Defaults()
+
+	if path == "" {
+		path = os.Getenv(ConfigFileEnv)
+	}
+	if path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if err := loadEnv(&cfg); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+func (c DatabaseConfig) Validate() error {
+	if c.DSN == "" {
+		return errors.New("database dsn is required (AVANA_DATABASE_DSN)")
+	}
+	return nil
+}
+
+func (c *Config) Validate() error {
+	var problems []string
+
+	if err := c.Database.Validate(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	// the shared secret is only needed while hmac tokens are still in use
+	if (c.Auth.KeysDir == "" || c.Auth.AcceptHMACTokens) && len(c.Auth.JWTSecret) < 32 {
+		problems = append(problems, "jwt secret must be at least 32 characters (AVANA_JWT_SECRET)")
+	}
+	if c.Auth.KeysDir != "" && c.Auth.ActiveKeyID == "" {
+		problems = append(problems, "active key id is required with a keys directory (AVANA_JWT_ACTIVE_KEY_ID)")
+	}
+	if c.Auth.TicketKeyFile == "" {
+		problems = append(problems, "ticket signing key file is required (AVANA_TICKET_KEY_FILE)")
+	}
+	switch c.Payments.Provider {
+	case "":
+		problems = append(problems, "payment provider is required (AVANA_PAYMENT_PROVIDER)")
+	case PaymentProviderStripe:
+		if c.Payments.SecretKey == "" {
+			problems = append(problems, "payment secret key is required (AVANA_PAYMENT_SECRET_KEY)")
+		}
+		if c.Payments.IdempotencyPrefix == "" {
+			problems = append(problems, "payment idempotency prefix is required, such as the environment's name (AVANA_PAYMENT_IDEMPOTENCY_PREFIX)")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unknown payment provider %q (AVANA_PAYMENT_PROVIDER)", c.Payments.Provider))
+	}
+	if c.Payments.WebhookSecret == "" {
+		problems = append(problems, "payment webhook secret is required (AVANA_PAYMENT_WEBHOOK_SECRET)")
+	}
+	if !currencyCode.MatchString(c.Payments.Currency) {
+		problems = append(problems, "payment currency must be a three letter code such as NGN (AVANA_PAYMENT_CURRENCY)")
+	}
+	if c.Auth.AccessTokenTTLMinutes <= 0 {
+		problems = append(problems, "access token ttl must be positive (AVANA_ACCESS_TOKEN_TTL_MINUTES)")
+	}
+	if c.Auth.RefreshTokenTTLDays <= 0 {
+		problems = append(problems, "refresh token ttl must be positive (AVANA_REFRESH_TOKEN_TTL_DAYS)")
+	}
+	if c.Server.Port <= 0 || c.Server.Port > 65535 {
+		problems = append(problems, "server port must be between 1 and 65535 (AVANA_PORT)")
+	}
+	if c.Server.ReservationTTLMinutes <= 0 {
+		problems = append(problems, "reservation ttl must be positive (AVANA_RESERVATION_TTL_MINUTES)")
+	}
+	if c.Server.OrderTTLMinutes <= 0 {
+		problems = append(problems, "order ttl must be positive (AVANA_ORDER_TTL_MINUTES)")
+	}
+	if c.Mail.SMTPHost != "" && c.Mail.From == "" {
+		problems = append(problems, "mail from address is required with smtp (AVANA_MAIL_FROM)")
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (c ServerConfig) Addr() string {
+	return ":" + strconv.Itoa(c.Port)
+}
+
+func (c AuthConfig) AccessTokenTTL() time.Duration {
+	return time.Duration(c.AccessTokenTTLMinutes) * time.Minute
+}
+
+func (c AuthConfig) RefreshTokenTTL() time.Duration {
+	return time.Duration(c.RefreshTokenTTLDays) * 24 * time.Hour
+}
+
+// Defaults returns the settings used when neither a file nor the environment sets them.
+func Defaults() Config {
+	return Config{
+		Server: ServerConfig{
+			Port: 8000,
+			ReservationTTLMinutes: 10,
+			OrderTTLMinutes: 30,
+		},
+		Auth: AuthConfig{
+			AccessTokenTTLMinutes: 15,
+			RefreshTokenTTLDays: 30,
+			AcceptHMACTokens: true,
+		},
+		Mail: MailConfig{
+			SMTPPort: 587,
+			From: "no-reply@avana.local",
+			OutboxDir: "mail",
+		},
+		Payments: PaymentsConfig{
+			Currency: "NGN",
+		},
+	}
+}
+
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("unsupported config file type %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("parsing config file: %w", err)
+	}
+	return nil
+}
+
+func loadEnv(cfg *Config) error {
+	stringVars := map[string]*string{
+		"AVANA_DATABASE_DSN": &cfg.Database.DSN,
+		"AVANA_JWT_SECRET": &cfg.Auth.JWTSecret,
+		"AVANA_SMTP_HOST": &cfg.Mail.SMTPHost,
+		"AVANA_SMTP_USERNAME": &cfg.Mail.SMTPUsername,
+		"AVANA_SMTP_PASSWORD": &cfg.Mail.SMTPPassword,
+		"AVANA_MAIL_FROM": &cfg.Mail.From,
+		"AVANA_MAIL_OUTBOX_DIR": &cfg.Mail.OutboxDir,
+		"AVANA_PAYMENT_PROVIDER": &cfg.Payments.Provider,
+		"AVANA_PAYMENT_SECRET_KEY": &cfg.Payments.SecretKey,
+		"AVANA_PAYMENT_WEBHOOK_SECRET": &cfg.Payments.WebhookSecret,
+		"AVANA_PAYMENT_IDEMPOTENCY_PREFIX": &cfg.Payments.IdempotencyPrefix,
+		"AVANA_PAYMENT_CURRENCY": &cfg.Payments.Currency,
+		"AVANA_JWT_KEYS_DIR": &cfg.Auth.KeysDir,
+		"AVANA_JWT_ACTIVE_KEY_ID": &cfg.Auth.ActiveKeyID,
+		"AVANA_TICKET_KEY_FILE": &cfg.Auth.TicketKeyFile,
+	}
+	for name, field := range stringVars {
+		if value, ok := os.LookupEnv(name); ok {
+			*field = value
+		}
+	}
+
+	intVars := map[string]*int{
+		"AVANA_PORT": &cfg.Server.Port,
+		"AVANA_RESERVATION_TTL_MINUTES": &cfg.Server.ReservationTTLMinutes,
+		"AVANA_ORDER_TTL_MINUTES": &cfg.Server.OrderTTLMinutes,
+		"AVANA_SMTP_PORT": &cfg.Mail.SMTPPort,
+		"AVANA_ACCESS_TOKEN_TTL_MINUTES": &cfg.Auth.AccessTokenTTLMinutes,
+		"AVANA_REFRESH_TOKEN_TTL_DAYS": &cfg.Auth.RefreshTokenTTLDays,
+	}
+	for name, field := range intVars {
+		if value, ok := os.LookupEnv(name); ok {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s must be a number", name)
+			}
+			*field = parsed
+		}
+	}
+
+	boolVars := map[string]*bool{
+		"AVANA_ACCEPT_HMAC_TOKENS": &cfg.Auth.AcceptHMACTokens,
+	}
+	for name, field := range boolVars {
+		if value, ok := os.LookupEnv(name); ok {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%s must be true or false", name)
+			}
+			*field = parsed
+		}
+	}
+
+	return nil
+}
@@ -9,10 +9,10 @@ import (
 var DB *gorm.DB
 
 
-func ConnectToDb() {
+func ConnectToDb(cfg DatabaseConfig) {
 	var err error
-	dsn := "host=localhost user=postgres password=subomi7205 dbname=avana port=5432 sslmode=disable TimeZone=Africa/Lagos"
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// translated errors let repository.GormError tell a unique violation apart
+	DB, err = gorm.Open(postgres.Open(cfg.DSN), &gorm.Config{TranslateError: true})
 
 	if err != nil {
 	log.Fatal("Error to connect to database")
@@ -0,0 +1,134 @@
+// Package dbtest gives tests a migrated Postgres schema of their own. The
+// database comes from AVANA_TEST_DATABASE_DSN, tests that need it are
+// skipped when it is not set.
+package dbtest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const dsnVariable = "AVANA_TEST_DATABASE_DSN"
+
+// Open creates a schema with every up migration applied and returns a
+// connection that only sees it. The schema is dropped when the test ends.
+func Open(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv(dsnVariable)
+	if dsn == "" {
+		t.Skipf("%s is not set, skipping the postgres run", dsnVariable)
+	}
+
+	admin, err := open(dsn)
+	if err != nil {
+		t.Fatalf("connecting to postgres: %v", err)
+	}
+
+	suffix := make([]byte, 6)
+	rand.Read(suffix)
+	schema := "avana_test_" + hex.EncodeToString(suffix)
+	if err = admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("creating schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if db, err := admin.DB(); err == nil {
+			db.Close()
+		}
+	})
+
+	db, err := open(withSearchPath(dsn, schema))
+	if err != nil {
+		t.Fatalf("connecting to schema: %v", err)
+	}
+	conn, err := db.DB()
+	if err != nil {
+		t.Fatalf("opening pool: %v", err)
+	}
+	// parallel tests must queue for a connection, not exhaust the server
+	conn.SetMaxOpenConns(20)
+	t.Cleanup(func() { conn.Close() })
+
+	if err = migrate(db); err != nil {
+		t.Fatalf("migrating schema: %v", err)
+	}
+	return db
+}
+
+// open matches config.ConnectToDb, translated errors are what the
+// repositories look for.
+func open(dsn string) (*gorm.DB, error) {
+	return gorm.Open(postgres.Open(dsn), &gorm.Config{
+		TranslateError: true,
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+}
+
+// withSearchPath points every pooled connection at the schema, for both the
+// url and the key=value forms of a dsn.
+func withSearchPath(dsn string, schema string) string {
+	if strings.Contains(dsn, "://") {
+		if parsed, err := url.Parse(dsn); err == nil {
+			query := parsed.Query()
+			query.Set("search_path", schema)
+			parsed.RawQuery = query.Encode()
+			return parsed.String()
+		}
+	}
+	return dsn + " search_path=" + schema
+}
+
+// migrate runs the up files in version order, the same files the
+// migrations command applies.
+func migrate(db *gorm.DB) error {
+	dir, err := migrationsDir()
+	if err != nil {
+		return err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.up.sql"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		sql, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if err = db.Exec(string(sql)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrationsDir finds the migrations folder next to go.mod, tests run from
+// their own package directory.
+func migrationsDir() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return filepath.Join(dir, "migrations"), nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", os.ErrNotExist
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,341 @@
+package events
+
+import (
+	"avana/internal/payments"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+const (
+	RefundCompleted string = "refunded"
+	RefundPending string = "pending"
+)
+
+// RefundLine is the refund owed on one paid order of a cancelled event.
+type RefundLine struct {
+	OrderID uint
+	UserID uint
+	Amount float64
+	Currency string
+	Status string
+}
+
+// CancellationReport sums up what a cancellation did to the people holding
+// tickets. It is read back from the stored records, so the organiser can
+// fetch it again at any time.
+type CancellationReport struct {
+	EventID uint
+	EventName string
+	Reason string
+	CancelledAt *time.Time
+	AttendeesCancelled int
+	UnitsCancelled uint
+	// OrdersVoided counts checkouts that were still awaiting payment
+	OrdersVoided int
+	Refunds []RefundLine
+	RefundedAmount float64
+	// OutstandingRefunds counts paid orders the provider has not refunded
+	// yet, retrying the cancellation tries them again
+	OutstandingRefunds int
+	// NotificationsSent is only filled in by the request that sent them
+	NotificationsSent int
+}
+
+// Cancel stops sales for good while keeping the event on record. Every
+// attendee is cancelled, open checkouts and holds are released, paid orders
+// are refunded through the payment provider and the attendees are told.
+// A refund the provider turns down is left outstanding in the report.
+func (s *EventService) Cancel(actor Actor, eventId uint, schema CancelEventSchema) (CancellationReport, error) {
+	event, err := s.transition(actor, eventId, EventCancelled, EventPermDelete, func(tx Tx, event *Event) error {
+		event.StatusReason = schema.Reason
+		return nil
+	})
+	if err != nil {
+		return CancellationReport{}, err
+	}
+
+	// only the attendees this cancellation took a ticket from are told
+	cancelled, err := s.settleCancellation(event)
+	if err != nil {
+		return CancellationReport{}, err
+	}
+
+	sent := s.notifyAttendees(event, cancelled)
+
+	report, err := s.cancellationReport(event)
+	if err != nil {
+		return CancellationReport{}, err
+	}
+	report.NotificationsSent = sent
+	return report, nil
+}
+
+// RetryCancellation settles whatever an earlier cancellation left undone,
+// such as refunds the provider failed. Attendees are not notified again.
+func (s *EventService) RetryCancellation(actor Actor, eventId uint) (CancellationReport, error) {
+	event, err := s.cancelledEvent(actor, eventId, EventPermDelete)
+	if err != nil {
+		return CancellationReport{}, err
+	}
+
+	if _, err = s.settleCancellation(event); err != nil {
+		return CancellationReport{}, err
+	}
+	return s.cancellationReport(event)
+}
+
+// CancellationReport returns the report of a cancelled event.
+func (s *EventService) CancellationReport(actor Actor, eventId uint) (CancellationReport, error) {
+	event, err := s.cancelledEvent(actor, eventId, EventPermEdit)
+	if err != nil {
+		return CancellationReport{}, err
+	}
+	return s.cancellationReport(event)
+}
+
+func (s *EventService) cancelledEvent(actor Actor, eventId uint, permission string) (Event, error) {
+	event, err := s.store.Events().FindByID(eventId)
+	if err != nil {
+		return Event{}, err
+	}
+
+	if err = s.Authorize(actor, event, permission); err != nil {
+		return Event{}, err
+	}
+
+	if event.Status != EventCancelled {
+		return Event{}, ErrEventNotCancelled
+	}
+	return event, nil
+}
+
+// settleCancellation cancels the event's attendees, releases its holds and
+// open checkouts, then refunds its paid orders one by one. Each step skips
+// what is already done, so it is safe to run again. It returns the
+// attendees it cancelled.
+func (s *EventService) settleCancellation(event Event) ([]Attendee, error) {
+	var cancelled []Attendee
+
+	tickets, err := s.store.Tickets().ListByEvent(event.ID)
+	if err != nil {
+		return nil, err
+	}
+	ticketIds := make([]uint, 0, len(tickets))
+	for _, ticket := range tickets {
+		ticketIds = append(ticketIds, ticket.ID)
+	}
+
+	err = withTransaction(s.store, func(tx Tx) error {
+		// a purchase holding the event finishes first, any later one sees it cancelled
+		if _, err := tx.Events().FindByIDForUpdate(event.ID); err != nil {
+			return err
+		}
+
+		attendees, err := tx.Attendees().ListByTickets(ticketIds)
+		if err != nil {
+			return err
+		}
+		for i := range attendees {
+			if attendees[i].Status == AttendeeCancelled {
+				continue
+			}
+			attendees[i].Status = AttendeeCancelled
+			if err := tx.Attendees().Save(&attendees[i]); err != nil {
+				return err
+			}
+			if err := revokeTicketCodes(tx, attendees[i].ID, RevokedCancelled); err != nil {
+				return err
+			}
+			cancelled = append(cancelled, attendees[i])
+		}
+
+		reservations, err := tx.Reservations().ListHeldByTickets(ticketIds)
+		if err != nil {
+			return err
+		}
+		for i := range reservations {
+			if err := releaseReservation(tx, &reservations[i], ReservationCancelled); err != nil {
+				return err
+			}
+		}
+
+		// a payment that still arrives for one of these is refunded when it settles
+		pending, err := tx.Orders().ListByTickets(ticketIds, payments.OrderPending)
+		if err != nil {
+			return err
+		}
+		for i := range pending {
+			if err := tx.Tickets().Release(pending[i].TicketID, pending[i].Units); err != nil {
+				return err
+			}
+			pending[i].Status = payments.OrderCancelled
+			if err := tx.Orders().Save(&pending[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// refunds a provider failed earlier are still refunding, ask again
+	for _, status := range []string{payments.OrderPaid, payments.OrderRefunding} {
+		orders, err := s.store.Orders().ListByTickets(ticketIds, status)
+		if err != nil {
+			return nil, err
+		}
+		for _, order := range orders {
+			if err := s.refundOrder(order.ID); err != nil {
+				log.Printf("refunding order %d of cancelled event %d: %v", order.ID, event.ID, err)
+			}
+		}
+	}
+	return cancelled, nil
+}
+
+// refundOrder refunds a paid order in full. The order is marked refunding
+// under its lock, then the provider is asked outside the transaction.
+func (s *EventService) refundOrder(orderId uint) error {
+	var order payments.Order
+	var payment payments.Payment
+	due := false
+
+	err := withTransaction(s.store, func(tx Tx) error {
+		var err error
+		order, err = tx.Orders().FindByIDForUpdate(orderId)
+		if err != nil {
+			return err
+		}
+		if order.Status != payments.OrderPaid && order.Status != payments.OrderRefunding {
+			return nil
+		}
+
+		payment, err = tx.Payments().FindByOrder(order.ID)
+		if err != nil {
+			return err
+		}
+		due = true
+		return markRefunding(tx, &order)
+	})
+	if err != nil || !due {
+		return err
+	}
+
+	return refundPayment(s.store, s.payments, order, payment)
+}
+
+// markRefunding records that the order's refund is about to be asked for,
+// the order row must already be locked by the caller.
+func markRefunding(tx Tx, order *payments.Order) error {
+	if order.Status == payments.OrderRefunding {
+		return nil
+	}
+	order.Status = payments.OrderRefunding
+	return tx.Orders().Save(order)
+}
+
+// refundPayment returns the whole amount of a refunding order through the
+// provider and records the refund. It runs outside any transaction so a slow
+// provider holds no locks. The key is derived from the order, so asking
+// again after a failure or a crash never refunds twice.
+func refundPayment(store Store, provider payments.PaymentProvider, order payments.Order, payment payments.Payment) error {
+	key := "order-" + strconv.Itoa(int(order.ID))
+	intent, err := provider.Refund(payment.Reference, payments.ToMinorUnits(order.Amount), key)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrPaymentProvider, err)
+	}
+
+	return withTransaction(store, func(tx Tx) error {
+		locked, err := tx.Orders().FindByIDForUpdate(order.ID)
+		if err != nil {
+			return err
+		}
+		// a parallel request recorded it already
+		if locked.Status != payments.OrderRefunding {
+			return nil
+		}
+
+		stored, err := tx.Payments().FindByOrder(order.ID)
+		if err != nil {
+			return err
+		}
+		stored.Status = payments.IntentRefunded
+		stored.RefundedAmount = payments.FromMinorUnits(intent.Refunded)
+		if err := tx.Payments().Save(&stored); err != nil {
+			return err
+		}
+		locked.Status = payments.OrderRefunded
+		return tx.Orders().Save(&locked)
+	})
+}
+
+func (s *EventService) cancellationReport(event Event) (CancellationReport, error) {
+	report := CancellationReport{
+		EventID: event.ID,
+		EventName: event.Name,
+		Reason: event.StatusReason,
+		CancelledAt: event.StatusChangedAt,
+		Refunds: []RefundLine{},
+	}
+
+	tickets, err := s.store.Tickets().ListByEvent(event.ID)
+	if err != nil {
+		return CancellationReport{}, err
+	}
+	ticketIds := make([]uint, 0, len(tickets))
+	for _, ticket := range tickets {
+		ticketIds = append(ticketIds, ticket.ID)
+	}
+
+	attendees, err := eventAttendees(s.store, tickets)
+	if err != nil {
+		return CancellationReport{}, err
+	}
+	for _, attendee := range attendees {
+		if attendee.Status == AttendeeCancelled {
+			report.AttendeesCancelled++
+			report.UnitsCancelled += attendee.Units
+		}
+	}
+
+	voided, err := s.store.Orders().ListByTickets(ticketIds, payments.OrderCancelled)
+	if err != nil {
+		return CancellationReport{}, err
+	}
+	report.OrdersVoided = len(voided)
+
+	refunded, err := s.store.Orders().ListByTickets(ticketIds, payments.OrderRefunded)
+	if err != nil {
+		return CancellationReport{}, err
+	}
+	for _, order := range refunded {
+		report.Refunds = append(report.Refunds, refundLine(order, RefundCompleted))
+		report.RefundedAmount += order.Amount
+	}
+
+	for _, status := range []string{payments.OrderPaid, payments.OrderRefunding} {
+		outstanding, err := s.store.Orders().ListByTickets(ticketIds, status)
+		if err != nil {
+			return CancellationReport{}, err
+		}
+		for _, order := range outstanding {
+			report.Refunds = append(report.Refunds, refundLine(order, RefundPending))
+		}
+		report.OutstandingRefunds += len(outstanding)
+	}
+
+	return report, nil
+}
+
+func refundLine(order payments.Order, status string) RefundLine {
+	return RefundLine{
+		OrderID: order.ID,
+		UserID: order.UserID,
+		Amount: order.Amount,
+		Currency: order.Currency,
+		Status: status,
+	}
+}
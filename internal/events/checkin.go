@@ -0,0 +1,245 @@
+package events
+
+import (
+	"avana/internal/repository"
+	"avana/internal/users"
+	"errors"
+	"time"
+)
+
+// AttendeeCodeLength is how many characters a purchase's door code has.
+const AttendeeCodeLength = 10
+
+// CheckInResult is where a purchase stands at the door after a scan.
+type CheckInResult struct {
+	AttendeeID uint
+	Code string
+	TicketType string
+	Units uint
+	CheckedIn uint
+	Remaining uint
+	// CheckIns lists every scan that let units in, earliest first, so a
+	// refused duplicate shows when and by whom the ticket was used
+	CheckIns []CheckIn
+}
+
+// CheckIn lets units of a purchase into the event. The code is either the
+// purchase's door code, which lets in the given number of units or every
+// remaining one, or a unit's signed code, which lets in that unit. Once a
+// code is used up a scan fails with ErrAlreadyCheckedIn and the result
+// still describes the earlier check-ins.
+func (s *EventService) CheckIn(actor Actor, eventId uint, schema CheckInSchema) (CheckInResult, error) {
+	event, err := s.checkInEvent(actor, eventId)
+	if err != nil {
+		return CheckInResult{}, err
+	}
+
+	if isClosed(event) {
+		return CheckInResult{}, ErrEventClosed
+	}
+
+	var result CheckInResult
+	err = withTransaction(s.store, func(tx Tx) error {
+		var err error
+		if isTicketToken(schema.Code) {
+			result, err = s.checkInUnit(tx, actor, event, schema.Code)
+		} else {
+			result, err = checkInPurchase(tx, actor, event, schema)
+		}
+		return err
+	})
+
+	return result, err
+}
+
+func checkInPurchase(tx Tx, actor Actor, event Event, schema CheckInSchema) (CheckInResult, error) {
+	attendee, err := tx.Attendees().FindByCodeForUpdate(schema.Code)
+	if errors.Is(err, repository.ErrNotFound) {
+		return CheckInResult{}, ErrUnknownTicketCode
+	}
+	if err != nil {
+		return CheckInResult{}, err
+	}
+
+	ticket, err := scannedTicket(tx, event, attendee)
+	if err != nil {
+		return CheckInResult{}, err
+	}
+
+	remaining := attendee.Units - attendee.CheckedIn
+	if remaining == 0 {
+		return alreadyCheckedIn(tx, attendee, ticket)
+	}
+
+	units := schema.Units
+	if units == 0 {
+		units = remaining
+	}
+	if units > remaining {
+		return CheckInResult{}, ErrTooManyUnits
+	}
+
+	if err = admit(tx, &attendee, nil, newCheckIn(actor, event, units)); err != nil {
+		return CheckInResult{}, err
+	}
+	return checkInResult(tx, attendee, ticket)
+}
+
+func (s *EventService) checkInUnit(tx Tx, actor Actor, event Event, token string) (CheckInResult, error) {
+	serial, eventId, err := s.parseTicketToken(token)
+	if err != nil {
+		return CheckInResult{}, err
+	}
+	if eventId != event.ID {
+		return CheckInResult{}, ErrUnknownTicketCode
+	}
+
+	code, err := tx.TicketCodes().FindBySerialForUpdate(serial)
+	if errors.Is(err, repository.ErrNotFound) {
+		return CheckInResult{}, ErrUnknownTicketCode
+	}
+	if err != nil {
+		return CheckInResult{}, err
+	}
+
+	attendee, err := tx.Attendees().FindByIDForUpdate(code.AttendeeID)
+	if err != nil {
+		return CheckInResult{}, err
+	}
+	ticket, err := scannedTicket(tx, event, attendee)
+	if err != nil {
+		return CheckInResult{}, err
+	}
+
+	if code.Status != TicketCodeValid {
+		return CheckInResult{}, ErrTicketRevoked
+	}
+	if code.CheckedInAt != nil {
+		return alreadyCheckedIn(tx, attendee, ticket)
+	}
+
+	if err = admit(tx, &attendee, &code, newCheckIn(actor, event, 1)); err != nil {
+		return CheckInResult{}, err
+	}
+	return checkInResult(tx, attendee, ticket)
+}
+
+// scannedTicket returns the ticket of a scanned purchase, failing unless
+// the purchase is for the event and still stands.
+func scannedTicket(tx Tx, event Event, attendee Attendee) (Ticket, error) {
+	ticket, err := tx.Tickets().FindByID(attendee.TicketID)
+	if err != nil {
+		return Ticket{}, err
+	}
+
+	// a code from another event is as good as no code here
+	if ticket.EventID != event.ID {
+		return Ticket{}, ErrUnknownTicketCode
+	}
+	if attendee.Status != AttendeeActive {
+		return Ticket{}, ErrTicketCancelled
+	}
+	return ticket, nil
+}
+
+func alreadyCheckedIn(tx Tx, attendee Attendee, ticket Ticket) (CheckInResult, error) {
+	result, err := checkInResult(tx, attendee, ticket)
+	if err != nil {
+		return CheckInResult{}, err
+	}
+	return result, ErrAlreadyCheckedIn
+}
+
+func newCheckIn(actor Actor, event Event, units uint) CheckIn {
+	return CheckIn{
+		EventID: event.ID,
+		Units: units,
+		CheckedInByID: actor.ID,
+		CheckedInAt: time.Now(),
+	}
+}
+
+// admit records the check-in's units of the purchase as let in. A scanned
+// unit code is marked used, otherwise the purchase's unused codes are, in
+// unit order.
+func admit(tx Tx, attendee *Attendee, scanned *TicketCode, checkIn CheckIn) error {
+	at := checkIn.CheckedInAt
+
+	codes := []TicketCode{}
+	if scanned != nil {
+		codes = append(codes, *scanned)
+	} else {
+		issued, err := tx.TicketCodes().ListByAttendees([]uint{attendee.ID})
+		if err != nil {
+			return err
+		}
+		for _, code := range issued {
+			if uint(len(codes)) == checkIn.Units {
+				break
+			}
+			if code.Status == TicketCodeValid && code.CheckedInAt == nil {
+				codes = append(codes, code)
+			}
+		}
+	}
+	for i := range codes {
+		codes[i].CheckedInAt = &at
+		if err := tx.TicketCodes().Save(&codes[i]); err != nil {
+			return err
+		}
+	}
+
+	checkIn.AttendeeID = attendee.ID
+	if scanned != nil {
+		checkIn.TicketCodeID = &scanned.ID
+	}
+	if err := tx.CheckIns().Create(&checkIn); err != nil {
+		return err
+	}
+
+	attendee.CheckedIn += checkIn.Units
+	attendee.Attended = true
+	return tx.Attendees().Save(attendee)
+}
+
+// CheckIns lists the event's check-ins, latest first.
+func (s *EventService) CheckIns(actor Actor, eventId uint) ([]CheckIn, error) {
+	event, err := s.checkInEvent(actor, eventId)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.CheckIns().ListByEvent(event.ID)
+}
+
+// checkInEvent loads the event if the actor works its door, either as
+// platform staff or through the event's team.
+func (s *EventService) checkInEvent(actor Actor, eventId uint) (Event, error) {
+	event, err := s.store.Events().FindByID(eventId)
+	if err != nil {
+		return Event{}, err
+	}
+
+	if !actor.can(users.PermCheckInAny) {
+		if err = s.Authorize(actor, event, EventPermCheckIn); err != nil {
+			return Event{}, err
+		}
+	}
+	return event, nil
+}
+
+func checkInResult(tx Tx, attendee Attendee, ticket Ticket) (CheckInResult, error) {
+	checkIns, err := tx.CheckIns().ListByAttendee(attendee.ID)
+	if err != nil {
+		return CheckInResult{}, err
+	}
+
+	return CheckInResult{
+		AttendeeID: attendee.ID,
+		Code: attendee.Code,
+		TicketType: ticket.Name,
+		Units: attendee.Units,
+		CheckedIn: attendee.CheckedIn,
+		Remaining: attendee.Units - attendee.CheckedIn,
+		CheckIns: checkIns,
+	}, nil
+}
@@ -1,139 +1,142 @@
 package events
 
 import (
-	"avana/internal/config"
+	"avana/internal/apperror"
+	"avana/internal/mailer"
+	"avana/internal/payments"
+	"avana/internal/tokens"
+	"avana/internal/users"
 	"avana/internal/utils"
 	"errors"
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
+// Handler serves the event routes. The rules live in the event and ticket
+// services, the handler only binds requests and renders their outcome.
+type Handler struct {
+	events *EventService
+	tickets *TicketService
+}
+
+func NewHandler(store Store, userRepo users.UserRepository, provider payments.PaymentProvider, mail mailer.Mailer, signer *tokens.TicketSigner) *Handler {
+	eventService := NewEventService(store, userRepo, provider, mail, signer)
+	return &Handler{
+		events: eventService,
+		tickets: NewTicketService(store, eventService, provider),
+	}
+}
 
-func CreateEvent(c *gin.Context) {
+
+func (h *Handler) CreateEvent(c *gin.Context) {
 	// get the user id
-	userId, err := getUserId(c)
+	actor, err := getActor(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": utils.ValidateTokenError,
-		})
+		apperror.Write(c, apperror.ErrUnauthenticated)
 		return
 	}
 	
 	// bind the request data
 	var eventSchema CreateEventSchema
-	if c.Bind(&eventSchema) != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": utils.ReadRequestError,
-		})
+	if err := c.ShouldBind(&eventSchema); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
 		return
 	}
 
-	// validate all dates
-	eventDate, err := utils.ValidateDate(eventSchema.EventDate)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": utils.ReadRequestError,
-		})
+	if _, err = h.events.Create(actor, eventSchema); err != nil {
+		apperror.Write(c, err)
 		return
 	}
 
-	regExpDate, err := utils.ValidateDate(eventSchema.RegistrationExpirationDate)
+	// return success 
+	c.JSON(http.StatusOK, gin.H{
+		"message": utils.CreateRecordSuccess,
+	})
+}
+
+func (h *Handler) UpdateEvent(c *gin.Context) {
+	// get the user id
+	actor, err := getActor(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": utils.ReadRequestError,
-		})
+		apperror.Write(c, apperror.ErrUnauthenticated)
 		return
 	}
 
-	// create the object
-	event := Event{
-		Name: eventSchema.Name,
-		Organiser: eventSchema.Organiser,
-		Location: eventSchema.Location,
-		IsPaidEvent: eventSchema.IsPaidEvent,
-		Description: eventSchema.Description,
-		IsLimited: eventSchema.IsLimitedEvent,
-		MaxUnitReservation: eventSchema.MaxUnitReservation,
-		EventDate: eventDate,
-		RegistrationExpirationDate: regExpDate,
-		UserID: userId,
-
+	// get the update object
+	var updateSchema UpdateEventSchema
+	if err := c.ShouldBind(&updateSchema); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
+		return
 	}
 
-	// start the saving transaction
-	tx := config.DB.Begin()
-	
-	result := tx.Create(&event)
-	if result.Error != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": utils.CreateRecordError + "1",
-		})
+	// get the id
+	eventIdStr := c.Param("id")
+	eventId, err := strconv.Atoi(eventIdStr)
+	if err != nil {
+		apperror.Write(c, apperror.InvalidParam("id"))
 		return
 	}
 
-	// save all the tickets
-	if len(eventSchema.Tickets) > 0{
-		for _, ticketSchema := range eventSchema.Tickets {
-			if err := createTicket(ticketSchema,event.ID,tx); err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{
-				"message": utils.CreateRecordError+ "2",
-			})
-			return
-			}
-		}
-	} else {
-		ticket := TicketSchema{
-			Name: "Regular",
-			Price: 0,
-			TotalAvailable: 0,
-			SingleLimit: eventSchema.MaxUnitReservation,
-			ExpiryTime: eventSchema.RegistrationExpirationDate,
-		}
-
-		if eventSchema.IsLimitedEvent {
-			ticket.TotalAvailable  = eventSchema.TotalTicketLimit
-		}
-
-		if err := createTicket(ticket,event.ID,tx); err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{
-			"message": utils.CreateRecordError+ "3",
-		})
+	if _, err = h.events.Update(actor, uint(eventId), updateSchema); err != nil {
+		apperror.Write(c, err)
 		return
-		}
-
 	}
 
-	tx.Commit()
+	//return success 
+	c.JSON(http.StatusOK,gin.H{
+		"message": utils.UpdateRecordSuccess,
+	})
+}
+
+func (h *Handler) PublishEvent(c *gin.Context) {
+	h.changeStatus(c, func(actor Actor, eventId uint) (Event, error) {
+		return h.events.Publish(actor, eventId)
+	})
+}
 
-	// return success 
-	c.JSON(http.StatusOK, gin.H{
-		"message": utils.CreateRecordSuccess,
+func (h *Handler) PostponeEvent(c *gin.Context) {
+	// bind the reason and the new dates
+	var postponeSchema PostponeEventSchema
+	if err := c.ShouldBind(&postponeSchema); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
+		return
+	}
+
+	h.changeStatus(c, func(actor Actor, eventId uint) (Event, error) {
+		return h.events.Postpone(actor, eventId, postponeSchema)
 	})
 }
 
-func UpdateEvent(c *gin.Context) {
-	// get the user id
-	userId, err := getUserId(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": utils.ValidateTokenError,
-		})
+func (h *Handler) CancelEvent(c *gin.Context) {
+	// bind the reason
+	var cancelSchema CancelEventSchema
+	if err := c.ShouldBind(&cancelSchema); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
 		return
 	}
 
-	// get the update object
-	var updateSchema UpdateEventSchema
-	if err := c.ShouldBind(&updateSchema); err != nil {
-		c.JSON(http.StatusBadRequest,gin.H{
-			"message": utils.ReadRequestError,
-		})
+	h.writeCancellation(c, func(actor Actor, eventId uint) (CancellationReport, error) {
+		return h.events.Cancel(actor, eventId, cancelSchema)
+	})
+}
+
+func (h *Handler) GetCancellationReport(c *gin.Context) {
+	h.writeCancellation(c, h.events.CancellationReport)
+}
+
+func (h *Handler) RetryCancellation(c *gin.Context) {
+	h.writeCancellation(c, h.events.RetryCancellation)
+}
+
+// writeCancellation runs a cancellation action on the event in the path
+// and renders its report.
+func (h *Handler) writeCancellation(c *gin.Context, action func(actor Actor, eventId uint) (CancellationReport, error)) {
+	// get the user id
+	actor, err := getActor(c)
+	if err != nil {
+		apperror.Write(c, apperror.ErrUnauthenticated)
 		return
 	}
 
@@ -141,85 +144,100 @@ func UpdateEvent(c *gin.Context) {
 	eventIdStr := c.Param("id")
 	eventId, err := strconv.Atoi(eventIdStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest,gin.H{
-			"message": utils.ReadRequestError,
-		})
+		apperror.Write(c, apperror.InvalidParam("id"))
 		return
 	}
 
-	// get the object from db
-	var event  Event
-	err = config.DB.First(&event, eventId).Error
+	report, err := action(actor, uint(eventId))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError,gin.H{
-			"message": utils.DatabaseCallError,
-		})
+		apperror.Write(c, err)
 		return
 	}
 
-	// check the permission 
-	if err = canOperate(userId,event.UserID); err != nil {
-		c.JSON(http.StatusUnauthorized,gin.H{
-			"message": utils.IncorrecPermission,
-		})
+	c.JSON(http.StatusOK,gin.H{
+		"report": report,
+	})
+}
+
+func (h *Handler) CompleteEvent(c *gin.Context) {
+	h.changeStatus(c, func(actor Actor, eventId uint) (Event, error) {
+		return h.events.Complete(actor, eventId)
+	})
+}
+
+// changeStatus runs a lifecycle action on the event in the path and
+// renders the event with its new status.
+func (h *Handler) changeStatus(c *gin.Context, action func(actor Actor, eventId uint) (Event, error)) {
+	// get the user id
+	actor, err := getActor(c)
+	if err != nil {
+		apperror.Write(c, apperror.ErrUnauthenticated)
 		return
 	}
 
-
-	//  use the update data to populate the model
-	updateData, err := getEventUpdateData(updateSchema,event)
+	// get the id
+	eventIdStr := c.Param("id")
+	eventId, err := strconv.Atoi(eventIdStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest,gin.H{
-			"message": utils.ReadRequestError,
-		})
+		apperror.Write(c, apperror.InvalidParam("id"))
 		return
 	}
 
-	if err = config.DB.Save(&updateData).Error; err != nil {
-		c.JSON(http.StatusInternalServerError,gin.H{
-			"message": utils.UpdateRecordError,
-		})
+	event, err := action(actor, uint(eventId))
+	if err != nil {
+		apperror.Write(c, err)
 		return
 	}
 
-	//return success 
 	c.JSON(http.StatusOK,gin.H{
 		"message": utils.UpdateRecordSuccess,
+		"event": event,
 	})
 }
 
 
-func GetAllEvent(c *gin.Context) {
-	var events []Event
-	if err := config.DB.Order("created_at DESC").Find(&events).Error ; err != nil {
-		c.JSON(http.StatusInternalServerError,gin.H{
-			"message": utils.DatabaseCallError,
-		})
+func (h *Handler) GetAllEvent(c *gin.Context) {
+	// bind the filters, sort and page from the query string
+	var querySchema EventQuerySchema
+	if err := c.ShouldBindQuery(&querySchema); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
+		return
+	}
+
+	page, err := h.events.List(querySchema)
+	if err != nil {
+		apperror.Write(c, err)
 		return
 	}
 
+	var nextPage *int
+	if next := page.NextPage(); next != 0 {
+		nextPage = &next
+	}
+
 	c.JSON(http.StatusOK,gin.H{
-		"event":events,
+		"event":page.Events,
+		"meta": gin.H{
+			"total": page.Total,
+			"page": page.Page,
+			"pageSize": page.PageSize,
+			"nextPage": nextPage,
+		},
 	})
 }
 
-func GetEventByID(c *gin.Context) {
+func (h *Handler) GetEventByID(c *gin.Context) {
 	// get the event by id 
 	eventIdStr := c.Param("id")
 	eventId, err := strconv.Atoi(eventIdStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest,gin.H{
-			"message": utils.ReadRequestError,
-		})
+		apperror.Write(c, apperror.InvalidParam("id"))
 		return
 	}
 
-	// query the database
-	var event Event
-	if err = config.DB.First(&event,eventId).Error; err != nil {
-		c.JSON(http.StatusInternalServerError,gin.H{
-			"message": utils.DatabaseCallError,
-		})
+	event, err := h.events.Get(getViewer(c), uint(eventId))
+	if err != nil {
+		apperror.Write(c, err)
 		return
 	}
 
@@ -228,23 +246,18 @@ func GetEventByID(c *gin.Context) {
 	})
 }
 
-func GetAllTickets(c *gin.Context) {
+func (h *Handler) GetAllTickets(c *gin.Context) {
 	// get the ticket id 
 	eventIdStr := c.Param("id")
 	eventId, err := strconv.Atoi(eventIdStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest,gin.H{
-			"message": utils.ReadRequestError,
-		})
+		apperror.Write(c, apperror.InvalidParam("id"))
 		return
 	}
 
-	// query the database
-	var tickets []Ticket
-	if err := config.DB.Where("event_id = ?",eventId).Order("price").Find(&tickets).Error; err != nil {
-		c.JSON(http.StatusInternalServerError,gin.H{
-			"message": utils.DatabaseCallError,
-		})
+	tickets, err := h.tickets.ListByEvent(getViewer(c), uint(eventId))
+	if err != nil {
+		apperror.Write(c, err)
 		return
 	}
 
@@ -254,23 +267,18 @@ func GetAllTickets(c *gin.Context) {
 	})
 }
 
-func GetTicketById(c *gin.Context) {
+func (h *Handler) GetTicketById(c *gin.Context) {
 	// get the ticket id 
 	ticketIdStr := c.Param("id")
 	ticketId, err := strconv.Atoi(ticketIdStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest,gin.H{
-			"message": utils.ReadRequestError,
-		})
+		apperror.Write(c, apperror.InvalidParam("id"))
 		return
 	}
 
-	// query the database
-	var ticket Ticket
-	if err := config.DB.First(&ticket,ticketId).Error; err != nil {
-		c.JSON(http.StatusInternalServerError,gin.H{
-			"message": utils.DatabaseCallError,
-		})
+	ticket, err := h.tickets.Get(getViewer(c), uint(ticketId))
+	if err != nil {
+		apperror.Write(c, err)
 		return
 	}
 
@@ -280,22 +288,18 @@ func GetTicketById(c *gin.Context) {
 	})
 }
 
-func AddTicket(c *gin.Context) {
+func (h *Handler) AddTicket(c *gin.Context) {
 	// bind the ticket object
 	var ticketSchema TicketSchema
-	if c.Bind(&ticketSchema) !=nil {
-		c.JSON(http.StatusBadRequest,gin.H{
-			"message": utils.ReadRequestError,
-		})
+	if err := c.ShouldBind(&ticketSchema); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
 		return
 	}
 
 	// get the user id 
-	userId, err := getUserId(c)
+	actor, err := getActor(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": utils.ValidateTokenError,
-		})
+		apperror.Write(c, apperror.ErrUnauthenticated)
 		return
 	}
 
@@ -303,68 +307,12 @@ func AddTicket(c *gin.Context) {
 	eventIdStr := c.Param("id")
 	eventId, err := strconv.Atoi(eventIdStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest,gin.H{
-			"message": utils.ReadRequestError,
-		})
-		return
-	}
-
-	// get the event object
-	var event Event
-	if err = config.DB.First(&event,eventId).Error; err != nil {
-		c.JSON(http.StatusInternalServerError,gin.H{
-			"message": utils.DatabaseCallError,
-		})
-		return
-	}
-
-	//can operate 
-	if err = canOperate(userId,event.UserID); err != nil {
-		c.JSON(http.StatusUnauthorized,gin.H{
-			"message": utils.IncorrecPermission,
-		})
-		return
-	}
-
-	// change the time
-	expTime, err := utils.ValidateDate(ticketSchema.ExpiryTime)
-	if err != nil {
-		c.JSON(http.StatusBadRequest,gin.H{
-			"message": utils.ReadRequestError,
-		})
-		return
-	}
-
-	if expTime.After(event.EventDate) {
-		c.JSON(http.StatusBadRequest,gin.H{
-			"message": utils.TicketTimeError,
-		})
+		apperror.Write(c, apperror.InvalidParam("id"))
 		return
 	}
 
-	// verify the price
-	if !event.IsPaidEvent && ticketSchema.Price > 0{
-		c.JSON(http.StatusBadRequest,gin.H{
-			"message":utils.PriceError,
-		})
-		return
-	}
-
-	// create the ticket object 
-	ticket := Ticket{
-		Name: ticketSchema.Name,
-		Price: ticketSchema.Price,
-		TotalAvailable: ticketSchema.TotalAvailable,
-		SingleLimit: ticketSchema.SingleLimit,
-		ExpiryTime: expTime,
-		EventID: event.ID,
-	}
-
-	// save the model
-	if err = config.DB.Create(&ticket).Error; err != nil {
-		c.JSON(http.StatusInternalServerError,gin.H{
-			"message": utils.CreateRecordError,
-		})
+	if _, err = h.tickets.Add(actor, uint(eventId), ticketSchema); err != nil {
+		apperror.Write(c, err)
 		return
 	}
 
@@ -374,22 +322,18 @@ func AddTicket(c *gin.Context) {
 	})
 }
 
-func UpdateTicket(c *gin.Context) {
+func (h *Handler) UpdateTicket(c *gin.Context) {
 	// bind the data
 	var updateSchema UpdateTicketSchema
 	if err := c.ShouldBind(&updateSchema); err != nil {
-		c.JSON(http.StatusBadRequest,gin.H{
-			"message": utils.ReadRequestError,
-		})
+		apperror.Write(c, apperror.FromBinding(err))
 		return
 	}
 
 	// get the user id 
-	userId, err := getUserId(c)
+	actor, err := getActor(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": utils.ValidateTokenError,
-		})
+		apperror.Write(c, apperror.ErrUnauthenticated)
 		return
 	}
 
@@ -397,52 +341,12 @@ func UpdateTicket(c *gin.Context) {
 	ticketIdStr := c.Param("id")
 	ticketId, err := strconv.Atoi(ticketIdStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest,gin.H{
-			"message": utils.ReadRequestError,
-		})
-		return
-	}
-
-	//get the ticket
-	var ticket Ticket
-	if err = config.DB.First(&ticket,ticketId).Error; err != nil {
-		c.JSON(http.StatusInternalServerError,gin.H{
-			"message": utils.DatabaseCallError,
-		})
-		return
-	}
-
-	// get the event id
-	var event Event
-	if err = config.DB.First(&event,ticket.EventID).Error; err != nil {
-							c.JSON(http.StatusInternalServerError,gin.H{
-								"message": utils.DatabaseCallError,
-							})
-							return
-						}
-
-	//compare the event id
-	if err = canOperate(userId, event.UserID); err != nil {
-		c.JSON(http.StatusUnauthorized,gin.H{
-			"message": utils.IncorrecPermission,
-		})
-		return
-	}
-
-	// build the new ticket
-	newTicket, err := getTicketUpdateData(updateSchema,ticket,event.EventDate,event.IsPaidEvent)
-	if err != nil {
-		c.JSON(http.StatusBadRequest,gin.H{
-			"message": utils.ReadRequestError,
-		})
+		apperror.Write(c, apperror.InvalidParam("id"))
 		return
 	}
 
-	// save the model
-	if err = config.DB.Save(&newTicket).Error; err != nil {
-		c.JSON(http.StatusInternalServerError,gin.H{
-			"message": utils.UpdateRecordError,
-		})
+	if _, err = h.tickets.Update(actor, uint(ticketId), updateSchema); err != nil {
+		apperror.Write(c, err)
 		return
 	}
 
@@ -453,22 +357,17 @@ func UpdateTicket(c *gin.Context) {
 
 }
 
-func GetMyEvents(c *gin.Context) {
+func (h *Handler) GetMyEvents(c *gin.Context) {
 	// get the user id
 	userId, err := getUserId(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": utils.ValidateTokenError,
-		})
+		apperror.Write(c, apperror.ErrUnauthenticated)
 		return
 	}
 
-	// query the db
-	var events []Event
-	if err := config.DB.Where("user_id = ?", userId).Order("created_at DESC").Find(&events).Error ; err != nil {
-		c.JSON(http.StatusInternalServerError,gin.H{
-			"message": utils.DatabaseCallError,
-		})
+	events, err := h.events.ListByUser(userId)
+	if err != nil {
+		apperror.Write(c, err)
 		return
 	}
 
@@ -477,137 +376,71 @@ func GetMyEvents(c *gin.Context) {
 	})
 }
 
-func DeleteTicket(c *gin.Context) {
-	// get the ticket id 
-	ticketIdStr := c.Param("id")
-	ticketId, err := strconv.Atoi(ticketIdStr)
+func (h *Handler) GetDashboard(c *gin.Context) {
+	actor, err := getActor(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest,gin.H{
-			"message": utils.ReadRequestError,
-		})
+		apperror.Write(c, apperror.ErrUnauthenticated)
 		return
 	}
 
-	// get the user id
-	userId, err := getUserId(c)
+	dashboard, err := h.events.Dashboard(actor)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": utils.ValidateTokenError,
-		})
-		return
-	}
-
-
-	// get the event id
-	var eventUserId uint
-	if err = config.DB.Table("tickets").
-				Select("events.user_id").
-				Joins("JOIN events ON tickets.event_id = events.id").
-				Where("tickets.id = ?",ticketId).
-				Find(&eventUserId).Error;
-	 err != nil {
-			c.JSON(http.StatusInternalServerError,gin.H{
-				"message": utils.DatabaseCallError,
-			})
-			return
-				}
-
-	//compare the event id
-	if err = canOperate(userId, eventUserId); err != nil {
-		c.JSON(http.StatusUnauthorized,gin.H{
-			"message": utils.IncorrecPermission,
-		})
-		return
-	}
-
-	// // delete the ticket 
-	if err = config.DB.Delete(&Ticket{},ticketId).Error; err != nil {
-		c.JSON(http.StatusInternalServerError,gin.H{
-			"message": utils.DeleteRecordError,
-		})
+		apperror.Write(c, err)
 		return
 	}
 
-	//return success message
-	c.JSON(http.StatusOK, gin.H{
-		"message": utils.DeleteRecordSuccess,
+	c.JSON(http.StatusOK,gin.H{
+		"events": dashboard,
 	})
 }
 
-func DeleteEvent(c *gin.Context) {
-	// get the event id 
-	eventIdStr := c.Param("id")
-	eventId, err := strconv.Atoi(eventIdStr)
+func (h *Handler) DeleteTicket(c *gin.Context) {
+	// get the ticket id 
+	ticketIdStr := c.Param("id")
+	ticketId, err := strconv.Atoi(ticketIdStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest,gin.H{
-			"message": utils.ReadRequestError,
-		})
+		apperror.Write(c, apperror.InvalidParam("id"))
 		return
 	}
 
 	// get the user id
-	userId, err := getUserId(c)
+	actor, err := getActor(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": utils.ValidateTokenError,
-		})
-		return
-	}
-
-	// verify the userId
-	var event Event
-	if err = config.DB.First(&event, eventId).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": utils.DatabaseCallError,
-		})
-		return
-	}
-
-	// verify if the user has permission
-	if err = canOperate(userId, event.UserID); err != nil {
-		c.JSON(http.StatusUnauthorized,gin.H{
-			"message": utils.IncorrecPermission,
-		})
+		apperror.Write(c, apperror.ErrUnauthenticated)
 		return
 	}
 
-	// delete all related tickets
-	if err = config.DB.Where("event_id = ?",event.ID).Delete(&Ticket{}).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": utils.DeleteRecordError,
-		})
-		return
-	}
-	// delete all events 
-	if err = config.DB.Delete(&Event{},event.ID).Error;err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": utils.DeleteRecordError,
-		})
+	if err = h.tickets.Delete(actor, uint(ticketId)); err != nil {
+		apperror.Write(c, err)
 		return
 	}
 
 	//return success message
 	c.JSON(http.StatusOK, gin.H{
-			"message": utils.DeleteRecordSuccess,
-		})
+		"message": utils.DeleteRecordSuccess,
+	})
+}
+
+// DeleteEvent cancels the event rather than removing it, so its attendees
+// are refunded and told and the record stays for the organiser's report.
+func (h *Handler) DeleteEvent(c *gin.Context) {
+	h.writeCancellation(c, func(actor Actor, eventId uint) (CancellationReport, error) {
+		return h.events.Cancel(actor, eventId, CancelEventSchema{})
+	})
 }
 
-func BuyTicket(c * gin.Context) {
+func (h *Handler) BuyTicket(c *gin.Context) {
 	// get the user id
 	userId, err := getUserId(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": utils.ValidateTokenError,
-		})
+		apperror.Write(c, apperror.ErrUnauthenticated)
 		return
 	}
 
 	// bind the request
 	var ticketSchema BuyTicketScema
-	if err = c.Bind(&ticketSchema); err != nil {
-		c.JSON(http.StatusBadRequest,gin.H{
-			"message": utils.ReadRequestError,
-		})
+	if err = c.ShouldBind(&ticketSchema); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
 		return
 	}
 
@@ -615,187 +448,67 @@ func BuyTicket(c * gin.Context) {
 	ticketIdStr := c.Param("id")
 	ticketId, err := strconv.Atoi(ticketIdStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest,gin.H{
-			"message": utils.ReadRequestError,
-		})
-		return
-	}
-
-	var ticket Ticket
-	if err = config.DB.First(&ticket,ticketId).Error; err != nil {
-		c.JSON(http.StatusInternalServerError,gin.H{
-			"message": utils.DatabaseCallError,
-		})
-		return
-	}
-
-	// verify the ticket units 
-	if ticketSchema.Units > ticket.SingleLimit {
-		c.JSON(http.StatusBadRequest,gin.H{
-			"message": utils.TicketAmountError,
-		})
+		apperror.Write(c, apperror.InvalidParam("id"))
 		return
 	}
 
-	// verify if the ticket is still valid
-	if time.Now().After(ticket.ExpiryTime) {
-		c.JSON(http.StatusBadRequest,gin.H{
-			"message": utils.TicketExpiredError,
-		})
-		return
-	}
-	
-	var attendeeID uint
-	if err = config.DB.Table("attendees").Select("id").
-					Where("user_id = ? AND ticket_id = ?",userId,ticketId).Scan(&attendeeID).Error;
-					err != nil {
-						c.JSON(http.StatusInternalServerError,gin.H{
-							"message": utils.DatabaseCallError,
-						})
-						return
-					}
-	if attendeeID != 0 {
-		c.JSON(http.StatusInternalServerError,gin.H{
-			"message": utils.ExistingDataError,
-		})
-		return
-	}
-	
-	attendee := Attendee{
-			UserID: userId,
-			Units: ticketSchema.Units,
-			TicketID: ticket.ID,
-		}
-
-	if ticket.Price == 0 {		// free ticket case
-		// save the atendee model
-		if err = config.DB.Create(&attendee).Error; err != nil {
-			c.JSON(http.StatusInternalServerError,gin.H{
-				"message": utils.CreateRecordError,
-			})
-			return
-		}
-
-	} else if ticket.Price >0 {
-		// case for paid ticket
-	} else {
-		// bad request
-		c.JSON(http.StatusBadRequest,gin.H{
-			"message":utils.ReadRequestError,
-		})
+	purchase, err := h.tickets.Buy(userId, uint(ticketId), ticketSchema.Units)
+	if err != nil {
+		apperror.Write(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK,gin.H{
-		"message": utils.CreateRecordSuccess,
-	})
+	writePurchase(c, purchase)
 }
 
-func GetTotalAttendees(c *gin.Context) {
+func (h *Handler) GetTotalAttendees(c *gin.Context) {
 	// get the event id 
 	eventIdStr := c.Param("id")
 	eventId, err := strconv.Atoi(eventIdStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest,gin.H{
-			"message": utils.ReadRequestError,
-		})
+		apperror.Write(c, apperror.InvalidParam("id"))
 		return
 	}
 
 	// get the user id 
-	userId, err := getUserId(c)
+	actor, err := getActor(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": utils.ValidateTokenError,
-		})
+		apperror.Write(c, apperror.ErrUnauthenticated)
 		return
 	}
 
-	// get the event id
-	var event Event
-	if err = config.DB.First(&event,eventId).Error; err != nil {
-							c.JSON(http.StatusInternalServerError,gin.H{
-								"message": utils.DatabaseCallError,
-							})
-							return
-						}
-
-	//compare the event id
-	if err = canOperate(userId, event.UserID); err != nil {
-		c.JSON(http.StatusUnauthorized,gin.H{
-			"message": utils.IncorrecPermission,
-		})
+	attendees, err := h.events.Attendees(actor, uint(eventId))
+	if err != nil {
+		apperror.Write(c, err)
 		return
 	}
 
-	// get all tickets using the id 
-	var attendees []GetAllAttendees
-	query := config.DB.Table("attendees").
-					Joins("LEFT JOIN tickets ON tickets.id = attendees.ticket_id").
-					Joins("LEFT JOIN events ON events.id = tickets.event_id").
-					Joins("LEFT JOIN users ON users.id = attendees.user_id").
-					Select("users.email AS email,tickets.name AS ticket_type, attendees.units AS amount").
-					Where("events.id = ?", eventId).Scan(&attendees)
-	
-	
-	 if err = query.Error; err != nil {
-		c.JSON(http.StatusInternalServerError,gin.H{
-			"message": utils.DatabaseCallError,
-		})
-		return
-	 }
-	
-
 	 // return success
 	c.JSON(http.StatusOK,gin.H{
 		"attendees": attendees,
-		"attendeeCount":query.RowsAffected,
+		"attendeeCount": len(attendees),
 	})
 }
 
-func GetAllReviews(c *gin.Context) {
+func (h *Handler) GetAllReviews(c *gin.Context) {
 	// get the event id 
 	eventIdStr := c.Param("id")
 	eventId, err := strconv.Atoi(eventIdStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest,gin.H{
-			"message": utils.ReadRequestError,
-		})
-		return
-	}
-	// verify if the event has held
-	var eventHeld time.Time 
-	var reviews []GetAllReviewSchema
-	err = config.DB.Table("events").Select("event_date").Where("id = ?",eventId).Scan(&eventHeld).Error
-	if err != nil {
-		c.JSON(http.StatusInternalServerError,gin.H{
-			"message": utils.DatabaseCallError,
-		})
+		apperror.Write(c, apperror.InvalidParam("id"))
 		return
 	}
 
-	// return empty string for 
-	if !time.Now().After(eventHeld) {
+	reviews, err := h.events.Reviews(getViewer(c), uint(eventId))
+	if errors.Is(err, ErrEventNotHeld) {
 		c.JSON(http.StatusOK,gin.H{
 			"reviews": reviews,
 			"message": utils.EventHeldError,
 		})
 		return
 	}
-
-	// use the string
-	err = config.DB.Table("attendees").
-					Joins("LEFT JOIN tickets ON tickets.id = attendees.ticket_id").
-					Joins("LEFT JOIN events ON events.id = tickets.event_id").
-					Joins("LEFT JOIN users ON users.id = attendees.user_id").
-					Select("users.first_name, users.last_name, attendees.rating, attendees.review").
-					Where("events.id = ?", eventId).
-					Scan(&reviews).
-					Error
 	if err != nil {
-		c.JSON(http.StatusInternalServerError,gin.H{
-			"message": utils.DatabaseCallError,
-		})
+		apperror.Write(c, err)
 		return
 	}
 
@@ -805,149 +518,768 @@ func GetAllReviews(c *gin.Context) {
 	
 }
 
-func VerifyAttendance(c * gin.Context) {
-
-}
-
-func ReviewEvent(c * gin.Context) {
-	
-}
-
-
-
-
-// internal functions
-func createTicket(ticket TicketSchema, eventId uint, tx *gorm.DB) error{
-	//validate the date 
-	date, err := utils.ValidateDate(ticket.ExpiryTime)
+func (h *Handler) ConfirmOrder(c *gin.Context) {
+	// get the user id
+	userId, err := getUserId(c)
 	if err != nil {
-		return errors.New("incorrect date time format")
-	}
-	// create the ticket model
-	ticketModel := Ticket{
-		Name: ticket.Name,
-		Price: ticket.Price,
-		TotalAvailable: ticket.TotalAvailable,
-		SingleLimit: ticket.SingleLimit,
-		ExpiryTime: date,
-		EventID: eventId,
+		apperror.Write(c, apperror.ErrUnauthenticated)
+		return
 	}
 
-	if err = tx.Create(&ticketModel).Error; err != nil {
-		return err
+	// get the order id
+	orderIdStr := c.Param("id")
+	orderId, err := strconv.Atoi(orderIdStr)
+	if err != nil {
+		apperror.Write(c, apperror.InvalidParam("id"))
+		return
 	}
 
-	return nil
+	order, err := h.tickets.ConfirmOrder(userId, uint(orderId))
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	if order.Status != payments.OrderPaid {
+		apperror.Write(c, ErrPaymentFailed)
+		return
+	}
+
+	c.JSON(http.StatusOK,gin.H{
+		"message": utils.OperationSucess,
+		"status": order.Status,
+	})
 }
 
-func getUserId(c *gin.Context) (uint, error){
-	//get the auth token 
-	userIdStr, exist := c.Get("userID")
-	if !exist {
-		return 0, errors.New("wrong user Id")
+func (h *Handler) PaymentWebhook(c *gin.Context) {
+	// read the raw body, the signature is computed over it
+	payload, err := c.GetRawData()
+	if err != nil {
+		apperror.Write(c, apperror.ErrInvalidRequest)
+		return
 	}
 
-	userId, ok := userIdStr.(uint)
-	if !ok {
-		return 0, errors.New("wrong user Id")
+	// events we do not handle are acknowledged so the provider stops retrying
+	if err = h.tickets.HandleWebhook(payload, c.GetHeader(h.tickets.SignatureHeader())); err != nil {
+		apperror.Write(c, err)
+		return
 	}
-	return userId, nil
+
+	c.JSON(http.StatusOK,gin.H{
+		"message": utils.OperationSucess,
+	})
+}
+
+func (h *Handler) HoldTicket(c *gin.Context) {
+	// get the user id
+	userId, err := getUserId(c)
+	if err != nil {
+		apperror.Write(c, apperror.ErrUnauthenticated)
+		return
+	}
+
+	// bind the request
+	var holdSchema BuyTicketScema
+	if err = c.ShouldBind(&holdSchema); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
+		return
+	}
+
+	// get the ticket id
+	ticketIdStr := c.Param("id")
+	ticketId, err := strconv.Atoi(ticketIdStr)
+	if err != nil {
+		apperror.Write(c, apperror.InvalidParam("id"))
+		return
+	}
+
+	reservation, err := h.tickets.Hold(userId, uint(ticketId), holdSchema.Units)
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated,gin.H{
+		"message": utils.CreateRecordSuccess,
+		"reservation": reservation,
+	})
+}
+
+func (h *Handler) ExtendHold(c *gin.Context) {
+	// get the user id
+	userId, err := getUserId(c)
+	if err != nil {
+		apperror.Write(c, apperror.ErrUnauthenticated)
+		return
+	}
+
+	// get the reservation id
+	reservationIdStr := c.Param("id")
+	reservationId, err := strconv.Atoi(reservationIdStr)
+	if err != nil {
+		apperror.Write(c, apperror.InvalidParam("id"))
+		return
+	}
+
+	reservation, err := h.tickets.ExtendHold(userId, uint(reservationId))
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK,gin.H{
+		"message": utils.UpdateRecordSuccess,
+		"reservation": reservation,
+	})
+}
+
+func (h *Handler) CancelHold(c *gin.Context) {
+	// get the user id
+	userId, err := getUserId(c)
+	if err != nil {
+		apperror.Write(c, apperror.ErrUnauthenticated)
+		return
+	}
+
+	// get the reservation id
+	reservationIdStr := c.Param("id")
+	reservationId, err := strconv.Atoi(reservationIdStr)
+	if err != nil {
+		apperror.Write(c, apperror.InvalidParam("id"))
+		return
+	}
+
+	if err = h.tickets.CancelHold(userId, uint(reservationId)); err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK,gin.H{
+		"message": utils.OperationSucess,
+	})
+}
+
+func (h *Handler) CheckoutHold(c *gin.Context) {
+	// get the user id
+	userId, err := getUserId(c)
+	if err != nil {
+		apperror.Write(c, apperror.ErrUnauthenticated)
+		return
+	}
+
+	// get the reservation id
+	reservationIdStr := c.Param("id")
+	reservationId, err := strconv.Atoi(reservationIdStr)
+	if err != nil {
+		apperror.Write(c, apperror.InvalidParam("id"))
+		return
+	}
+
+	purchase, err := h.tickets.CheckoutHold(userId, uint(reservationId))
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	writePurchase(c, purchase)
+}
+
+func (h *Handler) GetEventMembers(c *gin.Context) {
+	// get the event id
+	eventIdStr := c.Param("id")
+	eventId, err := strconv.Atoi(eventIdStr)
+	if err != nil {
+		apperror.Write(c, apperror.InvalidParam("id"))
+		return
+	}
+
+	// get the user id
+	actor, err := getActor(c)
+	if err != nil {
+		apperror.Write(c, apperror.ErrUnauthenticated)
+		return
+	}
+
+	members, err := h.events.Members(actor, uint(eventId))
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK,gin.H{
+		"members": members,
+	})
+}
+
+func (h *Handler) InviteMember(c *gin.Context) {
+	// bind the request
+	var inviteSchema InviteMemberSchema
+	if err := c.ShouldBind(&inviteSchema); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
+		return
+	}
+
+	// get the event id
+	eventIdStr := c.Param("id")
+	eventId, err := strconv.Atoi(eventIdStr)
+	if err != nil {
+		apperror.Write(c, apperror.InvalidParam("id"))
+		return
+	}
+
+	// get the user id
+	actor, err := getActor(c)
+	if err != nil {
+		apperror.Write(c, apperror.ErrUnauthenticated)
+		return
+	}
+
+	member, err := h.events.InviteMember(actor, uint(eventId), inviteSchema)
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated,gin.H{
+		"message": utils.CreateRecordSuccess,
+		"member": member,
+	})
+}
+
+func (h *Handler) AcceptInvite(c *gin.Context) {
+	// get the event id
+	eventIdStr := c.Param("id")
+	eventId, err := strconv.Atoi(eventIdStr)
+	if err != nil {
+		apperror.Write(c, apperror.InvalidParam("id"))
+		return
+	}
+
+	// get the user id
+	actor, err := getActor(c)
+	if err != nil {
+		apperror.Write(c, apperror.ErrUnauthenticated)
+		return
+	}
+
+	member, err := h.events.AcceptInvite(actor, uint(eventId))
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK,gin.H{
+		"message": utils.OperationSucess,
+		"member": member,
+	})
+}
+
+func (h *Handler) RemoveMember(c *gin.Context) {
+	// get the event and member ids
+	eventId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apperror.Write(c, apperror.InvalidParam("id"))
+		return
+	}
+
+	memberId, err := strconv.Atoi(c.Param("memberId"))
+	if err != nil {
+		apperror.Write(c, apperror.InvalidParam("memberId"))
+		return
+	}
+
+	// get the user id
+	actor, err := getActor(c)
+	if err != nil {
+		apperror.Write(c, apperror.ErrUnauthenticated)
+		return
+	}
+
+	if err = h.events.RemoveMember(actor, uint(eventId), uint(memberId)); err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK,gin.H{
+		"message": utils.DeleteRecordSuccess,
+	})
+}
+
+func (h *Handler) VerifyAttendance(c *gin.Context) {
+	// get the user id
+	actor, err := getActor(c)
+	if err != nil {
+		apperror.Write(c, apperror.ErrUnauthenticated)
+		return
+	}
+
+	// bind the scan
+	var checkInSchema CheckInSchema
+	if err := c.ShouldBind(&checkInSchema); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
+		return
+	}
+
+	// get the event id
+	eventIdStr := c.Param("id")
+	eventId, err := strconv.Atoi(eventIdStr)
+	if err != nil {
+		apperror.Write(c, apperror.InvalidParam("id"))
+		return
+	}
+
+	checkIn, err := h.events.CheckIn(actor, uint(eventId), checkInSchema)
+	if errors.Is(err, ErrAlreadyCheckedIn) {
+		// the door needs to see when the ticket was already used
+		apperror.WriteWith(c, err, gin.H{
+			"checkIn": checkIn,
+		})
+		return
+	}
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK,gin.H{
+		"message": utils.OperationSucess,
+		"checkIn": checkIn,
+	})
+}
+
+func (h *Handler) GetMyTickets(c *gin.Context) {
+	// get the user id
+	actor, err := getActor(c)
+	if err != nil {
+		apperror.Write(c, apperror.ErrUnauthenticated)
+		return
+	}
+
+	tickets, err := h.events.MyTickets(actor)
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK,gin.H{
+		"tickets": tickets,
+	})
+}
+
+func (h *Handler) GetTicketQR(c *gin.Context) {
+	// get the user id
+	actor, err := getActor(c)
+	if err != nil {
+		apperror.Write(c, apperror.ErrUnauthenticated)
+		return
+	}
+
+	// bind the image options
+	var qrSchema TicketQRSchema
+	if err := c.ShouldBindQuery(&qrSchema); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
+		return
+	}
+
+	image, contentType, err := h.events.TicketQR(actor, c.Param("serial"), qrSchema)
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	// a revoked code must not linger in caches
+	c.Header("Cache-Control", "private, no-store")
+	c.Data(http.StatusOK, contentType, image)
+}
+
+func (h *Handler) TransferTicket(c *gin.Context) {
+	// get the user id
+	actor, err := getActor(c)
+	if err != nil {
+		apperror.Write(c, apperror.ErrUnauthenticated)
+		return
+	}
+
+	// bind the recipient
+	var transferSchema TransferTicketSchema
+	if err := c.ShouldBind(&transferSchema); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
+		return
+	}
+
+	// get the attendee id
+	attendeeIdStr := c.Param("id")
+	attendeeId, err := strconv.Atoi(attendeeIdStr)
+	if err != nil {
+		apperror.Write(c, apperror.InvalidParam("id"))
+		return
+	}
+
+	attendee, err := h.tickets.Transfer(actor, uint(attendeeId), transferSchema)
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK,gin.H{
+		"message": utils.OperationSucess,
+		"attendeeId": attendee.ID,
+	})
+}
+
+func (h *Handler) GetCheckIns(c *gin.Context) {
+	// get the user id
+	actor, err := getActor(c)
+	if err != nil {
+		apperror.Write(c, apperror.ErrUnauthenticated)
+		return
+	}
+
+	// get the event id
+	eventIdStr := c.Param("id")
+	eventId, err := strconv.Atoi(eventIdStr)
+	if err != nil {
+		apperror.Write(c, apperror.InvalidParam("id"))
+		return
+	}
+
+	checkIns, err := h.events.CheckIns(actor, uint(eventId))
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK,gin.H{
+		"checkIns": checkIns,
+		"checkInCount": len(checkIns),
+	})
+}
+
+func (h *Handler) GetCheckInManifest(c *gin.Context) {
+	// get the user id
+	actor, err := getActor(c)
+	if err != nil {
+		apperror.Write(c, apperror.ErrUnauthenticated)
+		return
+	}
+
+	// get the event id
+	eventIdStr := c.Param("id")
+	eventId, err := strconv.Atoi(eventIdStr)
+	if err != nil {
+		apperror.Write(c, apperror.InvalidParam("id"))
+		return
+	}
+
+	manifest, err := h.events.CheckInManifest(actor, uint(eventId))
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK,gin.H{
+		"manifest": manifest,
+	})
+}
+
+func (h *Handler) SyncCheckIns(c *gin.Context) {
+	// get the user id
+	actor, err := getActor(c)
+	if err != nil {
+		apperror.Write(c, apperror.ErrUnauthenticated)
+		return
+	}
+
+	// bind the batch of offline scans
+	var syncSchema SyncCheckInsSchema
+	if err := c.ShouldBind(&syncSchema); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
+		return
+	}
+
+	// get the event id
+	eventIdStr := c.Param("id")
+	eventId, err := strconv.Atoi(eventIdStr)
+	if err != nil {
+		apperror.Write(c, apperror.InvalidParam("id"))
+		return
+	}
+
+	sync, err := h.events.SyncCheckIns(actor, uint(eventId), syncSchema)
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK,gin.H{
+		"message": utils.OperationSucess,
+		"sync": sync,
+	})
+}
+
+func (h *Handler) ReviewEvent(c *gin.Context) {
+	h.writeReview(c, http.StatusCreated, h.events.Review)
+}
+
+func (h *Handler) EditReview(c *gin.Context) {
+	h.writeReview(c, http.StatusOK, h.events.EditReview)
+}
+
+// writeReview binds a review of the event in the path, saves it with the
+// given action and renders it.
+func (h *Handler) writeReview(c *gin.Context, status int, action func(actor Actor, eventId uint, schema ReviewSchema) (GetAllReviewSchema, error)) {
+	// get the user id
+	actor, err := getActor(c)
+	if err != nil {
+		apperror.Write(c, apperror.ErrUnauthenticated)
+		return
+	}
+
+	// bind the review
+	var reviewSchema ReviewSchema
+	if err := c.ShouldBind(&reviewSchema); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
+		return
+	}
+
+	// get the event id
+	eventIdStr := c.Param("id")
+	eventId, err := strconv.Atoi(eventIdStr)
+	if err != nil {
+		apperror.Write(c, apperror.InvalidParam("id"))
+		return
+	}
+
+	review, err := action(actor, uint(eventId), reviewSchema)
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	message := utils.UpdateRecordSuccess
+	if status == http.StatusCreated {
+		message = utils.CreateRecordSuccess
+	}
+	c.JSON(status,gin.H{
+		"message": message,
+		"review": review,
+	})
 }
 
-func canOperate(userId, jobUserId uint) error{
-	if userId != jobUserId {
-		return errors.New("incorrect permission")
+func (h *Handler) GetReviewStats(c *gin.Context) {
+	// get the event id
+	eventIdStr := c.Param("id")
+	eventId, err := strconv.Atoi(eventIdStr)
+	if err != nil {
+		apperror.Write(c, apperror.InvalidParam("id"))
+		return
+	}
+
+	stats, err := h.events.EventRatingStats(getViewer(c), uint(eventId))
+	if err != nil {
+		apperror.Write(c, err)
+		return
 	}
-	return nil
+
+	c.JSON(http.StatusOK,gin.H{
+		"stats": stats,
+	})
 }
 
-func getEventUpdateData(updateData UpdateEventSchema, event Event) (Event,error) {
-	if updateData.Name != "" {
-		event.Name = updateData.Name
+func (h *Handler) GetOrganiserReviewStats(c *gin.Context) {
+	// get the organiser id
+	organiserIdStr := c.Param("id")
+	organiserId, err := strconv.Atoi(organiserIdStr)
+	if err != nil {
+		apperror.Write(c, apperror.InvalidParam("id"))
+		return
 	}
 
-	if updateData.Location != "" {
-		event.Location = updateData.Location
+	stats, err := h.events.OrganiserRatingStats(uint(organiserId))
+	if err != nil {
+		apperror.Write(c, err)
+		return
 	}
 
-	if updateData.Organiser != "" {
-		event.Organiser = updateData.Organiser
+	c.JSON(http.StatusOK,gin.H{
+		"stats": stats,
+	})
+}
+
+func (h *Handler) FlagReview(c *gin.Context) {
+	// get the user id
+	actor, err := getActor(c)
+	if err != nil {
+		apperror.Write(c, apperror.ErrUnauthenticated)
+		return
 	}
 
-	if updateData.IsPaidEvent != nil {
-		isPaidEvent := utils.BoolValue(updateData.IsPaidEvent, false)
-		event.IsPaidEvent = isPaidEvent
+	// bind the flag
+	var flagSchema FlagReviewSchema
+	if err := c.ShouldBind(&flagSchema); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
+		return
 	}
 
-	if updateData.IsLimitedEvent != nil{
-		isLimitedEvent := utils.BoolValue(updateData.IsLimitedEvent, false)
-		event.IsLimited = isLimitedEvent
+	// get the review id
+	reviewIdStr := c.Param("id")
+	reviewId, err := strconv.Atoi(reviewIdStr)
+	if err != nil {
+		apperror.Write(c, apperror.InvalidParam("id"))
+		return
 	}
 
-	if updateData.Description != "" {
-		event.Description = updateData.Description
+	flag, err := h.events.Flag(actor, uint(reviewId), flagSchema)
+	if err != nil {
+		apperror.Write(c, err)
+		return
 	}
 
-	if updateData.MaxUnitReservation != nil {
-		maxUnitReservation := utils.UintValue(updateData.MaxUnitReservation,1)
-		event.MaxUnitReservation = maxUnitReservation
+	c.JSON(http.StatusCreated,gin.H{
+		"message": utils.CreateRecordSuccess,
+		"flag": flag,
+	})
+}
+
+func (h *Handler) ReplyToReview(c *gin.Context) {
+	// get the user id
+	actor, err := getActor(c)
+	if err != nil {
+		apperror.Write(c, apperror.ErrUnauthenticated)
+		return
 	}
 
-	if updateData.EventDate != "" {
-		eventDate, err := utils.ValidateDate(updateData.EventDate)
-		if err != nil {
-			return Event{}, errors.New("invalid date")
-		}
-		event.EventDate = eventDate
+	// bind the reply
+	var replySchema ReplyReviewSchema
+	if err := c.ShouldBind(&replySchema); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
+		return
 	}
 
-	if updateData.RegistrationExpirationDate != "" {
-		regDate, err := utils.ValidateDate(updateData.RegistrationExpirationDate)
-		if err != nil {
-			return Event{}, errors.New("invalid date")
-		}
-		event.EventDate = regDate
+	// get the review id
+	reviewIdStr := c.Param("id")
+	reviewId, err := strconv.Atoi(reviewIdStr)
+	if err != nil {
+		apperror.Write(c, apperror.InvalidParam("id"))
+		return
 	}
 
-	return event, nil 
+	review, err := h.events.Reply(actor, uint(reviewId), replySchema)
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK,gin.H{
+		"message": utils.UpdateRecordSuccess,
+		"review": review,
+	})
 }
 
-func getTicketUpdateData(updateData UpdateTicketSchema, ticket Ticket, eventDate time.Time, isPaid bool) (Ticket, error) {
-	if updateData.Name != "" {
-		ticket.Name = updateData.Name
+func (h *Handler) GetModerationQueue(c *gin.Context) {
+	queue, err := h.events.ModerationQueue()
+	if err != nil {
+		apperror.Write(c, err)
+		return
 	}
 
-	if updateData.Price != nil {
-		actualPrice := utils.FloatValue(updateData.Price, 0 )
-		if !isPaid && actualPrice > 0 {
-			return Ticket{}, errors.New("the event is free")
-		}
-		ticket.Price = actualPrice
+	c.JSON(http.StatusOK,gin.H{
+		"reviews": queue,
+		"reviewCount": len(queue),
+	})
+}
+
+func (h *Handler) ModerateReview(c *gin.Context) {
+	// get the user id
+	actor, err := getActor(c)
+	if err != nil {
+		apperror.Write(c, apperror.ErrUnauthenticated)
+		return
 	}
 
-	if updateData.TotalAvailable != nil {
-		actualAvailable := utils.UintValue(updateData.TotalAvailable,0)
-		ticket.TotalAvailable = actualAvailable
+	// bind the decision
+	var moderateSchema ModerateReviewSchema
+	if err := c.ShouldBind(&moderateSchema); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
+		return
 	}
 
-	if updateData.SingleLimit != nil {
-		actualLimit := utils.UintValue(updateData.SingleLimit,0)
-		ticket.TotalAvailable = actualLimit
+	// get the review id
+	reviewIdStr := c.Param("id")
+	reviewId, err := strconv.Atoi(reviewIdStr)
+	if err != nil {
+		apperror.Write(c, apperror.InvalidParam("id"))
+		return
 	}
 
-	if updateData.ExpiryTime != "" {
-		expTime, err := utils.ValidateDate(updateData.ExpiryTime)
-		if err != nil {
-			return Ticket{}, errors.New("wrong time")
-		}
-		if expTime.After(eventDate) {
-			return Ticket{},errors.New("wrong time")
-		}
+	review, err := h.events.Moderate(actor, uint(reviewId), moderateSchema)
+	if err != nil {
+		apperror.Write(c, err)
+		return
 	}
 
-	return ticket, nil
+	c.JSON(http.StatusOK,gin.H{
+		"message": utils.UpdateRecordSuccess,
+		"review": review,
+	})
 }
 
 
 
 
+// internal functions
+func getUserId(c *gin.Context) (uint, error){
+	//get the auth token 
+	userIdStr, exist := c.Get("userID")
+	if !exist {
+		return 0, errors.New("wrong user Id")
+	}
+
+	userId, ok := userIdStr.(uint)
+	if !ok {
+		return 0, errors.New("wrong user Id")
+	}
+	return userId, nil
+}
+
+// getActor returns the authenticated user along with their platform role.
+func getActor(c *gin.Context) (Actor, error) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return Actor{}, err
+	}
+
+	role, _ := c.Get("userRole")
+	roleString, _ := role.(string)
+	return Actor{ID: userId, Role: roleString}, nil
+}
+
+// getViewer returns the signed in user on routes where signing in is
+// optional, or a zero Actor for an anonymous request.
+func getViewer(c *gin.Context) Actor {
+	actor, err := getActor(c)
+	if err != nil {
+		return Actor{}
+	}
+	return actor
+}
+
+// writePurchase renders a purchase. Paid tickets return the order to pay,
+// the attendee is only created once the payment succeeds.
+func writePurchase(c *gin.Context, purchase Purchase) {
+	if purchase.Order != nil {
+		c.JSON(http.StatusCreated,gin.H{
+			"message": utils.CreateRecordSuccess,
+			"orderId": purchase.Order.ID,
+			"amount": purchase.Order.Amount,
+			"currency": purchase.Order.Currency,
+			"paymentReference": purchase.Intent.ID,
+			"clientSecret": purchase.Intent.ClientSecret,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK,gin.H{
+		"message": utils.CreateRecordSuccess,
+	})
+}
@@ -0,0 +1,191 @@
+package events
+
+import (
+	"avana/internal/payments"
+	"sort"
+	"time"
+)
+
+// TicketStats is how one ticket type of an event is selling.
+type TicketStats struct {
+	TicketID uint
+	Name string
+	Price float64
+	// Sold counts the units of active purchases, paid or free
+	Sold uint
+	// Held counts the units in holds and unpaid checkouts, not yet sold
+	Held uint
+	// Remaining is nil for a ticket without a limit, it still includes Held
+	Remaining *uint
+	Revenue float64
+	SalesEnd time.Time
+}
+
+// Deadline is an upcoming date the organiser has to plan around.
+type Deadline struct {
+	Name string
+	Date time.Time
+}
+
+// EventDashboard summarises one event for its organiser.
+type EventDashboard struct {
+	EventID uint
+	Name string
+	Status string
+	EventDate time.Time
+	TicketsSold uint
+	Revenue float64
+	Tickets []TicketStats
+	CheckedIn uint
+	// CheckInRate is the share of sold units checked in, from 0 to 1
+	CheckInRate float64
+	ReviewCount int
+	// AverageRating is nil until the event has a rating
+	AverageRating *float64
+	UpcomingDeadlines []Deadline
+}
+
+// Dashboard summarises every event the actor organises, soonest first.
+func (s *EventService) Dashboard(actor Actor) ([]EventDashboard, error) {
+	events, err := s.store.Events().ListByUser(actor.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	dashboards := make([]EventDashboard, 0, len(events))
+	for _, event := range events {
+		dashboard, err := s.eventDashboard(event, now)
+		if err != nil {
+			return nil, err
+		}
+		dashboards = append(dashboards, dashboard)
+	}
+
+	sort.SliceStable(dashboards, func(i, j int) bool {
+		return dashboards[i].EventDate.Before(dashboards[j].EventDate)
+	})
+	return dashboards, nil
+}
+
+func (s *EventService) eventDashboard(event Event, now time.Time) (EventDashboard, error) {
+	tickets, err := s.store.Tickets().ListByEvent(event.ID)
+	if err != nil {
+		return EventDashboard{}, err
+	}
+
+	attendees, err := eventAttendees(s.store, tickets)
+	if err != nil {
+		return EventDashboard{}, err
+	}
+
+	ticketIds := make([]uint, 0, len(tickets))
+	for _, ticket := range tickets {
+		ticketIds = append(ticketIds, ticket.ID)
+	}
+	orders, err := s.store.Orders().ListByTickets(ticketIds, payments.OrderPaid)
+	if err != nil {
+		return EventDashboard{}, err
+	}
+
+	// units still being bought hold their seats without being sold
+	held := map[uint]uint{}
+	pending, err := s.store.Orders().ListByTickets(ticketIds, payments.OrderPending)
+	if err != nil {
+		return EventDashboard{}, err
+	}
+	for _, order := range pending {
+		held[order.TicketID] += order.Units
+	}
+	reservations, err := s.store.Reservations().ListHeldByTickets(ticketIds)
+	if err != nil {
+		return EventDashboard{}, err
+	}
+	for _, reservation := range reservations {
+		held[reservation.TicketID] += reservation.Units
+	}
+
+	dashboard := EventDashboard{
+		EventID: event.ID,
+		Name: event.Name,
+		Status: event.Status,
+		EventDate: event.EventDate,
+		Tickets: make([]TicketStats, 0, len(tickets)),
+	}
+
+	revenue := map[uint]float64{}
+	for _, order := range orders {
+		revenue[order.TicketID] += order.Amount
+	}
+
+	// ticket.Sold also counts holds and unpaid orders, only purchases that
+	// went through are sold. A paid order always has its attendee.
+	sold := map[uint]uint{}
+	var units uint
+	for _, attendee := range attendees {
+		if attendee.Status != AttendeeActive {
+			continue
+		}
+		sold[attendee.TicketID] += attendee.Units
+		units += attendee.Units
+		dashboard.CheckedIn += attendee.CheckedIn
+	}
+
+	for _, ticket := range tickets {
+		stats := TicketStats{
+			TicketID: ticket.ID,
+			Name: ticket.Name,
+			Price: ticket.Price,
+			Sold: sold[ticket.ID],
+			Held: held[ticket.ID],
+			Revenue: revenue[ticket.ID],
+			SalesEnd: ticket.ExpiryTime,
+		}
+		if ticket.TotalAvailable != nil {
+			var remaining uint
+			if stats.Sold < *ticket.TotalAvailable {
+				remaining = *ticket.TotalAvailable - stats.Sold
+			}
+			stats.Remaining = &remaining
+		}
+		dashboard.Tickets = append(dashboard.Tickets, stats)
+		dashboard.TicketsSold += stats.Sold
+		dashboard.Revenue += stats.Revenue
+
+		if !isClosed(event) && ticket.ExpiryTime.After(now) {
+			dashboard.UpcomingDeadlines = append(dashboard.UpcomingDeadlines, Deadline{
+				Name: ticket.Name + " sales end",
+				Date: ticket.ExpiryTime,
+			})
+		}
+	}
+
+	if units > 0 {
+		dashboard.CheckInRate = float64(dashboard.CheckedIn) / float64(units)
+	}
+
+	ratings, err := s.ratingStats([]uint{event.ID})
+	if err != nil {
+		return EventDashboard{}, err
+	}
+	dashboard.ReviewCount = ratings.Count
+	dashboard.AverageRating = ratings.Average
+
+	if !isClosed(event) && event.RegistrationExpirationDate.After(now) {
+		dashboard.UpcomingDeadlines = append(dashboard.UpcomingDeadlines, Deadline{
+			Name: "Registration closes",
+			Date: event.RegistrationExpirationDate,
+		})
+	}
+	if !isClosed(event) && event.EventDate.After(now) {
+		dashboard.UpcomingDeadlines = append(dashboard.UpcomingDeadlines, Deadline{
+			Name: "Event starts",
+			Date: event.EventDate,
+		})
+	}
+	sort.SliceStable(dashboard.UpcomingDeadlines, func(i, j int) bool {
+		return dashboard.UpcomingDeadlines[i].Date.Before(dashboard.UpcomingDeadlines[j].Date)
+	})
+
+	return dashboard, nil
+}
@@ -0,0 +1,50 @@
+package events
+
+import (
+	"avana/internal/apperror"
+	"avana/internal/utils"
+)
+
+// Domain errors returned by the event and ticket services. Each carries a
+// stable code, so any caller gets the same rules with the same failures.
+var (
+	ErrInvalidDate = apperror.New(apperror.KindInvalid, "invalid_date", "Dates must use the YYYY-MM-DD HH:MM:SS format")
+	ErrRegistrationAfterEvent = apperror.New(apperror.KindInvalid, "registration_after_event", "Registration must close before the event date")
+	ErrTicketAfterEvent = apperror.New(apperror.KindInvalid, "ticket_after_event", utils.TicketTimeError)
+	ErrPriceOnFreeEvent = apperror.New(apperror.KindInvalid, "price_on_free_event", utils.PriceError)
+	ErrInvalidUnits = apperror.New(apperror.KindInvalid, "invalid_units", utils.TicketAmountError)
+	ErrTicketExpired = apperror.New(apperror.KindGone, "ticket_expired", utils.TicketExpiredError)
+	ErrSoldOut = apperror.New(apperror.KindConflict, "ticket_sold_out", utils.TicketSoldOutError)
+	ErrAlreadyAttending = apperror.New(apperror.KindConflict, "already_attending", "The user already holds this ticket")
+	ErrReleaseExceedsSold = apperror.New(apperror.KindInternal, "release_exceeds_sold", "More units were released than the ticket has sold")
+	ErrBelowSold = apperror.New(apperror.KindConflict, "below_sold", "The ticket has already sold more than that")
+	ErrTicketSold = apperror.New(apperror.KindConflict, "ticket_sold", "Tickets that have been sold or are being checked out cannot be deleted")
+	ErrPendingOrder = apperror.New(apperror.KindConflict, "order_pending", "An order for this ticket is already awaiting payment")
+	ErrHoldExpired = apperror.New(apperror.KindGone, "reservation_expired", utils.ReservationExpiredError)
+	ErrHoldActive = apperror.New(apperror.KindConflict, "reservation_active", "The user already holds a reservation for this ticket")
+	ErrNotAttendee = apperror.New(apperror.KindForbidden, "not_an_attendee", "Only attendees of the event can review it")
+	ErrAlreadyReviewed = apperror.New(apperror.KindConflict, "already_reviewed", "The user has already reviewed this event")
+	ErrReviewLocked = apperror.New(apperror.KindConflict, "review_locked", "The review can no longer be edited")
+	ErrOwnReview = apperror.New(apperror.KindForbidden, "own_review", "Users cannot flag their own review")
+	ErrAlreadyFlagged = apperror.New(apperror.KindConflict, "already_flagged", "The user has already flagged this review")
+	ErrEventNotHeld = apperror.New(apperror.KindConflict, "event_not_held", utils.EventHeldError)
+	ErrInvalidTransition = apperror.New(apperror.KindConflict, "invalid_status_transition", "The event cannot move to that status from its current one")
+	ErrNothingToSell = apperror.New(apperror.KindInvalid, "no_sellable_ticket", "The event needs at least one ticket on sale before it is published")
+	ErrSalesClosed = apperror.New(apperror.KindConflict, "sales_closed", "Tickets for this event are not on sale")
+	ErrEventNotCancelled = apperror.New(apperror.KindConflict, "event_not_cancelled", "The event has not been cancelled")
+	ErrEventClosed = apperror.New(apperror.KindConflict, "event_closed", "Cancelled and completed events cannot be changed")
+	ErrUnknownTicketCode = apperror.New(apperror.KindNotFound, "unknown_ticket_code", "The code does not match a ticket for this event")
+	ErrTicketCancelled = apperror.New(apperror.KindGone, "ticket_cancelled", "The ticket has been cancelled")
+	ErrAlreadyCheckedIn = apperror.New(apperror.KindConflict, "already_checked_in", "The code has already been used to check in")
+	ErrTicketRevoked = apperror.New(apperror.KindGone, "ticket_code_revoked", "The ticket code has been revoked")
+	ErrTicketUsed = apperror.New(apperror.KindConflict, "ticket_used", "Tickets that have been checked in cannot be transferred")
+	ErrTransferToSelf = apperror.New(apperror.KindInvalid, "transfer_to_self", "The ticket already belongs to this user")
+	ErrTooManyUnits = apperror.New(apperror.KindConflict, "check_in_exceeds_units", "More units than remain on the ticket")
+	ErrNoPermission = apperror.New(apperror.KindForbidden, "forbidden", utils.IncorrecPermission)
+	ErrPaymentProvider = apperror.New(apperror.KindUpstream, "payment_provider_error", utils.PaymentError)
+	ErrPaymentFailed = apperror.New(apperror.KindPaymentRequired, "payment_failed", utils.PaymentFailedError)
+	ErrInvalidSignature = apperror.New(apperror.KindUnauthenticated, "invalid_signature", utils.WebhookSignatureError)
+	ErrInvalidMemberRole = apperror.New(apperror.KindInvalid, "invalid_member_role", utils.MemberRoleError)
+	ErrAlreadyMember = apperror.New(apperror.KindConflict, "already_member", "The email is already on the event's team")
+	ErrOwnerRemoval = apperror.New(apperror.KindConflict, "owner_removal", utils.OwnerRemovalError)
+)
@@ -0,0 +1,249 @@
+package events
+
+import (
+	"avana/internal/mailer"
+	"avana/internal/repository"
+	"errors"
+	"log"
+	"time"
+)
+
+const (
+	EventDraft string = "draft"
+	EventPublished string = "published"
+	EventPostponed string = "postponed"
+	EventCancelled string = "cancelled"
+	EventCompleted string = "completed"
+)
+
+// eventTransitions lists the statuses an event may move to from each status.
+// Cancelled and completed events are final.
+var eventTransitions = map[string][]string{
+	EventDraft: {EventPublished, EventCancelled},
+	EventPublished: {EventPostponed, EventCancelled, EventCompleted},
+	EventPostponed: {EventPublished, EventCancelled},
+}
+
+// publicStatuses are the statuses anyone may see, drafts are only visible
+// to the event's team.
+var publicStatuses = []string{EventPublished, EventPostponed, EventCancelled, EventCompleted}
+
+func canTransition(from, to string) bool {
+	for _, allowed := range eventTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// isClosed reports whether the event has reached a final status.
+func isClosed(event Event) bool {
+	return event.Status == EventCancelled || event.Status == EventCompleted
+}
+
+// checkOnSale allows sales only while the event is published.
+func checkOnSale(event Event) error {
+	if event.Status != EventPublished {
+		return ErrSalesClosed
+	}
+	return nil
+}
+
+// hasAvailableTicket reports whether any of the tickets can still be bought.
+func hasAvailableTicket(tickets []Ticket, now time.Time) bool {
+	for _, ticket := range tickets {
+		if ticket.ExpiryTime.After(now) && (ticket.TotalAvailable == nil || ticket.Sold < *ticket.TotalAvailable) {
+			return true
+		}
+	}
+	return false
+}
+
+// canView hides drafts from anyone outside the event's team, as if they did
+// not exist.
+func (s *EventService) canView(viewer Actor, event Event) error {
+	if event.Status != EventDraft {
+		return nil
+	}
+	if viewer.ID == 0 {
+		return repository.ErrNotFound
+	}
+	if err := s.Authorize(viewer, event, EventPermView); err != nil {
+		if errors.Is(err, ErrNoPermission) {
+			return repository.ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Publish opens a draft or postponed event for sale. It needs at least one
+// ticket that can still be sold.
+func (s *EventService) Publish(actor Actor, eventId uint) (Event, error) {
+	return s.transition(actor, eventId, EventPublished, EventPermEdit, func(tx Tx, event *Event) error {
+		tickets, err := tx.Tickets().ListByEvent(event.ID)
+		if err != nil {
+			return err
+		}
+		if !hasAvailableTicket(tickets, time.Now()) {
+			return ErrNothingToSell
+		}
+		return nil
+	})
+}
+
+// Postpone stops sales and lets the attendees know. New dates are optional,
+// the event is published again once it has been rescheduled. Every ticket
+// must still close its sales before the new event date.
+func (s *EventService) Postpone(actor Actor, eventId uint, schema PostponeEventSchema) (Event, error) {
+	event, err := s.transition(actor, eventId, EventPostponed, EventPermEdit, func(tx Tx, event *Event) error {
+		event.StatusReason = schema.Reason
+		if schema.EventDate == "" && schema.RegistrationExpirationDate == "" {
+			return nil
+		}
+
+		updated, err := getEventUpdateData(UpdateEventSchema{
+			EventDate: schema.EventDate,
+			RegistrationExpirationDate: schema.RegistrationExpirationDate,
+		}, *event)
+		if err != nil {
+			return err
+		}
+
+		tickets, err := tx.Tickets().ListByEvent(event.ID)
+		if err != nil {
+			return err
+		}
+		for _, ticket := range tickets {
+			if err := checkTicketRules(updated, ticket.Price, ticket.ExpiryTime); err != nil {
+				return err
+			}
+		}
+
+		*event = updated
+		return nil
+	})
+	if err != nil {
+		return Event{}, err
+	}
+
+	attendees, err := s.activeAttendees(event)
+	if err != nil {
+		log.Printf("notifying attendees of event %d: %v", event.ID, err)
+		return event, nil
+	}
+	s.notifyAttendees(event, attendees)
+	return event, nil
+}
+
+// Complete closes a published event once it has been held.
+func (s *EventService) Complete(actor Actor, eventId uint) (Event, error) {
+	return s.transition(actor, eventId, EventCompleted, EventPermEdit, func(tx Tx, event *Event) error {
+		if !time.Now().After(event.EventDate) {
+			return ErrEventNotHeld
+		}
+		return nil
+	})
+}
+
+// transition moves the event to the status when the actor holds the
+// permission and the move is allowed, running check on the event first. The
+// event row stays locked from the check to the save, so two transitions or
+// a transition and a sale cannot interleave.
+func (s *EventService) transition(actor Actor, eventId uint, status string, permission string, check func(tx Tx, event *Event) error) (Event, error) {
+	event, err := s.store.Events().FindByID(eventId)
+	if err != nil {
+		return Event{}, err
+	}
+
+	if err = s.Authorize(actor, event, permission); err != nil {
+		return Event{}, err
+	}
+
+	err = withTransaction(s.store, func(tx Tx) error {
+		var err error
+		event, err = tx.Events().FindByIDForUpdate(eventId)
+		if err != nil {
+			return err
+		}
+
+		if !canTransition(event.Status, status) {
+			return ErrInvalidTransition
+		}
+
+		if err = check(tx, &event); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		event.Status = status
+		event.StatusChangedAt = &now
+		return tx.Events().Save(&event)
+	})
+	if err != nil {
+		return Event{}, err
+	}
+	return event, nil
+}
+
+// activeAttendees returns the event's attendees whose tickets still stand,
+// cancelled purchases are left out.
+func (s *EventService) activeAttendees(event Event) ([]Attendee, error) {
+	tickets, err := s.store.Tickets().ListByEvent(event.ID)
+	if err != nil {
+		return nil, err
+	}
+	attendees, err := eventAttendees(s.store, tickets)
+	if err != nil {
+		return nil, err
+	}
+
+	active := make([]Attendee, 0, len(attendees))
+	for _, attendee := range attendees {
+		if attendee.Status == AttendeeActive {
+			active = append(active, attendee)
+		}
+	}
+	return active, nil
+}
+
+// notifyAttendees emails the attendees about the event's new status and
+// returns how many were sent. The change stands even when some mails fail,
+// they are only logged.
+func (s *EventService) notifyAttendees(event Event, attendees []Attendee) int {
+	if len(attendees) == 0 {
+		return 0
+	}
+
+	userIds := make([]uint, 0, len(attendees))
+	for _, attendee := range attendees {
+		userIds = append(userIds, attendee.UserID)
+	}
+	attendeeUsers, err := s.users.FindByIDs(userIds)
+	if err != nil {
+		log.Printf("notifying attendees of event %d: %v", event.ID, err)
+		return 0
+	}
+
+	data := mailer.EventStatusData{
+		EventName: event.Name,
+		EventID: event.ID,
+		Status: event.Status,
+		Reason: event.StatusReason,
+		EventDate: event.EventDate.Format("2006-01-02 15:04"),
+	}
+	sent := 0
+	for _, user := range attendeeUsers {
+		message, err := mailer.Render("event_status", user.Email, event.Name+" has been "+event.Status, data)
+		if err == nil {
+			err = s.mailer.Send(message)
+		}
+		if err != nil {
+			log.Printf("notifying %s of event %d: %v", user.Email, event.ID, err)
+			continue
+		}
+		sent++
+	}
+	return sent
+}
@@ -0,0 +1,190 @@
+package events
+
+import (
+	"avana/internal/mailer"
+	"log"
+)
+
+const (
+	MemberOwner string = "owner"
+	MemberManager string = "manager"
+	MemberBoxOffice string = "box_office"
+	MemberCheckIn string = "checkin"
+	MemberViewer string = "viewer"
+
+	MemberInvited string = "invited"
+	MemberActive string = "active"
+)
+
+const (
+	EventPermView string = "view"
+	EventPermEdit string = "edit"
+	EventPermDelete string = "delete"
+	EventPermManageTickets string = "manage_tickets"
+	EventPermViewAttendees string = "view_attendees"
+	EventPermCheckIn string = "checkin"
+	EventPermManageTeam string = "manage_team"
+)
+
+// memberPermissions lists what each team role may do on its event.
+var memberPermissions = map[string][]string{
+	MemberOwner: {
+		EventPermView,
+		EventPermEdit,
+		EventPermDelete,
+		EventPermManageTickets,
+		EventPermViewAttendees,
+		EventPermCheckIn,
+		EventPermManageTeam,
+	},
+	MemberManager: {
+		EventPermView,
+		EventPermEdit,
+		EventPermManageTickets,
+		EventPermViewAttendees,
+		EventPermCheckIn,
+		EventPermManageTeam,
+	},
+	MemberBoxOffice: {
+		EventPermView,
+		EventPermViewAttendees,
+		EventPermCheckIn,
+	},
+	MemberCheckIn: {
+		EventPermView,
+		EventPermViewAttendees,
+		EventPermCheckIn,
+	},
+	MemberViewer: {
+		EventPermView,
+		EventPermViewAttendees,
+	},
+}
+
+// isInvitableRole reports whether the role can be handed out through an
+// invite, ownership never changes hands that way.
+func isInvitableRole(role string) bool {
+	_, ok := memberPermissions[role]
+	return ok && role != MemberOwner
+}
+
+func memberCan(role, permission string) bool {
+	for _, granted := range memberPermissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Members lists the event's team, anyone on it can see who else is.
+func (s *EventService) Members(actor Actor, eventId uint) ([]EventMember, error) {
+	event, err := s.Get(actor, eventId)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.Authorize(actor, event, EventPermViewAttendees); err != nil {
+		return nil, err
+	}
+	return s.store.Members().ListByEvent(event.ID)
+}
+
+// InviteMember adds an invite for the email to the event's team and mails
+// it. The invite stands even if the mail fails.
+func (s *EventService) InviteMember(actor Actor, eventId uint, schema InviteMemberSchema) (EventMember, error) {
+	if !isInvitableRole(schema.Role) {
+		return EventMember{}, ErrInvalidMemberRole
+	}
+
+	event, err := s.Get(actor, eventId)
+	if err != nil {
+		return EventMember{}, err
+	}
+	if err = s.Authorize(actor, event, EventPermManageTeam); err != nil {
+		return EventMember{}, err
+	}
+
+	member := EventMember{
+		EventID: event.ID,
+		Email: schema.Email,
+		Role: schema.Role,
+		Status: MemberInvited,
+		InvitedByID: actor.ID,
+	}
+	err = withTransaction(s.store, func(tx Tx) error {
+		// one membership per email per event
+		existing, err := tx.Members().ExistsByEmail(event.ID, schema.Email)
+		if err != nil {
+			return err
+		}
+		if existing {
+			return ErrAlreadyMember
+		}
+		return tx.Members().Create(&member)
+	})
+	if err != nil {
+		return EventMember{}, err
+	}
+
+	message, err := mailer.Render("event_invite", member.Email, "You have been invited to help run "+event.Name, mailer.InviteData{
+		EventName: event.Name,
+		EventID: event.ID,
+		Role: member.Role,
+	})
+	if err == nil {
+		err = s.mailer.Send(message)
+	}
+	if err != nil {
+		log.Printf("sending invite for event %d to %s: %v", event.ID, member.Email, err)
+	}
+	return member, nil
+}
+
+// AcceptInvite joins the actor to the event's team through the invite sent
+// to their email.
+func (s *EventService) AcceptInvite(actor Actor, eventId uint) (EventMember, error) {
+	user, err := s.users.FindByID(actor.ID)
+	if err != nil {
+		return EventMember{}, err
+	}
+
+	var member EventMember
+	err = withTransaction(s.store, func(tx Tx) error {
+		member, err = tx.Members().FindInvite(eventId, user.Email)
+		if err != nil {
+			return err
+		}
+		member.UserID = &user.ID
+		member.Status = MemberActive
+		return tx.Members().Save(&member)
+	})
+	if err != nil {
+		return EventMember{}, err
+	}
+	return member, nil
+}
+
+// RemoveMember takes a member off the event's team. Members can always
+// leave, removing others needs team management and the owner stays.
+func (s *EventService) RemoveMember(actor Actor, eventId uint, memberId uint) error {
+	event, err := s.Get(actor, eventId)
+	if err != nil {
+		return err
+	}
+
+	member, err := s.store.Members().FindByID(event.ID, memberId)
+	if err != nil {
+		return err
+	}
+	if member.Role == MemberOwner {
+		return ErrOwnerRemoval
+	}
+
+	leaving := member.UserID != nil && *member.UserID == actor.ID
+	if !leaving {
+		if err = s.Authorize(actor, event, EventPermManageTeam); err != nil {
+			return err
+		}
+	}
+	return s.store.Members().Delete(member.ID)
+}
@@ -17,9 +17,25 @@ type Event struct {
 	Description string		`gorm:"not null;type:TEXT"`
 	IsLimited bool			`gorm:"not null"`
 	MaxUnitReservation uint		`gorm:"not null;default:1"`
-	EventDate time.Time		`gorm:"not null"`
+	EventDate time.Time		`gorm:"not null;index"`
 	RegistrationExpirationDate time.Time	`gorm:"not null"`
 	UserID uint
+	Status string			`gorm:"not null;default:draft;index"`
+	// StatusReason is what the organiser gave for postponing or cancelling
+	StatusReason string
+	StatusChangedAt *time.Time
+}
+
+type EventMember struct {
+	gorm.Model
+
+	// other fields
+	EventID uint			`gorm:"not null;uniqueIndex:idx_event_member_email"`
+	UserID *uint			`gorm:"index"`
+	Email string			`gorm:"not null;uniqueIndex:idx_event_member_email"`
+	Role string				`gorm:"not null"`
+	Status string			`gorm:"not null;default:invited"`
+	InvitedByID uint
 }
 
 type Ticket struct {
@@ -28,13 +44,38 @@ type Ticket struct {
 	// other fields 
 	Name string			`gorm:"not null"`
 	Price float64		`gorm:"not null"`
-	TotalAvailable uint		`gorm:"not null"`
+	// TotalAvailable is nil for a ticket without a limit
+	TotalAvailable *uint
+	Sold uint			`gorm:"not null;default:0"`
 	SingleLimit uint	`gorm:"not null"`
 	ExpiryTime time.Time	`gorm:"not null"`
-	EventID uint
+	EventID uint		`gorm:"index"`
 
 }
 
+const (
+	ReservationHeld string = "held"
+	ReservationConverted string = "converted"
+	ReservationCancelled string = "cancelled"
+	ReservationExpired string = "expired"
+)
+
+type Reservation struct {
+	gorm.Model
+
+	// other fields
+	UserID uint
+	TicketID uint
+	Units uint				`gorm:"not null"`
+	Status string			`gorm:"not null;default:held;index"`
+	ExpiresAt time.Time		`gorm:"not null;index"`
+}
+
+const (
+	AttendeeActive string = "active"
+	AttendeeCancelled string = "cancelled"
+)
+
 type Attendee struct {
 	gorm.Model
 
@@ -42,7 +83,97 @@ type Attendee struct {
 	UserID uint
 	Units uint		`gorm:"not null"`
 	Attended bool			`gorm:"not null;default:false"`
-	Review string 			
-	Rating uint
 	TicketID uint
-}
\ No newline at end of file
+	Status string			`gorm:"not null;default:active;index"`
+	// Code is presented at the door to check the purchase in
+	Code string				`gorm:"not null;uniqueIndex"`
+	// CheckedIn counts the units let in so far, at most Units
+	CheckedIn uint			`gorm:"not null;default:0"`
+}
+
+// CheckIn records units of a purchase let in at the door and who let them in.
+type CheckIn struct {
+	gorm.Model
+
+	// other fields
+	AttendeeID uint			`gorm:"not null;index"`
+	EventID uint			`gorm:"not null;index"`
+	Units uint				`gorm:"not null"`
+	CheckedInByID uint		`gorm:"not null"`
+	CheckedInAt time.Time	`gorm:"not null"`
+	// TicketCodeID is set when a single unit's code was scanned
+	TicketCodeID *uint		`gorm:"index"`
+	// DeviceID names the scanner of a check-in synced after the fact
+	DeviceID string
+}
+
+const (
+	TicketCodeValid string = "valid"
+	TicketCodeRevoked string = "revoked"
+)
+
+const (
+	RevokedCancelled string = "cancelled"
+	RevokedTransferred string = "transferred"
+)
+
+// TicketCode is the code for one unit of a purchase. Only its serial is
+// stored, the signature is derived from it whenever the code is shown.
+type TicketCode struct {
+	gorm.Model
+
+	// other fields
+	AttendeeID uint			`gorm:"not null;index"`
+	EventID uint			`gorm:"not null;index"`
+	Unit uint				`gorm:"not null"`
+	Serial string			`gorm:"not null;uniqueIndex"`
+	Status string			`gorm:"not null;default:valid;index"`
+	RevokedReason string
+	RevokedAt *time.Time
+	CheckedInAt *time.Time
+}
+
+const (
+	ReviewPublished string = "published"
+	// ReviewPending is a review hidden until a moderator looks at its flags
+	ReviewPending string = "pending"
+	ReviewHidden string = "hidden"
+)
+
+type Review struct {
+	gorm.Model
+
+	// other fields
+	EventID uint			`gorm:"not null;uniqueIndex:idx_review_event_user"`
+	UserID uint				`gorm:"not null;uniqueIndex:idx_review_event_user"`
+	Rating uint				`gorm:"not null"`
+	Body string				`gorm:"type:TEXT"`
+	VerifiedAttendee bool	`gorm:"not null;default:false"`
+	Status string			`gorm:"not null;default:published;index"`
+	ModerationNote string
+	ModeratedByID *uint
+	ModeratedAt *time.Time
+	// Reply is the organiser's public answer, one per review
+	Reply string			`gorm:"type:TEXT"`
+	RepliedByID *uint
+	RepliedAt *time.Time
+}
+
+const (
+	FlagSpam string = "spam"
+	FlagOffensive string = "offensive"
+	FlagOffTopic string = "off_topic"
+	FlagFake string = "fake"
+	FlagOther string = "other"
+)
+
+type ReviewFlag struct {
+	gorm.Model
+
+	// other fields
+	ReviewID uint			`gorm:"not null;uniqueIndex:idx_review_flag_user"`
+	UserID uint				`gorm:"not null;uniqueIndex:idx_review_flag_user"`
+	Reason string			`gorm:"not null"`
+	Note string
+	Resolved bool			`gorm:"not null;default:false;index"`
+}
@@ -0,0 +1,301 @@
+package events
+
+import (
+	"avana/internal/repository"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// SyncClockSkew is how far ahead of the server a scanner's clock may run
+// before its scans are refused.
+const SyncClockSkew = 5 * time.Minute
+
+const (
+	// ScanAdmitted is a scan that holds the code's check-in
+	ScanAdmitted string = "admitted"
+	// ScanDuplicate is a scan of a code an earlier scan already used
+	ScanDuplicate string = "duplicate"
+	ScanRevoked string = "revoked"
+	// ScanInvalid is a scan of a code that is not this event's, or one
+	// timed in the future
+	ScanInvalid string = "invalid"
+)
+
+// CheckInManifest is the signed list a scanner caches to check codes in
+// without a connection. Unused codes may be let in once, used and revoked
+// ones refused. A code whose signature holds but which is on no list was
+// issued after the manifest and is let in until the next sync says otherwise.
+type CheckInManifest struct {
+	EventID uint			`json:"eid"`
+	Unused []string			`json:"unused"`
+	Used []string			`json:"used"`
+	Revoked []string		`json:"revoked"`
+	jwt.RegisteredClaims
+}
+
+// ManifestBundle is the manifest as a JWT signed with the ticket key, with
+// its counts for whoever loads it onto a scanner.
+type ManifestBundle struct {
+	EventID uint
+	GeneratedAt time.Time
+	Unused int
+	Used int
+	Revoked int
+	Manifest string
+}
+
+// ScanResult is the outcome of one offline scan and where its code stands
+// once the batch is merged.
+type ScanResult struct {
+	Code string
+	Serial string
+	ScannedAt time.Time
+	Outcome string
+	AttendeeID uint
+	// CheckedInAt and DeviceID belong to the scan that holds the check-in,
+	// set for admitted and duplicate scans
+	CheckedInAt *time.Time
+	DeviceID string
+}
+
+// SyncResult reports a synced batch and the event's door totals after it.
+type SyncResult struct {
+	DeviceID string
+	Scans []ScanResult
+	Admitted int
+	Duplicates int
+	Refused int
+	// CheckedIn counts the event's valid codes used so far, out of Valid
+	CheckedIn int
+	Valid int
+}
+
+// CheckInManifest signs the state of every code issued for the event for a
+// scanner to cache.
+func (s *EventService) CheckInManifest(actor Actor, eventId uint) (ManifestBundle, error) {
+	event, err := s.checkInEvent(actor, eventId)
+	if err != nil {
+		return ManifestBundle{}, err
+	}
+
+	codes, err := s.store.TicketCodes().ListByEvent(event.ID)
+	if err != nil {
+		return ManifestBundle{}, err
+	}
+
+	now := time.Now()
+	manifest := CheckInManifest{
+		EventID: event.ID,
+		Unused: []string{},
+		Used: []string{},
+		Revoked: []string{},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject: "event:" + strconv.FormatUint(uint64(event.ID), 10),
+			IssuedAt: jwt.NewNumericDate(now),
+		},
+	}
+	for _, code := range codes {
+		switch {
+		case code.Status != TicketCodeValid:
+			manifest.Revoked = append(manifest.Revoked, code.Serial)
+		case code.CheckedInAt != nil:
+			manifest.Used = append(manifest.Used, code.Serial)
+		default:
+			manifest.Unused = append(manifest.Unused, code.Serial)
+		}
+	}
+
+	token, err := s.signer.SignClaims(manifest)
+	if err != nil {
+		return ManifestBundle{}, err
+	}
+
+	return ManifestBundle{
+		EventID: event.ID,
+		GeneratedAt: now,
+		Unused: len(manifest.Unused),
+		Used: len(manifest.Used),
+		Revoked: len(manifest.Revoked),
+		Manifest: token,
+	}, nil
+}
+
+// SyncCheckIns merges a scanner's offline scans into the event's check-ins.
+// When a code was scanned more than once, at one door or several, the
+// earliest scan holds the check-in whatever order the batches arrive in,
+// and equal times go to the lower device id. A batch sent again changes
+// nothing.
+func (s *EventService) SyncCheckIns(actor Actor, eventId uint, schema SyncCheckInsSchema) (SyncResult, error) {
+	event, err := s.checkInEvent(actor, eventId)
+	if err != nil {
+		return SyncResult{}, err
+	}
+
+	// scans taken before the event completed still count, not once it is cancelled
+	if event.Status == EventCancelled {
+		return SyncResult{}, ErrEventClosed
+	}
+
+	// merge earliest first so a batch's own repeats settle the same way
+	order := make([]int, len(schema.Scans))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := schema.Scans[order[i]], schema.Scans[order[j]]
+		if !a.ScannedAt.Equal(b.ScannedAt) {
+			return a.ScannedAt.Before(b.ScannedAt)
+		}
+		return a.Code < b.Code
+	})
+
+	result := SyncResult{
+		DeviceID: schema.DeviceID,
+		Scans: make([]ScanResult, len(schema.Scans)),
+	}
+	err = withTransaction(s.store, func(tx Tx) error {
+		for _, i := range order {
+			scan, err := s.syncScan(tx, actor, event, schema.DeviceID, schema.Scans[i])
+			if err != nil {
+				return err
+			}
+			result.Scans[i] = scan
+		}
+		return nil
+	})
+	if err != nil {
+		return SyncResult{}, err
+	}
+
+	for _, scan := range result.Scans {
+		switch scan.Outcome {
+		case ScanAdmitted:
+			result.Admitted++
+		case ScanDuplicate:
+			result.Duplicates++
+		default:
+			result.Refused++
+		}
+	}
+
+	codes, err := s.store.TicketCodes().ListByEvent(event.ID)
+	if err != nil {
+		return SyncResult{}, err
+	}
+	for _, code := range codes {
+		if code.Status != TicketCodeValid {
+			continue
+		}
+		result.Valid++
+		if code.CheckedInAt != nil {
+			result.CheckedIn++
+		}
+	}
+	return result, nil
+}
+
+func (s *EventService) syncScan(tx Tx, actor Actor, event Event, deviceId string, offline OfflineScanSchema) (ScanResult, error) {
+	// postgres keeps microseconds, a resent scan has to compare equal
+	scannedAt := offline.ScannedAt.Truncate(time.Microsecond)
+	result := ScanResult{
+		Code: offline.Code,
+		ScannedAt: scannedAt,
+		Outcome: ScanInvalid,
+	}
+
+	if scannedAt.After(time.Now().Add(SyncClockSkew)) {
+		return result, nil
+	}
+
+	serial, eventId, err := s.parseTicketToken(offline.Code)
+	if err != nil || eventId != event.ID {
+		return result, nil
+	}
+	result.Serial = serial
+
+	code, err := tx.TicketCodes().FindBySerialForUpdate(serial)
+	if errors.Is(err, repository.ErrNotFound) {
+		return result, nil
+	}
+	if err != nil {
+		return ScanResult{}, err
+	}
+
+	attendee, err := tx.Attendees().FindByIDForUpdate(code.AttendeeID)
+	if err != nil {
+		return ScanResult{}, err
+	}
+	result.AttendeeID = attendee.ID
+
+	_, err = scannedTicket(tx, event, attendee)
+	if errors.Is(err, ErrUnknownTicketCode) {
+		return result, nil
+	}
+	if errors.Is(err, ErrTicketCancelled) || (err == nil && code.Status != TicketCodeValid) {
+		result.Outcome = ScanRevoked
+		return result, nil
+	}
+	if err != nil {
+		return ScanResult{}, err
+	}
+
+	if code.CheckedInAt == nil {
+		checkIn := newCheckIn(actor, event, 1)
+		checkIn.CheckedInAt = scannedAt
+		checkIn.DeviceID = deviceId
+		if err = admit(tx, &attendee, &code, checkIn); err != nil {
+			return ScanResult{}, err
+		}
+		return heldBy(result, ScanAdmitted, scannedAt, deviceId), nil
+	}
+
+	// a door code check-in marks the codes used without a check-in of their own
+	checkIn, err := tx.CheckIns().FindByTicketCode(code.ID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return ScanResult{}, err
+	}
+	holder := err == nil
+
+	if scannedAt.Equal(*code.CheckedInAt) && deviceId == checkIn.DeviceID {
+		return heldBy(result, ScanAdmitted, scannedAt, deviceId), nil
+	}
+	if !scannedBefore(scannedAt, deviceId, *code.CheckedInAt, checkIn.DeviceID) {
+		return heldBy(result, ScanDuplicate, *code.CheckedInAt, checkIn.DeviceID), nil
+	}
+
+	// this scan came first, the check-in moves to it
+	code.CheckedInAt = &scannedAt
+	if err = tx.TicketCodes().Save(&code); err != nil {
+		return ScanResult{}, err
+	}
+	if holder {
+		checkIn.CheckedInAt = scannedAt
+		checkIn.CheckedInByID = actor.ID
+		checkIn.DeviceID = deviceId
+		if err = tx.CheckIns().Save(&checkIn); err != nil {
+			return ScanResult{}, err
+		}
+	}
+	return heldBy(result, ScanAdmitted, scannedAt, deviceId), nil
+}
+
+// scannedBefore orders two scans of a code: the earlier one, or at the same
+// time the one from the lower device id. Scans at the online endpoint have
+// no device and win ties.
+func scannedBefore(at time.Time, deviceId string, otherAt time.Time, otherDeviceId string) bool {
+	if !at.Equal(otherAt) {
+		return at.Before(otherAt)
+	}
+	return deviceId < otherDeviceId
+}
+
+func heldBy(result ScanResult, outcome string, at time.Time, deviceId string) ScanResult {
+	result.Outcome = outcome
+	result.CheckedInAt = &at
+	result.DeviceID = deviceId
+	return result
+}
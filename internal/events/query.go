@@ -0,0 +1,116 @@
+package events
+
+import (
+	"avana/internal/utils"
+	"strings"
+	"time"
+)
+
+// Sort orders accepted by GET /event/all. A leading "-" reverses the order.
+const (
+	SortNewest string = "newest"
+	SortDate string = "date"
+	SortDateDesc string = "-date"
+	SortPrice string = "price"
+	SortPriceDesc string = "-price"
+	SortPopularity string = "popularity"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize = 100
+)
+
+// EventQuery narrows and orders the public event listing. Zero values mean
+// no filter, so an empty query lists every event newest first.
+type EventQuery struct {
+	Search string
+	Location string
+	Organiser string
+	From *time.Time
+	To *time.Time
+	Paid *bool
+	// Available keeps only events with a ticket still on sale and not sold out
+	Available *bool
+	// Statuses keeps only events in one of these statuses
+	Statuses []string
+	Sort string
+	Page int
+	PageSize int
+}
+
+// EventPage is one page of a listing along with what is needed to fetch the next.
+type EventPage struct {
+	Events []Event
+	Total int64
+	Page int
+	PageSize int
+}
+
+// NextPage is the page after this one, or zero when this is the last.
+func (p EventPage) NextPage() int {
+	if int64(p.Page*p.PageSize) >= p.Total {
+		return 0
+	}
+	return p.Page + 1
+}
+
+func (q EventQuery) offset() int {
+	return (q.Page - 1) * q.PageSize
+}
+
+// searchTerms splits the search text into lowercase words.
+func (q EventQuery) searchTerms() []string {
+	return strings.Fields(strings.ToLower(q.Search))
+}
+
+// newEventQuery reads the query string schema, filling in the defaults.
+func newEventQuery(schema EventQuerySchema) (EventQuery, error) {
+	query := EventQuery{
+		Search: strings.TrimSpace(schema.Search),
+		Location: strings.TrimSpace(schema.Location),
+		Organiser: strings.TrimSpace(schema.Organiser),
+		Paid: schema.Paid,
+		Available: schema.Available,
+		Sort: schema.Sort,
+		Page: schema.Page,
+		PageSize: schema.PageSize,
+	}
+
+	if schema.From != "" {
+		from, err := utils.ParseDate(schema.From)
+		if err != nil {
+			return EventQuery{}, invalidDate("From")
+		}
+		query.From = &from
+	}
+
+	if schema.To != "" {
+		to, err := utils.ParseDate(schema.To)
+		if err != nil {
+			return EventQuery{}, invalidDate("To")
+		}
+		query.To = &to
+	}
+
+	if schema.Status != "" {
+		query.Statuses = []string{schema.Status}
+	} else {
+		query.Statuses = publicStatuses
+	}
+
+	if query.Sort == "" {
+		query.Sort = SortNewest
+	}
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PageSize < 1 {
+		query.PageSize = DefaultPageSize
+	}
+	if query.PageSize > MaxPageSize {
+		query.PageSize = MaxPageSize
+	}
+
+	return query, nil
+}
@@ -0,0 +1,725 @@
+package events
+
+import (
+	"avana/internal/payments"
+	"avana/internal/repository"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type EventRepository interface {
+	Create(event *Event) error
+	FindByID(id uint) (Event, error)
+	// FindByIDForUpdate locks the event row, purchases and status changes
+	// take it so a sale cannot slip past a cancellation.
+	FindByIDForUpdate(id uint) (Event, error)
+	FindByTicketID(ticketId uint) (Event, error)
+	// List returns one page of the events matching the query and how many
+	// match in total.
+	List(query EventQuery) ([]Event, int64, error)
+	ListByUser(userId uint) ([]Event, error)
+	Save(event *Event) error
+	Delete(id uint) error
+}
+
+type TicketRepository interface {
+	Create(ticket *Ticket) error
+	FindByID(id uint) (Ticket, error)
+	FindByIDForUpdate(id uint) (Ticket, error)
+	ListByEvent(eventId uint) ([]Ticket, error)
+	Save(ticket *Ticket) error
+	Delete(id uint) error
+	// Reserve moves units into the sold count, failing with ErrSoldOut
+	// instead of going past total available. A nil total is unlimited.
+	Reserve(ticketId uint, units uint) error
+	// Release takes units back out of the sold count, failing with
+	// ErrReleaseExceedsSold when fewer than that were sold.
+	Release(ticketId uint, units uint) error
+}
+
+type AttendeeRepository interface {
+	Create(attendee *Attendee) error
+	// ExistsActive reports whether the user holds the ticket, purchases that
+	// were cancelled do not count.
+	ExistsActive(userId, ticketId uint) (bool, error)
+	ListByTickets(ticketIds []uint) ([]Attendee, error)
+	ListByUser(userId uint, ticketIds []uint) ([]Attendee, error)
+	// ListByOwner returns every purchase the user holds, across events.
+	ListByOwner(userId uint) ([]Attendee, error)
+	FindByID(id uint) (Attendee, error)
+	FindByIDForUpdate(id uint) (Attendee, error)
+	FindByCodeForUpdate(code string) (Attendee, error)
+	Save(attendee *Attendee) error
+}
+
+type TicketCodeRepository interface {
+	Create(code *TicketCode) error
+	FindBySerial(serial string) (TicketCode, error)
+	FindBySerialForUpdate(serial string) (TicketCode, error)
+	// ListByAttendees returns the codes of the purchases in unit order.
+	ListByAttendees(attendeeIds []uint) ([]TicketCode, error)
+	// ListByEvent returns every code issued for the event in serial order.
+	ListByEvent(eventId uint) ([]TicketCode, error)
+	Save(code *TicketCode) error
+}
+
+type CheckInRepository interface {
+	Create(checkIn *CheckIn) error
+	// ListByAttendee returns the attendee's check-ins, earliest first.
+	ListByAttendee(attendeeId uint) ([]CheckIn, error)
+	// ListByEvent returns the event's check-ins, latest first.
+	ListByEvent(eventId uint) ([]CheckIn, error)
+	// FindByTicketCode returns the check-in that scanned the unit's code.
+	FindByTicketCode(codeId uint) (CheckIn, error)
+	Save(checkIn *CheckIn) error
+}
+
+type MemberRepository interface {
+	Create(member *EventMember) error
+	FindByID(eventId, memberId uint) (EventMember, error)
+	FindActive(eventId, userId uint) (EventMember, error)
+	FindInvite(eventId uint, email string) (EventMember, error)
+	ExistsByEmail(eventId uint, email string) (bool, error)
+	ListByEvent(eventId uint) ([]EventMember, error)
+	Save(member *EventMember) error
+	Delete(id uint) error
+}
+
+type ReservationRepository interface {
+	Create(reservation *Reservation) error
+	FindByID(id uint) (Reservation, error)
+	FindByIDForUpdate(id uint) (Reservation, error)
+	CountHeld(userId, ticketId uint) (int64, error)
+	// ListExpiredForUpdate locks expired holds, skipping rows another
+	// transaction is already working on.
+	ListExpiredForUpdate(now time.Time) ([]Reservation, error)
+	ListHeldByTickets(ticketIds []uint) ([]Reservation, error)
+	Save(reservation *Reservation) error
+}
+
+type ReviewRepository interface {
+	Create(review *Review) error
+	FindByID(id uint) (Review, error)
+	FindByUser(eventId, userId uint) (Review, error)
+	// ListByEvents returns the reviews of the events in the status, newest first.
+	ListByEvents(eventIds []uint, status string) ([]Review, error)
+	// ListForModeration returns reviews awaiting a moderator or carrying
+	// unresolved flags, oldest first.
+	ListForModeration() ([]Review, error)
+	Save(review *Review) error
+}
+
+type ReviewFlagRepository interface {
+	Create(flag *ReviewFlag) error
+	CountOpen(reviewId uint) (int64, error)
+	ListOpen(reviewIds []uint) ([]ReviewFlag, error)
+	ResolveByReview(reviewId uint) error
+}
+
+type Repositories interface {
+	Events() EventRepository
+	Tickets() TicketRepository
+	Attendees() AttendeeRepository
+	Members() MemberRepository
+	Reservations() ReservationRepository
+	Reviews() ReviewRepository
+	ReviewFlags() ReviewFlagRepository
+	CheckIns() CheckInRepository
+	TicketCodes() TicketCodeRepository
+	Orders() payments.OrderRepository
+	Payments() payments.PaymentRepository
+}
+
+// Store hands out the repositories and opens transactions across them.
+type Store interface {
+	Repositories
+	Begin() (Tx, error)
+}
+
+// Tx is a Store scoped to one transaction, it must end with Commit or Rollback.
+type Tx interface {
+	Repositories
+	Commit() error
+	Rollback()
+}
+
+// withTransaction runs fn in a transaction, committing only when it succeeds.
+func withTransaction(store Store, fn func(tx Tx) error) error {
+	tx, err := store.Begin()
+	if err != nil {
+		return err
+	}
+	if err = fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+type gormStore struct {
+	db *gorm.DB
+}
+
+func NewGormStore(db *gorm.DB) Store {
+	return &gormStore{db: db}
+}
+
+func (s *gormStore) Events() EventRepository {
+	return &gormEvents{db: s.db}
+}
+
+func (s *gormStore) Tickets() TicketRepository {
+	return &gormTickets{db: s.db}
+}
+
+func (s *gormStore) Attendees() AttendeeRepository {
+	return &gormAttendees{db: s.db}
+}
+
+func (s *gormStore) Members() MemberRepository {
+	return &gormMembers{db: s.db}
+}
+
+func (s *gormStore) Reservations() ReservationRepository {
+	return &gormReservations{db: s.db}
+}
+
+func (s *gormStore) Reviews() ReviewRepository {
+	return &gormReviews{db: s.db}
+}
+
+func (s *gormStore) ReviewFlags() ReviewFlagRepository {
+	return &gormReviewFlags{db: s.db}
+}
+
+func (s *gormStore) CheckIns() CheckInRepository {
+	return &gormCheckIns{db: s.db}
+}
+
+func (s *gormStore) TicketCodes() TicketCodeRepository {
+	return &gormTicketCodes{db: s.db}
+}
+
+func (s *gormStore) Orders() payments.OrderRepository {
+	return payments.NewGormOrderRepository(s.db)
+}
+
+func (s *gormStore) Payments() payments.PaymentRepository {
+	return payments.NewGormPaymentRepository(s.db)
+}
+
+func (s *gormStore) Begin() (Tx, error) {
+	tx := s.db.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &gormTx{gormStore{db: tx}}, nil
+}
+
+type gormTx struct {
+	gormStore
+}
+
+func (t *gormTx) Commit() error {
+	return t.db.Commit().Error
+}
+
+func (t *gormTx) Rollback() {
+	t.db.Rollback()
+}
+
+type gormEvents struct {
+	db *gorm.DB
+}
+
+func (r *gormEvents) Create(event *Event) error {
+	return repository.GormError(r.db.Create(event).Error)
+}
+
+func (r *gormEvents) FindByID(id uint) (Event, error) {
+	var event Event
+	err := r.db.First(&event, id).Error
+	return event, repository.GormError(err)
+}
+
+func (r *gormEvents) FindByIDForUpdate(id uint) (Event, error) {
+	var event Event
+	err := r.db.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).First(&event, id).Error
+	return event, repository.GormError(err)
+}
+
+func (r *gormEvents) FindByTicketID(ticketId uint) (Event, error) {
+	var event Event
+	err := r.db.Table("events").
+				Select("events.*").
+				Joins("JOIN tickets ON tickets.event_id = events.id").
+				Where("tickets.id = ? AND events.deleted_at IS NULL", ticketId).
+				First(&event).Error
+	return event, repository.GormError(err)
+}
+
+func (r *gormEvents) List(query EventQuery) ([]Event, int64, error) {
+	filter := func(db *gorm.DB) *gorm.DB {
+		if query.Search != "" {
+			db = db.Where("to_tsvector('english', events.name || ' ' || events.description) @@ plainto_tsquery('english', ?)", query.Search)
+		}
+		if query.Location != "" {
+			db = db.Where("events.location ILIKE ?", containsPattern(query.Location))
+		}
+		if query.Organiser != "" {
+			db = db.Where("events.organiser ILIKE ?", containsPattern(query.Organiser))
+		}
+		if query.From != nil {
+			db = db.Where("events.event_date >= ?", *query.From)
+		}
+		if query.To != nil {
+			db = db.Where("events.event_date <= ?", *query.To)
+		}
+		if query.Paid != nil {
+			db = db.Where("events.is_paid_event = ?", *query.Paid)
+		}
+		if len(query.Statuses) > 0 {
+			db = db.Where("events.status IN ?", query.Statuses)
+		}
+		if query.Available != nil {
+			available := "EXISTS (SELECT 1 FROM tickets WHERE tickets.event_id = events.id AND tickets.deleted_at IS NULL " +
+						"AND tickets.expiry_time > ? AND (tickets.total_available IS NULL OR tickets.sold < tickets.total_available))"
+			if !*query.Available {
+				available = "NOT " + available
+			}
+			db = db.Where(available, time.Now())
+		}
+		return db
+	}
+
+	var total int64
+	if err := r.db.Model(&Event{}).Scopes(filter).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var events []Event
+	err := r.db.Scopes(filter).
+				Order(eventOrder(query.Sort)).
+				Limit(query.PageSize).
+				Offset(query.offset()).
+				Find(&events).Error
+	return events, total, err
+}
+
+// eventOrder is the ORDER BY for a sort option. Events without tickets sort
+// last by price, and the id breaks ties so pages never overlap.
+func eventOrder(sort string) string {
+	const minPrice = "(SELECT MIN(tickets.price) FROM tickets WHERE tickets.event_id = events.id AND tickets.deleted_at IS NULL)"
+	const sold = "(SELECT COALESCE(SUM(tickets.sold), 0) FROM tickets WHERE tickets.event_id = events.id AND tickets.deleted_at IS NULL)"
+
+	switch sort {
+	case SortDate:
+		return "events.event_date ASC, events.id ASC"
+	case SortDateDesc:
+		return "events.event_date DESC, events.id DESC"
+	case SortPrice:
+		return minPrice + " ASC NULLS LAST, events.id ASC"
+	case SortPriceDesc:
+		return minPrice + " DESC NULLS LAST, events.id DESC"
+	case SortPopularity:
+		return sold + " DESC, events.id DESC"
+	}
+	return "events.created_at DESC, events.id DESC"
+}
+
+// containsPattern matches the text anywhere, treating LIKE wildcards in it literally.
+func containsPattern(text string) string {
+	escaped := strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(text)
+	return "%" + escaped + "%"
+}
+
+func (r *gormEvents) ListByUser(userId uint) ([]Event, error) {
+	var events []Event
+	err := r.db.Where("user_id = ?", userId).Order("created_at DESC").Find(&events).Error
+	return events, err
+}
+
+func (r *gormEvents) Save(event *Event) error {
+	return repository.GormError(r.db.Save(event).Error)
+}
+
+func (r *gormEvents) Delete(id uint) error {
+	return r.db.Delete(&Event{}, id).Error
+}
+
+type gormTickets struct {
+	db *gorm.DB
+}
+
+func (r *gormTickets) Create(ticket *Ticket) error {
+	return repository.GormError(r.db.Create(ticket).Error)
+}
+
+func (r *gormTickets) FindByID(id uint) (Ticket, error) {
+	var ticket Ticket
+	err := r.db.First(&ticket, id).Error
+	return ticket, repository.GormError(err)
+}
+
+func (r *gormTickets) FindByIDForUpdate(id uint) (Ticket, error) {
+	var ticket Ticket
+	err := r.db.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).First(&ticket, id).Error
+	return ticket, repository.GormError(err)
+}
+
+func (r *gormTickets) ListByEvent(eventId uint) ([]Ticket, error) {
+	var tickets []Ticket
+	err := r.db.Where("event_id = ?", eventId).Order("price").Find(&tickets).Error
+	return tickets, err
+}
+
+func (r *gormTickets) Save(ticket *Ticket) error {
+	return repository.GormError(r.db.Save(ticket).Error)
+}
+
+func (r *gormTickets) Delete(id uint) error {
+	return r.db.Delete(&Ticket{}, id).Error
+}
+
+func (r *gormTickets) Reserve(ticketId uint, units uint) error {
+	result := r.db.Model(&Ticket{}).
+				Where("id = ? AND (total_available IS NULL OR sold + ? <= total_available)", ticketId, units).
+				UpdateColumn("sold", gorm.Expr("sold + ?", units))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSoldOut
+	}
+	return nil
+}
+
+func (r *gormTickets) Release(ticketId uint, units uint) error {
+	result := r.db.Model(&Ticket{}).
+				Where("id = ? AND sold >= ?", ticketId, units).
+				UpdateColumn("sold", gorm.Expr("sold - ?", units))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrReleaseExceedsSold
+	}
+	return nil
+}
+
+type gormAttendees struct {
+	db *gorm.DB
+}
+
+func (r *gormAttendees) Create(attendee *Attendee) error {
+	return repository.GormError(r.db.Create(attendee).Error)
+}
+
+func (r *gormAttendees) ExistsActive(userId, ticketId uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&Attendee{}).
+				Where("user_id = ? AND ticket_id = ? AND status = ?", userId, ticketId, AttendeeActive).
+				Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormAttendees) ListByTickets(ticketIds []uint) ([]Attendee, error) {
+	var attendees []Attendee
+	if len(ticketIds) == 0 {
+		return attendees, nil
+	}
+	err := r.db.Where("ticket_id IN ?", ticketIds).Order("created_at").Find(&attendees).Error
+	return attendees, err
+}
+
+func (r *gormAttendees) ListByUser(userId uint, ticketIds []uint) ([]Attendee, error) {
+	var attendees []Attendee
+	if len(ticketIds) == 0 {
+		return attendees, nil
+	}
+	err := r.db.Where("user_id = ? AND ticket_id IN ?", userId, ticketIds).Order("created_at").Find(&attendees).Error
+	return attendees, err
+}
+
+func (r *gormAttendees) ListByOwner(userId uint) ([]Attendee, error) {
+	var attendees []Attendee
+	err := r.db.Where("user_id = ?", userId).Order("created_at DESC").Find(&attendees).Error
+	return attendees, err
+}
+
+func (r *gormAttendees) FindByID(id uint) (Attendee, error) {
+	var attendee Attendee
+	err := r.db.First(&attendee, id).Error
+	return attendee, repository.GormError(err)
+}
+
+func (r *gormAttendees) FindByIDForUpdate(id uint) (Attendee, error) {
+	var attendee Attendee
+	err := r.db.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).First(&attendee, id).Error
+	return attendee, repository.GormError(err)
+}
+
+func (r *gormAttendees) FindByCodeForUpdate(code string) (Attendee, error) {
+	var attendee Attendee
+	err := r.db.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).
+				Where("code = ?", code).
+				First(&attendee).Error
+	return attendee, repository.GormError(err)
+}
+
+func (r *gormAttendees) Save(attendee *Attendee) error {
+	return repository.GormError(r.db.Save(attendee).Error)
+}
+
+type gormMembers struct {
+	db *gorm.DB
+}
+
+func (r *gormMembers) Create(member *EventMember) error {
+	return repository.GormError(r.db.Create(member).Error)
+}
+
+func (r *gormMembers) FindByID(eventId, memberId uint) (EventMember, error) {
+	var member EventMember
+	err := r.db.Where("id = ? AND event_id = ?", memberId, eventId).First(&member).Error
+	return member, repository.GormError(err)
+}
+
+func (r *gormMembers) FindActive(eventId, userId uint) (EventMember, error) {
+	var member EventMember
+	err := r.db.Where("event_id = ? AND user_id = ? AND status = ?", eventId, userId, MemberActive).
+				First(&member).Error
+	return member, repository.GormError(err)
+}
+
+func (r *gormMembers) FindInvite(eventId uint, email string) (EventMember, error) {
+	var member EventMember
+	err := r.db.Where("event_id = ? AND email = ? AND status = ?", eventId, email, MemberInvited).
+				First(&member).Error
+	return member, repository.GormError(err)
+}
+
+func (r *gormMembers) ExistsByEmail(eventId uint, email string) (bool, error) {
+	var count int64
+	err := r.db.Model(&EventMember{}).
+				Where("event_id = ? AND email = ?", eventId, email).
+				Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormMembers) ListByEvent(eventId uint) ([]EventMember, error) {
+	var members []EventMember
+	err := r.db.Where("event_id = ?", eventId).Order("created_at").Find(&members).Error
+	return members, err
+}
+
+func (r *gormMembers) Save(member *EventMember) error {
+	return repository.GormError(r.db.Save(member).Error)
+}
+
+func (r *gormMembers) Delete(id uint) error {
+	return r.db.Unscoped().Delete(&EventMember{}, id).Error
+}
+
+type gormReservations struct {
+	db *gorm.DB
+}
+
+func (r *gormReservations) Create(reservation *Reservation) error {
+	return r.db.Create(reservation).Error
+}
+
+func (r *gormReservations) FindByID(id uint) (Reservation, error) {
+	var reservation Reservation
+	err := r.db.First(&reservation, id).Error
+	return reservation, repository.GormError(err)
+}
+
+func (r *gormReservations) FindByIDForUpdate(id uint) (Reservation, error) {
+	var reservation Reservation
+	err := r.db.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).First(&reservation, id).Error
+	return reservation, repository.GormError(err)
+}
+
+func (r *gormReservations) CountHeld(userId, ticketId uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&Reservation{}).
+				Where("user_id = ? AND ticket_id = ? AND status = ?", userId, ticketId, ReservationHeld).
+				Count(&count).Error
+	return count, err
+}
+
+func (r *gormReservations) ListExpiredForUpdate(now time.Time) ([]Reservation, error) {
+	var reservations []Reservation
+	err := r.db.Clauses(clause.Locking{
+					Strength: clause.LockingStrengthUpdate,
+					Options: clause.LockingOptionsSkipLocked,
+				}).
+				Where("status = ? AND expires_at < ?", ReservationHeld, now).
+				Find(&reservations).Error
+	return reservations, err
+}
+
+func (r *gormReservations) ListHeldByTickets(ticketIds []uint) ([]Reservation, error) {
+	var reservations []Reservation
+	if len(ticketIds) == 0 {
+		return reservations, nil
+	}
+	err := r.db.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).
+				Where("ticket_id IN ? AND status = ?", ticketIds, ReservationHeld).
+				Find(&reservations).Error
+	return reservations, err
+}
+
+func (r *gormReservations) Save(reservation *Reservation) error {
+	return repository.GormError(r.db.Save(reservation).Error)
+}
+
+type gormReviews struct {
+	db *gorm.DB
+}
+
+func (r *gormReviews) Create(review *Review) error {
+	return repository.GormError(r.db.Create(review).Error)
+}
+
+func (r *gormReviews) FindByID(id uint) (Review, error) {
+	var review Review
+	err := r.db.First(&review, id).Error
+	return review, repository.GormError(err)
+}
+
+func (r *gormReviews) FindByUser(eventId, userId uint) (Review, error) {
+	var review Review
+	err := r.db.Where("event_id = ? AND user_id = ?", eventId, userId).First(&review).Error
+	return review, repository.GormError(err)
+}
+
+func (r *gormReviews) ListByEvents(eventIds []uint, status string) ([]Review, error) {
+	var reviews []Review
+	if len(eventIds) == 0 {
+		return reviews, nil
+	}
+	err := r.db.Where("event_id IN ? AND status = ?", eventIds, status).
+				Order("created_at DESC").
+				Find(&reviews).Error
+	return reviews, err
+}
+
+func (r *gormReviews) ListForModeration() ([]Review, error) {
+	var reviews []Review
+	err := r.db.Where("status = ? OR EXISTS (SELECT 1 FROM review_flags WHERE review_flags.review_id = reviews.id "+
+					"AND review_flags.resolved = false AND review_flags.deleted_at IS NULL)", ReviewPending).
+				Order("created_at").
+				Find(&reviews).Error
+	return reviews, err
+}
+
+func (r *gormReviews) Save(review *Review) error {
+	return repository.GormError(r.db.Save(review).Error)
+}
+
+type gormReviewFlags struct {
+	db *gorm.DB
+}
+
+func (r *gormReviewFlags) Create(flag *ReviewFlag) error {
+	return repository.GormError(r.db.Create(flag).Error)
+}
+
+func (r *gormReviewFlags) CountOpen(reviewId uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&ReviewFlag{}).
+				Where("review_id = ? AND resolved = ?", reviewId, false).
+				Count(&count).Error
+	return count, err
+}
+
+func (r *gormReviewFlags) ListOpen(reviewIds []uint) ([]ReviewFlag, error) {
+	var flags []ReviewFlag
+	if len(reviewIds) == 0 {
+		return flags, nil
+	}
+	err := r.db.Where("review_id IN ? AND resolved = ?", reviewIds, false).Order("created_at").Find(&flags).Error
+	return flags, err
+}
+
+func (r *gormReviewFlags) ResolveByReview(reviewId uint) error {
+	return r.db.Model(&ReviewFlag{}).
+				Where("review_id = ? AND resolved = ?", reviewId, false).
+				Update("resolved", true).Error
+}
+
+type gormCheckIns struct {
+	db *gorm.DB
+}
+
+func (r *gormCheckIns) Create(checkIn *CheckIn) error {
+	return repository.GormError(r.db.Create(checkIn).Error)
+}
+
+func (r *gormCheckIns) ListByAttendee(attendeeId uint) ([]CheckIn, error) {
+	var checkIns []CheckIn
+	err := r.db.Where("attendee_id = ?", attendeeId).Order("checked_in_at").Find(&checkIns).Error
+	return checkIns, err
+}
+
+func (r *gormCheckIns) ListByEvent(eventId uint) ([]CheckIn, error) {
+	var checkIns []CheckIn
+	err := r.db.Where("event_id = ?", eventId).Order("checked_in_at DESC").Find(&checkIns).Error
+	return checkIns, err
+}
+
+func (r *gormCheckIns) FindByTicketCode(codeId uint) (CheckIn, error) {
+	var checkIn CheckIn
+	err := r.db.Where("ticket_code_id = ?", codeId).First(&checkIn).Error
+	return checkIn, repository.GormError(err)
+}
+
+func (r *gormCheckIns) Save(checkIn *CheckIn) error {
+	return repository.GormError(r.db.Save(checkIn).Error)
+}
+
+type gormTicketCodes struct {
+	db *gorm.DB
+}
+
+func (r *gormTicketCodes) Create(code *TicketCode) error {
+	return repository.GormError(r.db.Create(code).Error)
+}
+
+func (r *gormTicketCodes) FindBySerial(serial string) (TicketCode, error) {
+	var code TicketCode
+	err := r.db.Where("serial = ?", serial).First(&code).Error
+	return code, repository.GormError(err)
+}
+
+func (r *gormTicketCodes) FindBySerialForUpdate(serial string) (TicketCode, error) {
+	var code TicketCode
+	err := r.db.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).
+				Where("serial = ?", serial).
+				First(&code).Error
+	return code, repository.GormError(err)
+}
+
+func (r *gormTicketCodes) ListByAttendees(attendeeIds []uint) ([]TicketCode, error) {
+	var codes []TicketCode
+	if len(attendeeIds) == 0 {
+		return codes, nil
+	}
+	err := r.db.Where("attendee_id IN ?", attendeeIds).Order("attendee_id, unit, id").Find(&codes).Error
+	return codes, err
+}
+
+func (r *gormTicketCodes) ListByEvent(eventId uint) ([]TicketCode, error) {
+	var codes []TicketCode
+	err := r.db.Where("event_id = ?", eventId).Order("serial").Find(&codes).Error
+	return codes, err
+}
+
+func (r *gormTicketCodes) Save(code *TicketCode) error {
+	return repository.GormError(r.db.Save(code).Error)
+}
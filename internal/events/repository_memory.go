@@ -0,0 +1,754 @@
+package events
+
+import (
+	"avana/internal/payments"
+	"avana/internal/repository"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryStore keeps everything in process, for tests. A transaction holds
+// the store's lock and works on a copy that replaces the tables on commit.
+type memoryStore struct {
+	mu *sync.Mutex
+	events *repository.Table[Event]
+	tickets *repository.Table[Ticket]
+	attendees *repository.Table[Attendee]
+	members *repository.Table[EventMember]
+	reservations *repository.Table[Reservation]
+	reviews *repository.Table[Review]
+	reviewFlags *repository.Table[ReviewFlag]
+	checkIns *repository.Table[CheckIn]
+	ticketCodes *repository.Table[TicketCode]
+	orders *repository.Table[payments.Order]
+	payments *repository.Table[payments.Payment]
+}
+
+func NewMemoryStore() Store {
+	mu := &sync.Mutex{}
+	return &memoryStore{
+		mu: mu,
+		events: repository.NewTable[Event](mu),
+		tickets: repository.NewTable[Ticket](mu),
+		attendees: repository.NewTable[Attendee](mu),
+		members: repository.NewTable[EventMember](mu),
+		reservations: repository.NewTable[Reservation](mu),
+		reviews: repository.NewTable[Review](mu),
+		reviewFlags: repository.NewTable[ReviewFlag](mu),
+		checkIns: repository.NewTable[CheckIn](mu),
+		ticketCodes: repository.NewTable[TicketCode](mu),
+		orders: repository.NewTable[payments.Order](mu),
+		payments: repository.NewTable[payments.Payment](mu),
+	}
+}
+
+func (s *memoryStore) Events() EventRepository {
+	return &memoryEvents{events: s.events, tickets: s.tickets}
+}
+
+func (s *memoryStore) Tickets() TicketRepository {
+	return &memoryTickets{tickets: s.tickets}
+}
+
+func (s *memoryStore) Attendees() AttendeeRepository {
+	return &memoryAttendees{attendees: s.attendees}
+}
+
+func (s *memoryStore) Members() MemberRepository {
+	return &memoryMembers{members: s.members}
+}
+
+func (s *memoryStore) Reservations() ReservationRepository {
+	return &memoryReservations{reservations: s.reservations}
+}
+
+func (s *memoryStore) Reviews() ReviewRepository {
+	return &memoryReviews{reviews: s.reviews, flags: s.reviewFlags}
+}
+
+func (s *memoryStore) ReviewFlags() ReviewFlagRepository {
+	return &memoryReviewFlags{flags: s.reviewFlags}
+}
+
+func (s *memoryStore) CheckIns() CheckInRepository {
+	return &memoryCheckIns{checkIns: s.checkIns}
+}
+
+func (s *memoryStore) TicketCodes() TicketCodeRepository {
+	return &memoryTicketCodes{codes: s.ticketCodes}
+}
+
+func (s *memoryStore) Orders() payments.OrderRepository {
+	return payments.NewMemoryOrderRepository(s.orders)
+}
+
+func (s *memoryStore) Payments() payments.PaymentRepository {
+	return payments.NewMemoryPaymentRepository(s.payments)
+}
+
+func (s *memoryStore) Begin() (Tx, error) {
+	s.mu.Lock()
+	mu := &sync.Mutex{}
+	return &memoryTx{
+		memoryStore: memoryStore{
+			mu: mu,
+			events: s.events.Clone(mu),
+			tickets: s.tickets.Clone(mu),
+			attendees: s.attendees.Clone(mu),
+			members: s.members.Clone(mu),
+			reservations: s.reservations.Clone(mu),
+			reviews: s.reviews.Clone(mu),
+			reviewFlags: s.reviewFlags.Clone(mu),
+			checkIns: s.checkIns.Clone(mu),
+			ticketCodes: s.ticketCodes.Clone(mu),
+			orders: s.orders.Clone(mu),
+			payments: s.payments.Clone(mu),
+		},
+		parent: s,
+	}, nil
+}
+
+type memoryTx struct {
+	memoryStore
+	parent *memoryStore
+	done bool
+}
+
+func (t *memoryTx) Commit() error {
+	if t.done {
+		return nil
+	}
+	t.done = true
+	t.parent.events.Replace(t.events)
+	t.parent.tickets.Replace(t.tickets)
+	t.parent.attendees.Replace(t.attendees)
+	t.parent.members.Replace(t.members)
+	t.parent.reservations.Replace(t.reservations)
+	t.parent.reviews.Replace(t.reviews)
+	t.parent.reviewFlags.Replace(t.reviewFlags)
+	t.parent.checkIns.Replace(t.checkIns)
+	t.parent.ticketCodes.Replace(t.ticketCodes)
+	t.parent.orders.Replace(t.orders)
+	t.parent.payments.Replace(t.payments)
+	t.parent.mu.Unlock()
+	return nil
+}
+
+func (t *memoryTx) Rollback() {
+	if t.done {
+		return
+	}
+	t.done = true
+	t.parent.mu.Unlock()
+}
+
+type memoryEvents struct {
+	events *repository.Table[Event]
+	tickets *repository.Table[Ticket]
+}
+
+func (r *memoryEvents) Create(event *Event) error {
+	exists := r.events.Count(func(existing Event) bool {
+		return existing.Name == event.Name
+	})
+	if exists > 0 {
+		return repository.ErrDuplicate
+	}
+	r.events.Insert(event)
+	return nil
+}
+
+func (r *memoryEvents) FindByID(id uint) (Event, error) {
+	return r.events.Get(id)
+}
+
+func (r *memoryEvents) FindByIDForUpdate(id uint) (Event, error) {
+	return r.events.Get(id)
+}
+
+func (r *memoryEvents) FindByTicketID(ticketId uint) (Event, error) {
+	ticket, err := r.tickets.Get(ticketId)
+	if err != nil {
+		return Event{}, err
+	}
+	return r.events.Get(ticket.EventID)
+}
+
+func (r *memoryEvents) List(query EventQuery) ([]Event, int64, error) {
+	now := time.Now()
+	terms := query.searchTerms()
+	location := strings.ToLower(query.Location)
+	organiser := strings.ToLower(query.Organiser)
+
+	tickets := map[uint][]Ticket{}
+	for _, ticket := range r.tickets.Where(nil) {
+		tickets[ticket.EventID] = append(tickets[ticket.EventID], ticket)
+	}
+
+	events := r.events.Where(func(event Event) bool {
+		text := strings.ToLower(event.Name + " " + event.Description)
+		for _, term := range terms {
+			if !strings.Contains(text, term) {
+				return false
+			}
+		}
+		if location != "" && !strings.Contains(strings.ToLower(event.Location), location) {
+			return false
+		}
+		if organiser != "" && !strings.Contains(strings.ToLower(event.Organiser), organiser) {
+			return false
+		}
+		if query.From != nil && event.EventDate.Before(*query.From) {
+			return false
+		}
+		if query.To != nil && event.EventDate.After(*query.To) {
+			return false
+		}
+		if query.Paid != nil && event.IsPaidEvent != *query.Paid {
+			return false
+		}
+		if len(query.Statuses) > 0 && !containsString(query.Statuses, event.Status) {
+			return false
+		}
+		if query.Available != nil && hasAvailableTicket(tickets[event.ID], now) != *query.Available {
+			return false
+		}
+		return true
+	})
+
+	sortEvents(events, query.Sort, tickets)
+
+	total := int64(len(events))
+	start := query.offset()
+	if start > len(events) {
+		start = len(events)
+	}
+	end := start + query.PageSize
+	if end > len(events) {
+		end = len(events)
+	}
+	return events[start:end], total, nil
+}
+
+func (r *memoryEvents) ListByUser(userId uint) ([]Event, error) {
+	return newestFirst(r.events.Where(func(event Event) bool {
+		return event.UserID == userId
+	})), nil
+}
+
+func (r *memoryEvents) Save(event *Event) error {
+	return r.events.Save(event)
+}
+
+func (r *memoryEvents) Delete(id uint) error {
+	r.events.Delete(id)
+	return nil
+}
+
+type memoryTickets struct {
+	tickets *repository.Table[Ticket]
+}
+
+func (r *memoryTickets) Create(ticket *Ticket) error {
+	r.tickets.Insert(ticket)
+	return nil
+}
+
+func (r *memoryTickets) FindByID(id uint) (Ticket, error) {
+	return r.tickets.Get(id)
+}
+
+func (r *memoryTickets) FindByIDForUpdate(id uint) (Ticket, error) {
+	return r.tickets.Get(id)
+}
+
+func (r *memoryTickets) ListByEvent(eventId uint) ([]Ticket, error) {
+	tickets := r.tickets.Where(func(ticket Ticket) bool {
+		return ticket.EventID == eventId
+	})
+	sort.SliceStable(tickets, func(i, j int) bool {
+		return tickets[i].Price < tickets[j].Price
+	})
+	return tickets, nil
+}
+
+func (r *memoryTickets) Save(ticket *Ticket) error {
+	return r.tickets.Save(ticket)
+}
+
+func (r *memoryTickets) Delete(id uint) error {
+	r.tickets.Delete(id)
+	return nil
+}
+
+func (r *memoryTickets) Reserve(ticketId uint, units uint) error {
+	err := r.tickets.Modify(ticketId, func(ticket *Ticket) error {
+		if ticket.TotalAvailable != nil && ticket.Sold+units > *ticket.TotalAvailable {
+			return ErrSoldOut
+		}
+		ticket.Sold += units
+		return nil
+	})
+	if err == repository.ErrNotFound {
+		return ErrSoldOut
+	}
+	return err
+}
+
+func (r *memoryTickets) Release(ticketId uint, units uint) error {
+	err := r.tickets.Modify(ticketId, func(ticket *Ticket) error {
+		if ticket.Sold < units {
+			return ErrReleaseExceedsSold
+		}
+		ticket.Sold -= units
+		return nil
+	})
+	if err == repository.ErrNotFound {
+		return ErrReleaseExceedsSold
+	}
+	return err
+}
+
+type memoryAttendees struct {
+	attendees *repository.Table[Attendee]
+}
+
+func (r *memoryAttendees) Create(attendee *Attendee) error {
+	r.attendees.Insert(attendee)
+	return nil
+}
+
+func (r *memoryAttendees) ExistsActive(userId, ticketId uint) (bool, error) {
+	count := r.attendees.Count(func(attendee Attendee) bool {
+		return attendee.UserID == userId && attendee.TicketID == ticketId && attendee.Status == AttendeeActive
+	})
+	return count > 0, nil
+}
+
+func (r *memoryAttendees) ListByTickets(ticketIds []uint) ([]Attendee, error) {
+	wanted := map[uint]bool{}
+	for _, id := range ticketIds {
+		wanted[id] = true
+	}
+	return r.attendees.Where(func(attendee Attendee) bool {
+		return wanted[attendee.TicketID]
+	}), nil
+}
+
+func (r *memoryAttendees) ListByUser(userId uint, ticketIds []uint) ([]Attendee, error) {
+	wanted := map[uint]bool{}
+	for _, id := range ticketIds {
+		wanted[id] = true
+	}
+	return r.attendees.Where(func(attendee Attendee) bool {
+		return attendee.UserID == userId && wanted[attendee.TicketID]
+	}), nil
+}
+
+func (r *memoryAttendees) ListByOwner(userId uint) ([]Attendee, error) {
+	attendees := r.attendees.Where(func(attendee Attendee) bool {
+		return attendee.UserID == userId
+	})
+	sort.SliceStable(attendees, func(i, j int) bool {
+		return attendees[i].CreatedAt.After(attendees[j].CreatedAt)
+	})
+	return attendees, nil
+}
+
+func (r *memoryAttendees) FindByID(id uint) (Attendee, error) {
+	return r.attendees.Get(id)
+}
+
+func (r *memoryAttendees) FindByIDForUpdate(id uint) (Attendee, error) {
+	return r.attendees.Get(id)
+}
+
+func (r *memoryAttendees) FindByCodeForUpdate(code string) (Attendee, error) {
+	return r.attendees.First(func(attendee Attendee) bool {
+		return attendee.Code == code
+	})
+}
+
+func (r *memoryAttendees) Save(attendee *Attendee) error {
+	return r.attendees.Save(attendee)
+}
+
+type memoryMembers struct {
+	members *repository.Table[EventMember]
+}
+
+func (r *memoryMembers) Create(member *EventMember) error {
+	if exists, _ := r.ExistsByEmail(member.EventID, member.Email); exists {
+		return repository.ErrDuplicate
+	}
+	r.members.Insert(member)
+	return nil
+}
+
+func (r *memoryMembers) FindByID(eventId, memberId uint) (EventMember, error) {
+	member, err := r.members.Get(memberId)
+	if err != nil || member.EventID != eventId {
+		return EventMember{}, repository.ErrNotFound
+	}
+	return member, nil
+}
+
+func (r *memoryMembers) FindActive(eventId, userId uint) (EventMember, error) {
+	return r.members.First(func(member EventMember) bool {
+		return member.EventID == eventId && member.UserID != nil &&
+			*member.UserID == userId && member.Status == MemberActive
+	})
+}
+
+func (r *memoryMembers) FindInvite(eventId uint, email string) (EventMember, error) {
+	return r.members.First(func(member EventMember) bool {
+		return member.EventID == eventId && member.Email == email && member.Status == MemberInvited
+	})
+}
+
+func (r *memoryMembers) ExistsByEmail(eventId uint, email string) (bool, error) {
+	count := r.members.Count(func(member EventMember) bool {
+		return member.EventID == eventId && member.Email == email
+	})
+	return count > 0, nil
+}
+
+func (r *memoryMembers) ListByEvent(eventId uint) ([]EventMember, error) {
+	return r.members.Where(func(member EventMember) bool {
+		return member.EventID == eventId
+	}), nil
+}
+
+func (r *memoryMembers) Save(member *EventMember) error {
+	return r.members.Save(member)
+}
+
+func (r *memoryMembers) Delete(id uint) error {
+	r.members.Delete(id)
+	return nil
+}
+
+type memoryReservations struct {
+	reservations *repository.Table[Reservation]
+}
+
+func (r *memoryReservations) Create(reservation *Reservation) error {
+	r.reservations.Insert(reservation)
+	return nil
+}
+
+func (r *memoryReservations) FindByID(id uint) (Reservation, error) {
+	return r.reservations.Get(id)
+}
+
+func (r *memoryReservations) FindByIDForUpdate(id uint) (Reservation, error) {
+	return r.reservations.Get(id)
+}
+
+func (r *memoryReservations) CountHeld(userId, ticketId uint) (int64, error) {
+	count := r.reservations.Count(func(reservation Reservation) bool {
+		return reservation.UserID == userId && reservation.TicketID == ticketId &&
+			reservation.Status == ReservationHeld
+	})
+	return int64(count), nil
+}
+
+func (r *memoryReservations) ListExpiredForUpdate(now time.Time) ([]Reservation, error) {
+	return r.reservations.Where(func(reservation Reservation) bool {
+		return reservation.Status == ReservationHeld && reservation.ExpiresAt.Before(now)
+	}), nil
+}
+
+func (r *memoryReservations) ListHeldByTickets(ticketIds []uint) ([]Reservation, error) {
+	wanted := map[uint]bool{}
+	for _, id := range ticketIds {
+		wanted[id] = true
+	}
+	return r.reservations.Where(func(reservation Reservation) bool {
+		return wanted[reservation.TicketID] && reservation.Status == ReservationHeld
+	}), nil
+}
+
+func (r *memoryReservations) Save(reservation *Reservation) error {
+	return r.reservations.Save(reservation)
+}
+
+type memoryReviews struct {
+	reviews *repository.Table[Review]
+	flags *repository.Table[ReviewFlag]
+}
+
+func (r *memoryReviews) Create(review *Review) error {
+	exists := r.reviews.Count(func(existing Review) bool {
+		return existing.EventID == review.EventID && existing.UserID == review.UserID
+	})
+	if exists > 0 {
+		return repository.ErrDuplicate
+	}
+	r.reviews.Insert(review)
+	return nil
+}
+
+func (r *memoryReviews) FindByID(id uint) (Review, error) {
+	return r.reviews.Get(id)
+}
+
+func (r *memoryReviews) FindByUser(eventId, userId uint) (Review, error) {
+	return r.reviews.First(func(review Review) bool {
+		return review.EventID == eventId && review.UserID == userId
+	})
+}
+
+func (r *memoryReviews) ListByEvents(eventIds []uint, status string) ([]Review, error) {
+	wanted := map[uint]bool{}
+	for _, id := range eventIds {
+		wanted[id] = true
+	}
+	reviews := r.reviews.Where(func(review Review) bool {
+		return wanted[review.EventID] && review.Status == status
+	})
+	sort.SliceStable(reviews, func(i, j int) bool {
+		return reviews[i].CreatedAt.After(reviews[j].CreatedAt)
+	})
+	return reviews, nil
+}
+
+func (r *memoryReviews) ListForModeration() ([]Review, error) {
+	flagged := map[uint]bool{}
+	for _, flag := range r.flags.Where(nil) {
+		if !flag.Resolved {
+			flagged[flag.ReviewID] = true
+		}
+	}
+	return r.reviews.Where(func(review Review) bool {
+		return review.Status == ReviewPending || flagged[review.ID]
+	}), nil
+}
+
+func (r *memoryReviews) Save(review *Review) error {
+	return r.reviews.Save(review)
+}
+
+type memoryReviewFlags struct {
+	flags *repository.Table[ReviewFlag]
+}
+
+func (r *memoryReviewFlags) Create(flag *ReviewFlag) error {
+	exists := r.flags.Count(func(existing ReviewFlag) bool {
+		return existing.ReviewID == flag.ReviewID && existing.UserID == flag.UserID
+	})
+	if exists > 0 {
+		return repository.ErrDuplicate
+	}
+	r.flags.Insert(flag)
+	return nil
+}
+
+func (r *memoryReviewFlags) CountOpen(reviewId uint) (int64, error) {
+	count := r.flags.Count(func(flag ReviewFlag) bool {
+		return flag.ReviewID == reviewId && !flag.Resolved
+	})
+	return int64(count), nil
+}
+
+func (r *memoryReviewFlags) ListOpen(reviewIds []uint) ([]ReviewFlag, error) {
+	wanted := map[uint]bool{}
+	for _, id := range reviewIds {
+		wanted[id] = true
+	}
+	return r.flags.Where(func(flag ReviewFlag) bool {
+		return wanted[flag.ReviewID] && !flag.Resolved
+	}), nil
+}
+
+func (r *memoryReviewFlags) ResolveByReview(reviewId uint) error {
+	for _, flag := range r.flags.Where(func(flag ReviewFlag) bool {
+		return flag.ReviewID == reviewId && !flag.Resolved
+	}) {
+		err := r.flags.Modify(flag.ID, func(row *ReviewFlag) error {
+			row.Resolved = true
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type memoryCheckIns struct {
+	checkIns *repository.Table[CheckIn]
+}
+
+func (r *memoryCheckIns) Create(checkIn *CheckIn) error {
+	r.checkIns.Insert(checkIn)
+	return nil
+}
+
+func (r *memoryCheckIns) ListByAttendee(attendeeId uint) ([]CheckIn, error) {
+	checkIns := r.checkIns.Where(func(checkIn CheckIn) bool {
+		return checkIn.AttendeeID == attendeeId
+	})
+	sort.SliceStable(checkIns, func(i, j int) bool {
+		return checkIns[i].CheckedInAt.Before(checkIns[j].CheckedInAt)
+	})
+	return checkIns, nil
+}
+
+func (r *memoryCheckIns) ListByEvent(eventId uint) ([]CheckIn, error) {
+	checkIns := r.checkIns.Where(func(checkIn CheckIn) bool {
+		return checkIn.EventID == eventId
+	})
+	sort.SliceStable(checkIns, func(i, j int) bool {
+		return checkIns[i].CheckedInAt.After(checkIns[j].CheckedInAt)
+	})
+	return checkIns, nil
+}
+
+func (r *memoryCheckIns) FindByTicketCode(codeId uint) (CheckIn, error) {
+	return r.checkIns.First(func(checkIn CheckIn) bool {
+		return checkIn.TicketCodeID != nil && *checkIn.TicketCodeID == codeId
+	})
+}
+
+func (r *memoryCheckIns) Save(checkIn *CheckIn) error {
+	return r.checkIns.Save(checkIn)
+}
+
+type memoryTicketCodes struct {
+	codes *repository.Table[TicketCode]
+}
+
+func (r *memoryTicketCodes) Create(code *TicketCode) error {
+	exists := r.codes.Count(func(existing TicketCode) bool {
+		return existing.Serial == code.Serial
+	})
+	if exists > 0 {
+		return repository.ErrDuplicate
+	}
+	r.codes.Insert(code)
+	return nil
+}
+
+func (r *memoryTicketCodes) FindBySerial(serial string) (TicketCode, error) {
+	return r.codes.First(func(code TicketCode) bool {
+		return code.Serial == serial
+	})
+}
+
+func (r *memoryTicketCodes) FindBySerialForUpdate(serial string) (TicketCode, error) {
+	return r.FindBySerial(serial)
+}
+
+func (r *memoryTicketCodes) ListByAttendees(attendeeIds []uint) ([]TicketCode, error) {
+	wanted := map[uint]bool{}
+	for _, id := range attendeeIds {
+		wanted[id] = true
+	}
+	codes := r.codes.Where(func(code TicketCode) bool {
+		return wanted[code.AttendeeID]
+	})
+	sort.SliceStable(codes, func(i, j int) bool {
+		if codes[i].AttendeeID != codes[j].AttendeeID {
+			return codes[i].AttendeeID < codes[j].AttendeeID
+		}
+		return codes[i].Unit < codes[j].Unit
+	})
+	return codes, nil
+}
+
+func (r *memoryTicketCodes) ListByEvent(eventId uint) ([]TicketCode, error) {
+	codes := r.codes.Where(func(code TicketCode) bool {
+		return code.EventID == eventId
+	})
+	sort.SliceStable(codes, func(i, j int) bool {
+		return codes[i].Serial < codes[j].Serial
+	})
+	return codes, nil
+}
+
+func (r *memoryTicketCodes) Save(code *TicketCode) error {
+	return r.codes.Save(code)
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+// sortEvents orders the events the way eventOrder does in SQL.
+func sortEvents(events []Event, order string, tickets map[uint][]Ticket) {
+	minPrice := func(event Event) (float64, bool) {
+		if len(tickets[event.ID]) == 0 {
+			return 0, false
+		}
+		price := tickets[event.ID][0].Price
+		for _, ticket := range tickets[event.ID] {
+			if ticket.Price < price {
+				price = ticket.Price
+			}
+		}
+		return price, true
+	}
+	sold := func(event Event) uint {
+		var total uint
+		for _, ticket := range tickets[event.ID] {
+			total += ticket.Sold
+		}
+		return total
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		a, b := events[i], events[j]
+		switch order {
+		case SortDate:
+			if !a.EventDate.Equal(b.EventDate) {
+				return a.EventDate.Before(b.EventDate)
+			}
+			return a.ID < b.ID
+		case SortDateDesc:
+			if !a.EventDate.Equal(b.EventDate) {
+				return a.EventDate.After(b.EventDate)
+			}
+			return a.ID > b.ID
+		case SortPrice, SortPriceDesc:
+			priceA, okA := minPrice(a)
+			priceB, okB := minPrice(b)
+			if okA != okB {
+				return okA
+			}
+			if priceA != priceB {
+				if order == SortPrice {
+					return priceA < priceB
+				}
+				return priceA > priceB
+			}
+			if order == SortPrice {
+				return a.ID < b.ID
+			}
+			return a.ID > b.ID
+		case SortPopularity:
+			if sold(a) != sold(b) {
+				return sold(a) > sold(b)
+			}
+			return a.ID > b.ID
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID > b.ID
+	})
+}
+
+func newestFirst(events []Event) []Event {
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].CreatedAt.After(events[j].CreatedAt)
+	})
+	return events
+}
@@ -0,0 +1,390 @@
+package events
+
+import (
+	"avana/internal/repository"
+	"errors"
+	"time"
+)
+
+// ReviewEditWindow is how long after writing a review its author may still change it.
+const ReviewEditWindow = 7 * 24 * time.Hour
+
+// ReviewFlagThreshold is how many open flags take a review out of public
+// view until a moderator looks at it.
+const ReviewFlagThreshold = 3
+
+const (
+	ModerationApprove string = "approve"
+	ModerationHide string = "hide"
+)
+
+// ModerationItem is a review waiting in the moderation queue with the flags
+// raised against it.
+type ModerationItem struct {
+	Review Review
+	Flags []ReviewFlag
+}
+
+// RatingStats aggregates the published ratings of one or more events.
+type RatingStats struct {
+	Count int
+	// Average is nil until there is a rating
+	Average *float64
+	// Distribution counts the reviews at each rating from 1 to 5
+	Distribution map[uint]int
+}
+
+// Review records the attendee's rating of an event once it has been held.
+// Each user reviews an event once, however many tickets they bought for it.
+func (s *EventService) Review(actor Actor, eventId uint, schema ReviewSchema) (GetAllReviewSchema, error) {
+	var review Review
+
+	err := withTransaction(s.store, func(tx Tx) error {
+		attendees, err := s.reviewerAttendees(tx, actor.ID, eventId)
+		if err != nil {
+			return err
+		}
+
+		review = Review{
+			EventID: eventId,
+			UserID: actor.ID,
+			Rating: schema.Rating,
+			Body: schema.Review,
+			Status: ReviewPublished,
+		}
+		for _, attendee := range attendees {
+			if attendee.Attended {
+				review.VerifiedAttendee = true
+			}
+		}
+
+		err = tx.Reviews().Create(&review)
+		if errors.Is(err, repository.ErrDuplicate) {
+			return ErrAlreadyReviewed
+		}
+		return err
+	})
+	if err != nil {
+		return GetAllReviewSchema{}, err
+	}
+
+	return s.toReview(review)
+}
+
+// EditReview changes the user's review of the event within ReviewEditWindow
+// of first writing it. Reviews a moderator has hidden stay as they are.
+func (s *EventService) EditReview(actor Actor, eventId uint, schema ReviewSchema) (GetAllReviewSchema, error) {
+	var review Review
+
+	err := withTransaction(s.store, func(tx Tx) error {
+		if _, err := s.reviewerAttendees(tx, actor.ID, eventId); err != nil {
+			return err
+		}
+
+		var err error
+		review, err = tx.Reviews().FindByUser(eventId, actor.ID)
+		if err != nil {
+			return err
+		}
+
+		if review.Status == ReviewHidden || time.Now().After(review.CreatedAt.Add(ReviewEditWindow)) {
+			return ErrReviewLocked
+		}
+
+		review.Rating = schema.Rating
+		review.Body = schema.Review
+		return tx.Reviews().Save(&review)
+	})
+	if err != nil {
+		return GetAllReviewSchema{}, err
+	}
+
+	return s.toReview(review)
+}
+
+// reviewerAttendees returns the user's active attendee rows for the event,
+// failing unless the user may review it: the event has been held and the
+// user holds a ticket that was not cancelled.
+func (s *EventService) reviewerAttendees(tx Tx, userId uint, eventId uint) ([]Attendee, error) {
+	event, err := tx.Events().FindByID(eventId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !time.Now().After(event.EventDate) {
+		return nil, ErrEventNotHeld
+	}
+
+	tickets, err := tx.Tickets().ListByEvent(event.ID)
+	if err != nil {
+		return nil, err
+	}
+	ticketIds := make([]uint, 0, len(tickets))
+	for _, ticket := range tickets {
+		ticketIds = append(ticketIds, ticket.ID)
+	}
+
+	attendees, err := tx.Attendees().ListByUser(userId, ticketIds)
+	if err != nil {
+		return nil, err
+	}
+
+	active := make([]Attendee, 0, len(attendees))
+	for _, attendee := range attendees {
+		if attendee.Status == AttendeeActive {
+			active = append(active, attendee)
+		}
+	}
+	if len(active) == 0 {
+		return nil, ErrNotAttendee
+	}
+	return active, nil
+}
+
+func (s *EventService) toReview(review Review) (GetAllReviewSchema, error) {
+	user, err := s.users.FindByID(review.UserID)
+	if err != nil {
+		return GetAllReviewSchema{}, err
+	}
+	return newReview(review, user.FirstName+" "+user.LastName), nil
+}
+
+func newReview(review Review, reviewer string) GetAllReviewSchema {
+	return GetAllReviewSchema{
+		ID: review.ID,
+		Reviewer: reviewer,
+		Rating: review.Rating,
+		Review: review.Body,
+		Date: review.CreatedAt,
+		VerifiedAttendee: review.VerifiedAttendee,
+		Reply: review.Reply,
+		RepliedAt: review.RepliedAt,
+	}
+}
+
+// Reviews returns the event's published reviews newest first once it has
+// held, ErrEventNotHeld before that.
+func (s *EventService) Reviews(viewer Actor, eventId uint) ([]GetAllReviewSchema, error) {
+	// drafts stay hidden outside the team, as they are on the event itself
+	event, err := s.Get(viewer, eventId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !time.Now().After(event.EventDate) {
+		return nil, ErrEventNotHeld
+	}
+
+	published, err := s.store.Reviews().ListByEvents([]uint{event.ID}, ReviewPublished)
+	if err != nil {
+		return nil, err
+	}
+
+	userIds := make([]uint, 0, len(published))
+	for _, review := range published {
+		userIds = append(userIds, review.UserID)
+	}
+	reviewers, err := s.users.FindByIDs(userIds)
+	if err != nil {
+		return nil, err
+	}
+	names := map[uint]string{}
+	for _, user := range reviewers {
+		names[user.ID] = user.FirstName + " " + user.LastName
+	}
+
+	reviews := make([]GetAllReviewSchema, 0, len(published))
+	for _, review := range published {
+		reviews = append(reviews, newReview(review, names[review.UserID]))
+	}
+	return reviews, nil
+}
+
+// Flag reports a published review to the moderators. Once ReviewFlagThreshold
+// users have flagged it the review is held back until it is moderated.
+func (s *EventService) Flag(actor Actor, reviewId uint, schema FlagReviewSchema) (ReviewFlag, error) {
+	var flag ReviewFlag
+
+	err := withTransaction(s.store, func(tx Tx) error {
+		review, err := tx.Reviews().FindByID(reviewId)
+		if err != nil {
+			return err
+		}
+
+		// only published reviews are visible to flag
+		if review.Status != ReviewPublished {
+			return repository.ErrNotFound
+		}
+		if review.UserID == actor.ID {
+			return ErrOwnReview
+		}
+
+		flag = ReviewFlag{
+			ReviewID: review.ID,
+			UserID: actor.ID,
+			Reason: schema.Reason,
+			Note: schema.Note,
+		}
+		err = tx.ReviewFlags().Create(&flag)
+		if errors.Is(err, repository.ErrDuplicate) {
+			return ErrAlreadyFlagged
+		}
+		if err != nil {
+			return err
+		}
+
+		open, err := tx.ReviewFlags().CountOpen(review.ID)
+		if err != nil {
+			return err
+		}
+		if open < ReviewFlagThreshold {
+			return nil
+		}
+
+		review.Status = ReviewPending
+		return tx.Reviews().Save(&review)
+	})
+
+	return flag, err
+}
+
+// ModerationQueue returns the reviews awaiting a moderator, oldest first.
+func (s *EventService) ModerationQueue() ([]ModerationItem, error) {
+	reviews, err := s.store.Reviews().ListForModeration()
+	if err != nil {
+		return nil, err
+	}
+
+	reviewIds := make([]uint, 0, len(reviews))
+	for _, review := range reviews {
+		reviewIds = append(reviewIds, review.ID)
+	}
+	flags, err := s.store.ReviewFlags().ListOpen(reviewIds)
+	if err != nil {
+		return nil, err
+	}
+
+	flagsByReview := map[uint][]ReviewFlag{}
+	for _, flag := range flags {
+		flagsByReview[flag.ReviewID] = append(flagsByReview[flag.ReviewID], flag)
+	}
+
+	queue := make([]ModerationItem, 0, len(reviews))
+	for _, review := range reviews {
+		flags := flagsByReview[review.ID]
+		if flags == nil {
+			flags = []ReviewFlag{}
+		}
+		queue = append(queue, ModerationItem{
+			Review: review,
+			Flags: flags,
+		})
+	}
+	return queue, nil
+}
+
+// Moderate approves or hides a review, resolving the flags raised against it.
+func (s *EventService) Moderate(actor Actor, reviewId uint, schema ModerateReviewSchema) (Review, error) {
+	var review Review
+
+	err := withTransaction(s.store, func(tx Tx) error {
+		var err error
+		review, err = tx.Reviews().FindByID(reviewId)
+		if err != nil {
+			return err
+		}
+
+		review.Status = ReviewPublished
+		if schema.Action == ModerationHide {
+			review.Status = ReviewHidden
+		}
+		now := time.Now()
+		review.ModerationNote = schema.Note
+		review.ModeratedByID = &actor.ID
+		review.ModeratedAt = &now
+		if err = tx.Reviews().Save(&review); err != nil {
+			return err
+		}
+
+		return tx.ReviewFlags().ResolveByReview(review.ID)
+	})
+
+	return review, err
+}
+
+// Reply sets the organiser's public answer to a review, replacing any
+// earlier one.
+func (s *EventService) Reply(actor Actor, reviewId uint, schema ReplyReviewSchema) (GetAllReviewSchema, error) {
+	review, err := s.store.Reviews().FindByID(reviewId)
+	if err != nil {
+		return GetAllReviewSchema{}, err
+	}
+
+	event, err := s.store.Events().FindByID(review.EventID)
+	if err != nil {
+		return GetAllReviewSchema{}, err
+	}
+	if err = s.Authorize(actor, event, EventPermEdit); err != nil {
+		return GetAllReviewSchema{}, err
+	}
+
+	now := time.Now()
+	review.Reply = schema.Reply
+	review.RepliedByID = &actor.ID
+	review.RepliedAt = &now
+	if err = s.store.Reviews().Save(&review); err != nil {
+		return GetAllReviewSchema{}, err
+	}
+
+	return s.toReview(review)
+}
+
+// EventRatingStats aggregates the published ratings of an event the viewer can see.
+func (s *EventService) EventRatingStats(viewer Actor, eventId uint) (RatingStats, error) {
+	event, err := s.Get(viewer, eventId)
+	if err != nil {
+		return RatingStats{}, err
+	}
+	return s.ratingStats([]uint{event.ID})
+}
+
+// OrganiserRatingStats aggregates the published ratings across every event
+// the user organises.
+func (s *EventService) OrganiserRatingStats(organiserId uint) (RatingStats, error) {
+	events, err := s.store.Events().ListByUser(organiserId)
+	if err != nil {
+		return RatingStats{}, err
+	}
+
+	eventIds := make([]uint, 0, len(events))
+	for _, event := range events {
+		eventIds = append(eventIds, event.ID)
+	}
+	return s.ratingStats(eventIds)
+}
+
+func (s *EventService) ratingStats(eventIds []uint) (RatingStats, error) {
+	reviews, err := s.store.Reviews().ListByEvents(eventIds, ReviewPublished)
+	if err != nil {
+		return RatingStats{}, err
+	}
+	return newRatingStats(reviews), nil
+}
+
+func newRatingStats(reviews []Review) RatingStats {
+	stats := RatingStats{
+		Distribution: map[uint]int{1: 0, 2: 0, 3: 0, 4: 0, 5: 0},
+	}
+
+	var total uint
+	for _, review := range reviews {
+		stats.Count++
+		stats.Distribution[review.Rating]++
+		total += review.Rating
+	}
+	if stats.Count > 0 {
+		average := float64(total) / float64(stats.Count)
+		stats.Average = &average
+	}
+	return stats
+}
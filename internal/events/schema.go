@@ -1,61 +1,154 @@
 package events
 
+import "time"
+
 type CreateEventSchema struct {
-	Name string
-	Location string
-	Organiser string
+	Name string							`binding:"required,max=200"`
+	Location string						`binding:"required,max=200"`
+	Organiser string					`binding:"required,max=200"`
 	IsPaidEvent bool
 	IsLimitedEvent bool
-	Description string
-	MaxUnitReservation uint
-	EventDate string
-	RegistrationExpirationDate string
-	TotalTicketLimit uint
-	Tickets []TicketSchema
+	Description string					`binding:"max=5000"`
+	MaxUnitReservation uint				`binding:"required,min=1"`
+	EventDate string					`binding:"required,date"`
+	RegistrationExpirationDate string	`binding:"required,date,datebefore=EventDate"`
+	TotalTicketLimit uint				`binding:"required_if=IsLimitedEvent true"`
+	Tickets []TicketSchema				`binding:"dive"`
 }
 
 
 type TicketSchema struct {
-	Name string
-	Price float64
-	TotalAvailable uint
-	SingleLimit uint
-	ExpiryTime string
+	Name string				`binding:"required,max=100"`
+	Price float64			`binding:"gte=0"`
+	// TotalAvailable is left out for a ticket without a limit
+	TotalAvailable *uint	`binding:"omitempty,min=1"`
+	SingleLimit uint		`binding:"required,min=1"`
+	ExpiryTime string		`binding:"required,date"`
 }
 
 
 type UpdateTicketSchema struct {
-	Name          string   `binding:"omitempty"` 
-	Price         *float64   `binding:"omitempty"` 
-	TotalAvailable *uint      `binding:"omitempty"` 
-	SingleLimit   *uint     `binding:"omitempty"` 
-	ExpiryTime    string `binding:"omitempty"` 
+	Name          string   `binding:"omitempty,max=100"` 
+	Price         *float64   `binding:"omitempty,gte=0"` 
+	TotalAvailable *uint      `binding:"omitempty,min=1"` 
+	// Unlimited lifts the ticket's limit
+	Unlimited     bool       `binding:"excluded_with=TotalAvailable"`
+	SingleLimit   *uint     `binding:"omitempty,min=1"` 
+	ExpiryTime    string `binding:"omitempty,date"` 
 }
 
 type UpdateEventSchema struct {
-	Name                      string `binding:"omitempty"`                      // optional
-	Location                  string `binding:"omitempty"`                  // optional
-	Organiser                 string `binding:"omitempty"`                 // optional
+	Name                      string `binding:"omitempty,max=200"`                      // optional
+	Location                  string `binding:"omitempty,max=200"`                  // optional
+	Organiser                 string `binding:"omitempty,max=200"`                 // optional
 	IsPaidEvent               *bool  `binding:"omitempty"`             // optional
 	IsLimitedEvent 			  *bool  `binding:"omitempty"`
-	Description               string `binding:"omitempty"`               // optional
-	MaxUnitReservation        *uint  `binding:"omitempty"`      // optional
-	EventDate                 string `binding:"omitempty"`            // optional
-	RegistrationExpirationDate string `binding:"omitempty"` // optional
+	Description               string `binding:"omitempty,max=5000"`               // optional
+	MaxUnitReservation        *uint  `binding:"omitempty,min=1"`      // optional
+	EventDate                 string `binding:"omitempty,date"`            // optional
+	RegistrationExpirationDate string `binding:"omitempty,date,datebefore=EventDate"` // optional
 	TotalTicketLimit 		   *uint	`binding:"omitempty"`		// optional
 }
 
+// EventQuerySchema is the query string of GET /event/all.
+type EventQuerySchema struct {
+	Search string			`form:"q" binding:"omitempty,max=200"`
+	Location string			`form:"location" binding:"omitempty,max=200"`
+	Organiser string		`form:"organiser" binding:"omitempty,max=200"`
+	From string				`form:"from" binding:"omitempty,datetime=2006-01-02 15:04:05"`
+	To string				`form:"to" binding:"omitempty,datetime=2006-01-02 15:04:05"`
+	Paid *bool				`form:"paid"`
+	Available *bool			`form:"available"`
+	Status string			`form:"status" binding:"omitempty,oneof=published postponed cancelled completed"`
+	Sort string				`form:"sort" binding:"omitempty,oneof=newest date -date price -price popularity"`
+	Page int				`form:"page" binding:"omitempty,min=1"`
+	PageSize int			`form:"pageSize" binding:"omitempty,min=1,max=100"`
+}
+
+type PostponeEventSchema struct {
+	Reason string						`binding:"required,max=1000"`
+	EventDate string					`binding:"omitempty,date"`
+	RegistrationExpirationDate string	`binding:"omitempty,date,datebefore=EventDate"`
+}
+
+type CancelEventSchema struct {
+	Reason string			`binding:"required,max=1000"`
+}
+
 type BuyTicketScema struct {
-	Units uint
+	Units uint				`binding:"required,min=1"`
 }
 
 type GetAllAttendees struct {
 	Email string
 	TicketType string
+	Units uint
+	// Amount is what the units cost at the ticket's price
 	Amount float64
+	Status string
+	CheckedIn uint
 }
 
 type GetAllReviewSchema struct {
+	ID uint
+	Reviewer string
+	Rating uint
+	Review string
+	Date time.Time
+	// VerifiedAttendee is set when the reviewer was checked in at the event
+	VerifiedAttendee bool
+	// Reply is the organiser's public answer to the review
+	Reply string
+	RepliedAt *time.Time
+}
+
+type ReviewSchema struct {
+	Rating uint				`binding:"required,min=1,max=5"`
+	Review string			`binding:"max=2000"`
+}
+
+type CheckInSchema struct {
+	Code string				`binding:"required"`
+	// Units defaults to every unit not yet checked in
+	Units uint				`binding:"omitempty,min=1"`
+}
 
+type SyncCheckInsSchema struct {
+	// DeviceID names the scanner, it breaks ties between scans of one code
+	DeviceID string			`binding:"required,max=100"`
+	Scans []OfflineScanSchema	`binding:"required,min=1,max=1000,dive"`
 }
 
+type OfflineScanSchema struct {
+	// Code is a unit's signed code, door codes cannot be checked offline
+	Code string				`binding:"required"`
+	ScannedAt time.Time		`binding:"required"`
+}
+
+type TicketQRSchema struct {
+	Format string			`form:"format" binding:"omitempty,oneof=png svg"`
+	Size int				`form:"size" binding:"omitempty,min=64,max=1024"`
+}
+
+type TransferTicketSchema struct {
+	Email string			`binding:"required,email"`
+}
+
+type FlagReviewSchema struct {
+	Reason string			`binding:"required,oneof=spam offensive off_topic fake other"`
+	Note string				`binding:"max=500"`
+}
+
+type ModerateReviewSchema struct {
+	Action string			`binding:"required,oneof=approve hide"`
+	Note string				`binding:"max=500"`
+}
+
+type ReplyReviewSchema struct {
+	Reply string			`binding:"required,max=2000"`
+}
+
+type InviteMemberSchema struct {
+	Email string			`binding:"required,email"`
+	Role string				`binding:"required"`
+}
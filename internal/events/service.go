@@ -0,0 +1,1074 @@
+package events
+
+import (
+	"avana/internal/apperror"
+	"avana/internal/mailer"
+	"avana/internal/payments"
+	"avana/internal/repository"
+	"avana/internal/tokens"
+	"avana/internal/users"
+	"avana/internal/utils"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+// Actor is the user a service call is made on behalf of.
+type Actor struct {
+	ID uint
+	Role string
+}
+
+func (a Actor) can(permission string) bool {
+	return users.HasPermission(a.Role, permission)
+}
+
+// OrderCurrency is the currency orders are created and charged in.
+var OrderCurrency = "NGN"
+
+// Purchase is the outcome of buying or checking out a ticket. Free tickets
+// create the attendee straight away, paid ones leave an order to be paid.
+type Purchase struct {
+	Attendee *Attendee
+	Order *payments.Order
+	Intent payments.Intent
+}
+
+// EventService holds the rules for creating and running events.
+type EventService struct {
+	store Store
+	users users.UserRepository
+	payments payments.PaymentProvider
+	mailer mailer.Mailer
+	signer *tokens.TicketSigner
+}
+
+func NewEventService(store Store, userRepo users.UserRepository, provider payments.PaymentProvider, mail mailer.Mailer, signer *tokens.TicketSigner) *EventService {
+	return &EventService{
+		store: store,
+		users: userRepo,
+		payments: provider,
+		mailer: mail,
+		signer: signer,
+	}
+}
+
+// Get returns the event as the viewer may see it, a draft is not found
+// for anyone outside its team. An anonymous viewer has a zero Actor.
+func (s *EventService) Get(viewer Actor, eventId uint) (Event, error) {
+	event, err := s.store.Events().FindByID(eventId)
+	if err != nil {
+		return Event{}, err
+	}
+	if err = s.canView(viewer, event); err != nil {
+		return Event{}, err
+	}
+	return event, nil
+}
+
+// List returns one page of the public event listing.
+func (s *EventService) List(schema EventQuerySchema) (EventPage, error) {
+	query, err := newEventQuery(schema)
+	if err != nil {
+		return EventPage{}, err
+	}
+
+	events, total, err := s.store.Events().List(query)
+	if err != nil {
+		return EventPage{}, err
+	}
+
+	return EventPage{
+		Events: events,
+		Total: total,
+		Page: query.Page,
+		PageSize: query.PageSize,
+	}, nil
+}
+
+func (s *EventService) ListByUser(userId uint) ([]Event, error) {
+	return s.store.Events().ListByUser(userId)
+}
+
+// Create saves the event with its tickets and makes the actor its owner.
+// An event without tickets gets a single free "Regular" ticket.
+func (s *EventService) Create(actor Actor, schema CreateEventSchema) (Event, error) {
+	eventDate, err := utils.ValidateDate(schema.EventDate)
+	if err != nil {
+		return Event{}, invalidDate("EventDate")
+	}
+
+	regExpDate, err := utils.ValidateDate(schema.RegistrationExpirationDate)
+	if err != nil {
+		return Event{}, invalidDate("RegistrationExpirationDate")
+	}
+	if !regExpDate.Before(eventDate) {
+		return Event{}, ErrRegistrationAfterEvent
+	}
+
+	event := Event{
+		Name: schema.Name,
+		Organiser: schema.Organiser,
+		Location: schema.Location,
+		IsPaidEvent: schema.IsPaidEvent,
+		Description: schema.Description,
+		IsLimited: schema.IsLimitedEvent,
+		MaxUnitReservation: schema.MaxUnitReservation,
+		EventDate: eventDate,
+		RegistrationExpirationDate: regExpDate,
+		UserID: actor.ID,
+		Status: EventDraft,
+	}
+
+	tickets := schema.Tickets
+	if len(tickets) == 0 {
+		ticket := TicketSchema{
+			Name: "Regular",
+			Price: 0,
+			SingleLimit: schema.MaxUnitReservation,
+			ExpiryTime: schema.RegistrationExpirationDate,
+		}
+		if schema.IsLimitedEvent {
+			limit := schema.TotalTicketLimit
+			ticket.TotalAvailable = &limit
+		}
+		tickets = []TicketSchema{ticket}
+	}
+
+	// the owner's membership is addressed to their email
+	owner, err := s.users.FindByID(actor.ID)
+	if err != nil {
+		return Event{}, err
+	}
+
+	err = withTransaction(s.store, func(tx Tx) error {
+		if err := tx.Events().Create(&event); err != nil {
+			return err
+		}
+		if err := addOwner(tx, event, owner.Email); err != nil {
+			return err
+		}
+		for _, ticketSchema := range tickets {
+			ticket, err := newTicket(event, ticketSchema)
+			if err != nil {
+				return err
+			}
+			if err = tx.Tickets().Create(&ticket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return Event{}, err
+	}
+	return event, nil
+}
+
+func (s *EventService) Update(actor Actor, eventId uint, schema UpdateEventSchema) (Event, error) {
+	event, err := s.store.Events().FindByID(eventId)
+	if err != nil {
+		return Event{}, err
+	}
+
+	if err = s.Authorize(actor, event, EventPermEdit); err != nil {
+		return Event{}, err
+	}
+
+	if isClosed(event) {
+		return Event{}, ErrEventClosed
+	}
+
+	event, err = getEventUpdateData(schema, event)
+	if err != nil {
+		return Event{}, err
+	}
+
+	if err = s.store.Events().Save(&event); err != nil {
+		return Event{}, err
+	}
+	return event, nil
+}
+
+// Attendees lists who holds tickets for the event, with their email and ticket name.
+func (s *EventService) Attendees(actor Actor, eventId uint) ([]GetAllAttendees, error) {
+	event, err := s.store.Events().FindByID(eventId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !actor.can(users.PermViewAnyAttendees) {
+		if err = s.Authorize(actor, event, EventPermViewAttendees); err != nil {
+			return nil, err
+		}
+	}
+
+	tickets, err := s.store.Tickets().ListByEvent(event.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	ticketNames := map[uint]string{}
+	ticketPrices := map[uint]float64{}
+	for _, ticket := range tickets {
+		ticketNames[ticket.ID] = ticket.Name
+		ticketPrices[ticket.ID] = ticket.Price
+	}
+
+	attendees, err := eventAttendees(s.store, tickets)
+	if err != nil {
+		return nil, err
+	}
+
+	userIds := make([]uint, 0, len(attendees))
+	for _, attendee := range attendees {
+		userIds = append(userIds, attendee.UserID)
+	}
+	attendeeUsers, err := s.users.FindByIDs(userIds)
+	if err != nil {
+		return nil, err
+	}
+	emails := map[uint]string{}
+	for _, user := range attendeeUsers {
+		emails[user.ID] = user.Email
+	}
+
+	result := make([]GetAllAttendees, 0, len(attendees))
+	for _, attendee := range attendees {
+		result = append(result, GetAllAttendees{
+			Email: emails[attendee.UserID],
+			TicketType: ticketNames[attendee.TicketID],
+			Units: attendee.Units,
+			Amount: ticketPrices[attendee.TicketID] * float64(attendee.Units),
+			Status: attendee.Status,
+			CheckedIn: attendee.CheckedIn,
+		})
+	}
+	return result, nil
+}
+
+// Authorize checks the actor's role on the event's team. The creator is
+// always treated as owner, and roles that can manage every event skip the check.
+func (s *EventService) Authorize(actor Actor, event Event, permission string) error {
+	if actor.can(users.PermManageAnyEvent) || event.UserID == actor.ID {
+		return nil
+	}
+
+	member, err := s.store.Members().FindActive(event.ID, actor.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrNoPermission
+		}
+		return err
+	}
+
+	if !memberCan(member.Role, permission) {
+		return ErrNoPermission
+	}
+	return nil
+}
+
+// TicketService holds the rules for selling tickets: inventory, holds and payment.
+type TicketService struct {
+	store Store
+	events *EventService
+	payments payments.PaymentProvider
+}
+
+func NewTicketService(store Store, events *EventService, provider payments.PaymentProvider) *TicketService {
+	return &TicketService{
+		store: store,
+		events: events,
+		payments: provider,
+	}
+}
+
+// Get returns the ticket unless its event is a draft the viewer may not see.
+func (s *TicketService) Get(viewer Actor, ticketId uint) (Ticket, error) {
+	ticket, err := s.store.Tickets().FindByID(ticketId)
+	if err != nil {
+		return Ticket{}, err
+	}
+	if _, err = s.events.Get(viewer, ticket.EventID); err != nil {
+		return Ticket{}, err
+	}
+	return ticket, nil
+}
+
+func (s *TicketService) ListByEvent(viewer Actor, eventId uint) ([]Ticket, error) {
+	if _, err := s.events.Get(viewer, eventId); err != nil {
+		return nil, err
+	}
+	return s.store.Tickets().ListByEvent(eventId)
+}
+
+func (s *TicketService) Add(actor Actor, eventId uint, schema TicketSchema) (Ticket, error) {
+	event, err := s.store.Events().FindByID(eventId)
+	if err != nil {
+		return Ticket{}, err
+	}
+
+	if err = s.events.Authorize(actor, event, EventPermManageTickets); err != nil {
+		return Ticket{}, err
+	}
+
+	ticket, err := newTicket(event, schema)
+	if err != nil {
+		return Ticket{}, err
+	}
+
+	if err = s.store.Tickets().Create(&ticket); err != nil {
+		return Ticket{}, err
+	}
+	return ticket, nil
+}
+
+func (s *TicketService) Update(actor Actor, ticketId uint, schema UpdateTicketSchema) (Ticket, error) {
+	event, err := s.store.Events().FindByTicketID(ticketId)
+	if err != nil {
+		return Ticket{}, err
+	}
+
+	if err = s.events.Authorize(actor, event, EventPermManageTickets); err != nil {
+		return Ticket{}, err
+	}
+
+	var ticket Ticket
+	err = withTransaction(s.store, func(tx Tx) error {
+		// lock the ticket so the limit is checked against what is sold now
+		ticket, err = tx.Tickets().FindByIDForUpdate(ticketId)
+		if err != nil {
+			return err
+		}
+
+		ticket, err = getTicketUpdateData(schema, ticket, event)
+		if err != nil {
+			return err
+		}
+		return tx.Tickets().Save(&ticket)
+	})
+	if err != nil {
+		return Ticket{}, err
+	}
+	return ticket, nil
+}
+
+func (s *TicketService) Delete(actor Actor, ticketId uint) error {
+	event, err := s.store.Events().FindByTicketID(ticketId)
+	if err != nil {
+		return err
+	}
+
+	if err = s.events.Authorize(actor, event, EventPermManageTickets); err != nil {
+		return err
+	}
+
+	return withTransaction(s.store, func(tx Tx) error {
+		// lock the ticket so nothing is sold while it is checked
+		ticket, err := tx.Tickets().FindByIDForUpdate(ticketId)
+		if err != nil {
+			return err
+		}
+		if err = ensureUnsold(tx, ticket); err != nil {
+			return err
+		}
+		return tx.Tickets().Delete(ticket.ID)
+	})
+}
+
+// ensureUnsold fails when anyone holds units of the ticket: an active
+// attendee, an order pending or paid, or a hold. Deleting it would hide
+// them from the event's cancellation and its refunds.
+func ensureUnsold(tx Tx, ticket Ticket) error {
+	ticketIds := []uint{ticket.ID}
+
+	attendees, err := tx.Attendees().ListByTickets(ticketIds)
+	if err != nil {
+		return err
+	}
+	for _, attendee := range attendees {
+		if attendee.Status == AttendeeActive {
+			return ErrTicketSold
+		}
+	}
+
+	for _, status := range []string{payments.OrderPending, payments.OrderPaid} {
+		orders, err := tx.Orders().ListByTickets(ticketIds, status)
+		if err != nil {
+			return err
+		}
+		if len(orders) > 0 {
+			return ErrTicketSold
+		}
+	}
+
+	held, err := tx.Reservations().ListHeldByTickets(ticketIds)
+	if err != nil {
+		return err
+	}
+	if len(held) > 0 {
+		return ErrTicketSold
+	}
+	return nil
+}
+
+// Buy sells units of a ticket to the user. Free tickets make the user an
+// attendee at once, paid tickets hold the units behind a pending order.
+func (s *TicketService) Buy(userId uint, ticketId uint, units uint) (Purchase, error) {
+	var purchase Purchase
+
+	err := withTransaction(s.store, func(tx Tx) error {
+		// lock the ticket row so parallel purchases are serialised
+		event, ticket, err := lockForSale(tx, ticketId)
+		if err != nil {
+			return err
+		}
+
+		if err = checkPurchasable(event, ticket, units); err != nil {
+			return err
+		}
+
+		if err = ensureNotAttending(tx, userId, ticket.ID); err != nil {
+			return err
+		}
+
+		if ticket.Price > 0 {
+			order, err := s.createOrder(tx, event, ticket, userId, units, true)
+			if err != nil {
+				return err
+			}
+			purchase = Purchase{Order: &order}
+			return nil
+		}
+
+		// take the units out of the inventory
+		if err = tx.Tickets().Reserve(ticket.ID, units); err != nil {
+			return err
+		}
+
+		attendee, err := createAttendee(tx, userId, ticket, units)
+		if err != nil {
+			return err
+		}
+		purchase = Purchase{Attendee: &attendee}
+		return nil
+	})
+	if err != nil || purchase.Order == nil {
+		return purchase, err
+	}
+
+	purchase.Intent, err = s.openIntent(*purchase.Order)
+	return purchase, err
+}
+
+// Hold takes units out of the inventory for ReservationTTL while the user checks out.
+func (s *TicketService) Hold(userId uint, ticketId uint, units uint) (Reservation, error) {
+	var reservation Reservation
+
+	err := withTransaction(s.store, func(tx Tx) error {
+		// lock the ticket row so parallel holds are serialised
+		event, ticket, err := lockForSale(tx, ticketId)
+		if err != nil {
+			return err
+		}
+
+		if err = checkPurchasable(event, ticket, units); err != nil {
+			return err
+		}
+
+		if err = ensureNotAttending(tx, userId, ticket.ID); err != nil {
+			return err
+		}
+
+		// one active hold per user per ticket
+		held, err := tx.Reservations().CountHeld(userId, ticket.ID)
+		if err != nil {
+			return err
+		}
+		if held > 0 {
+			return ErrHoldActive
+		}
+
+		if err = tx.Tickets().Reserve(ticket.ID, units); err != nil {
+			return err
+		}
+
+		reservation = Reservation{
+			UserID: userId,
+			TicketID: ticket.ID,
+			Units: units,
+			Status: ReservationHeld,
+			ExpiresAt: time.Now().Add(ReservationTTL),
+		}
+		return tx.Reservations().Create(&reservation)
+	})
+
+	return reservation, err
+}
+
+// ExtendHold restarts the hold's timer, never past the ticket's sale deadline.
+func (s *TicketService) ExtendHold(userId uint, reservationId uint) (Reservation, error) {
+	var reservation Reservation
+
+	err := withTransaction(s.store, func(tx Tx) error {
+		var err error
+		reservation, err = getActiveReservation(tx, reservationId, userId)
+		if err != nil {
+			return err
+		}
+
+		ticket, err := tx.Tickets().FindByID(reservation.TicketID)
+		if err != nil {
+			return err
+		}
+
+		// a hold on an event that stopped selling cannot be kept alive
+		event, err := tx.Events().FindByID(ticket.EventID)
+		if err != nil {
+			return err
+		}
+		if err = checkOnSale(event); err != nil {
+			return err
+		}
+
+		expiresAt := time.Now().Add(ReservationTTL)
+		if expiresAt.After(ticket.ExpiryTime) {
+			expiresAt = ticket.ExpiryTime
+		}
+		reservation.ExpiresAt = expiresAt
+
+		return tx.Reservations().Save(&reservation)
+	})
+
+	return reservation, err
+}
+
+// CancelHold gives the units back and closes the hold.
+func (s *TicketService) CancelHold(userId uint, reservationId uint) error {
+	return withTransaction(s.store, func(tx Tx) error {
+		reservation, err := getActiveReservation(tx, reservationId, userId)
+		if err != nil {
+			return err
+		}
+		return releaseReservation(tx, &reservation, ReservationCancelled)
+	})
+}
+
+// CheckoutHold turns a hold into a purchase. The units are already out of
+// the inventory, only the hold changes hands.
+func (s *TicketService) CheckoutHold(userId uint, reservationId uint) (Purchase, error) {
+	var purchase Purchase
+
+	err := withTransaction(s.store, func(tx Tx) error {
+		held, err := tx.Reservations().FindByID(reservationId)
+		if err != nil {
+			return err
+		}
+
+		// lock the event, the hold and then the ticket, the order a
+		// cancellation and the sweeper take them in
+		ticket, err := tx.Tickets().FindByID(held.TicketID)
+		if err != nil {
+			return err
+		}
+		event, err := tx.Events().FindByIDForUpdate(ticket.EventID)
+		if err != nil {
+			return err
+		}
+
+		reservation, err := getActiveReservation(tx, reservationId, userId)
+		if err != nil {
+			return err
+		}
+
+		ticket, err = tx.Tickets().FindByIDForUpdate(reservation.TicketID)
+		if err != nil {
+			return err
+		}
+
+		// the hold may have been taken before sales stopped
+		if err = checkOnSale(event); err != nil {
+			return err
+		}
+
+		// a purchase may have gone through since the hold was taken
+		if err = ensureNotAttending(tx, userId, ticket.ID); err != nil {
+			return err
+		}
+
+		reservation.Status = ReservationConverted
+		if err = tx.Reservations().Save(&reservation); err != nil {
+			return err
+		}
+
+		if ticket.Price > 0 {
+			order, err := s.createOrder(tx, event, ticket, userId, reservation.Units, false)
+			if err != nil {
+				return err
+			}
+			purchase = Purchase{Order: &order}
+			return nil
+		}
+
+		attendee, err := createAttendee(tx, userId, ticket, reservation.Units)
+		if err != nil {
+			return err
+		}
+		purchase = Purchase{Attendee: &attendee}
+		return nil
+	})
+	if err != nil || purchase.Order == nil {
+		return purchase, err
+	}
+
+	purchase.Intent, err = s.openIntent(*purchase.Order)
+	return purchase, err
+}
+
+// ConfirmOrder asks the provider for the order's payment status and settles it.
+func (s *TicketService) ConfirmOrder(userId uint, orderId uint) (payments.Order, error) {
+	order, err := s.store.Orders().FindByID(orderId)
+	if err != nil {
+		return payments.Order{}, err
+	}
+
+	if err = canOperate(userId, order.UserID); err != nil {
+		return payments.Order{}, err
+	}
+
+	payment, err := s.store.Payments().FindByOrder(order.ID)
+	if err != nil {
+		return payments.Order{}, err
+	}
+
+	intent, err := s.payments.ConfirmIntent(payment.Reference)
+	if err != nil {
+		return payments.Order{}, fmt.Errorf("%w: %v", ErrPaymentProvider, err)
+	}
+
+	return s.settleOrder(payment.Reference, intent.Status)
+}
+
+// SignatureHeader names the header the provider signs its webhooks in.
+func (s *TicketService) SignatureHeader() string {
+	return s.payments.SignatureHeader()
+}
+
+// HandleWebhook verifies a provider callback and settles the order it is about.
+// Event types that are not handled are ignored.
+func (s *TicketService) HandleWebhook(payload []byte, signature string) error {
+	event, err := s.payments.VerifyWebhook(payload, signature)
+	if errors.Is(err, payments.ErrInvalidSignature) {
+		return ErrInvalidSignature
+	}
+	if err != nil {
+		return apperror.ErrInvalidRequest
+	}
+
+	var status string
+	switch event.Type {
+	case payments.WebhookPaymentSucceeded:
+		status = payments.IntentSucceeded
+	case payments.WebhookPaymentFailed:
+		status = payments.IntentFailed
+	default:
+		return nil
+	}
+
+	_, err = s.settleOrder(event.IntentID, status)
+	return err
+}
+
+// createOrder holds the units for a paid ticket behind a pending order. The
+// event and ticket rows must already be locked by the caller. When the units
+// were already taken by a reservation, reserve is false. The payment intent
+// is opened with openIntent once the transaction has let go of the ticket.
+func (s *TicketService) createOrder(tx Tx, event Event, ticket Ticket, userId uint, units uint, reserve bool) (payments.Order, error) {
+	// the organiser decides whether the event charges at all
+	if !event.IsPaidEvent {
+		return payments.Order{}, ErrPriceOnFreeEvent
+	}
+
+	// one open checkout per user per ticket
+	pending, err := tx.Orders().CountPending(userId, ticket.ID)
+	if err != nil {
+		return payments.Order{}, err
+	}
+	if pending > 0 {
+		return payments.Order{}, ErrPendingOrder
+	}
+
+	if reserve {
+		if err := tx.Tickets().Reserve(ticket.ID, units); err != nil {
+			return payments.Order{}, err
+		}
+	}
+
+	order := payments.Order{
+		UserID: userId,
+		TicketID: ticket.ID,
+		Units: units,
+		Amount: ticket.Price * float64(units),
+		Currency: OrderCurrency,
+		Status: payments.OrderPending,
+	}
+	if err := tx.Orders().Create(&order); err != nil {
+		return payments.Order{}, err
+	}
+	return order, nil
+}
+
+// openIntent asks the provider for a payment intent for a pending order
+// and records it. It runs outside any transaction so a slow provider does
+// not keep the ticket locked. When the provider fails the order is failed
+// and its units go back to the inventory.
+func (s *TicketService) openIntent(order payments.Order) (payments.Intent, error) {
+	intent, err := s.payments.CreateIntent(
+		payments.ToMinorUnits(order.Amount), order.Currency, strconv.Itoa(int(order.ID)),
+	)
+	if err != nil {
+		providerErr := fmt.Errorf("%w: %v", ErrPaymentProvider, err)
+		err = withTransaction(s.store, func(tx Tx) error {
+			pending, err := tx.Orders().FindByIDForUpdate(order.ID)
+			if err != nil {
+				return err
+			}
+			// the sweeper or a cancellation may have let it go already
+			if pending.Status != payments.OrderPending {
+				return nil
+			}
+			if err := tx.Tickets().Release(pending.TicketID, pending.Units); err != nil {
+				return err
+			}
+			pending.Status = payments.OrderFailed
+			return tx.Orders().Save(&pending)
+		})
+		if err != nil {
+			log.Printf("failing order %d after the provider failed: %v", order.ID, err)
+		}
+		return payments.Intent{}, providerErr
+	}
+
+	// recorded whatever the order's status by now, so a payment that
+	// still arrives for an expired or cancelled order can be refunded
+	payment := payments.Payment{
+		OrderID: order.ID,
+		Provider: s.payments.Name(),
+		Reference: intent.ID,
+		Amount: order.Amount,
+		Status: intent.Status,
+	}
+	if err := s.store.Payments().Create(&payment); err != nil {
+		return payments.Intent{}, err
+	}
+	return intent, nil
+}
+
+// settleOrder applies the provider's final intent status to a pending order.
+// A successful payment creates the attendee, a failed one gives the units back.
+// Orders that are no longer pending are returned untouched so repeated
+// confirmations and webhook retries are harmless, apart from a payment for
+// an order that was let go, which is refunded.
+func (s *TicketService) settleOrder(reference string, intentStatus string) (payments.Order, error) {
+	var order payments.Order
+	var payment payments.Payment
+	refund := false
+
+	err := withTransaction(s.store, func(tx Tx) error {
+		var err error
+		payment, err = tx.Payments().FindByReference(reference)
+		if err != nil {
+			return err
+		}
+
+		order, err = tx.Orders().FindByIDForUpdate(payment.OrderID)
+		if err != nil {
+			return err
+		}
+
+		// the event was cancelled or the order expired while this payment
+		// was in flight, a refund that failed before is asked for again
+		lapsed := order.Status == payments.OrderCancelled || order.Status == payments.OrderExpired || order.Status == payments.OrderRefunding
+		if lapsed && intentStatus == payments.IntentSucceeded {
+			refund = true
+			return markRefunding(tx, &order)
+		}
+
+		if order.Status != payments.OrderPending {
+			return nil
+		}
+
+		switch intentStatus {
+		case payments.IntentSucceeded:
+			ticket, err := tx.Tickets().FindByID(order.TicketID)
+			if err != nil {
+				return err
+			}
+			if _, err = createAttendee(tx, order.UserID, ticket, order.Units); err != nil {
+				return err
+			}
+			order.Status = payments.OrderPaid
+		case payments.IntentFailed:
+			if err := tx.Tickets().Release(order.TicketID, order.Units); err != nil {
+				return err
+			}
+			order.Status = payments.OrderFailed
+		default:
+			return nil
+		}
+
+		payment.Status = intentStatus
+		if err := tx.Payments().Save(&payment); err != nil {
+			return err
+		}
+		return tx.Orders().Save(&order)
+	})
+	if err != nil || !refund {
+		return order, err
+	}
+
+	if err = refundPayment(s.store, s.payments, order, payment); err != nil {
+		return order, err
+	}
+	order.Status = payments.OrderRefunded
+	return order, nil
+}
+
+// newTicket builds a ticket for the event, checking its price and sale deadline.
+func newTicket(event Event, schema TicketSchema) (Ticket, error) {
+	expTime, err := utils.ValidateDate(schema.ExpiryTime)
+	if err != nil {
+		return Ticket{}, invalidDate("ExpiryTime")
+	}
+
+	if err = checkTicketRules(event, schema.Price, expTime); err != nil {
+		return Ticket{}, err
+	}
+
+	return Ticket{
+		Name: schema.Name,
+		Price: schema.Price,
+		TotalAvailable: schema.TotalAvailable,
+		SingleLimit: schema.SingleLimit,
+		ExpiryTime: expTime,
+		EventID: event.ID,
+	}, nil
+}
+
+// checkTicketRules enforces what every ticket on an event must satisfy:
+// sales close before the event starts and free events cannot charge.
+func checkTicketRules(event Event, price float64, expiry time.Time) error {
+	if expiry.After(event.EventDate) {
+		return ErrTicketAfterEvent
+	}
+	if !event.IsPaidEvent && price > 0 {
+		return ErrPriceOnFreeEvent
+	}
+	return nil
+}
+
+// lockForSale locks the ticket's event and then the ticket. Every sale
+// takes the event first, as a cancellation does, so a sale either commits
+// before the event is cancelled or finds it cancelled.
+func lockForSale(tx Tx, ticketId uint) (Event, Ticket, error) {
+	ticket, err := tx.Tickets().FindByID(ticketId)
+	if err != nil {
+		return Event{}, Ticket{}, err
+	}
+
+	event, err := tx.Events().FindByIDForUpdate(ticket.EventID)
+	if err != nil {
+		return Event{}, Ticket{}, err
+	}
+
+	ticket, err = tx.Tickets().FindByIDForUpdate(ticketId)
+	if err != nil {
+		return Event{}, Ticket{}, err
+	}
+	return event, ticket, nil
+}
+
+// checkPurchasable verifies the units requested and that the ticket is still on sale.
+func checkPurchasable(event Event, ticket Ticket, units uint) error {
+	if units == 0 || units > ticket.SingleLimit {
+		return ErrInvalidUnits
+	}
+	if time.Now().After(ticket.ExpiryTime) {
+		return ErrTicketExpired
+	}
+	return checkOnSale(event)
+}
+
+// ensureNotAttending enforces one standing purchase per user per ticket, a
+// cancelled one does not stop the user buying or receiving it again.
+func ensureNotAttending(tx Tx, userId uint, ticketId uint) error {
+	attending, err := tx.Attendees().ExistsActive(userId, ticketId)
+	if err != nil {
+		return err
+	}
+	if attending {
+		return ErrAlreadyAttending
+	}
+	return nil
+}
+
+// eventAttendees returns the attendees across the given tickets of an event.
+func eventAttendees(repos Repositories, tickets []Ticket) ([]Attendee, error) {
+	ticketIds := make([]uint, 0, len(tickets))
+	for _, ticket := range tickets {
+		ticketIds = append(ticketIds, ticket.ID)
+	}
+	return repos.Attendees().ListByTickets(ticketIds)
+}
+
+// getActiveReservation locks a user's reservation that is still holding units.
+func getActiveReservation(tx Tx, reservationId uint, userId uint) (Reservation, error) {
+	reservation, err := tx.Reservations().FindByIDForUpdate(reservationId)
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	if err := canOperate(userId, reservation.UserID); err != nil {
+		return Reservation{}, err
+	}
+
+	if reservation.Status != ReservationHeld || time.Now().After(reservation.ExpiresAt) {
+		return Reservation{}, ErrHoldExpired
+	}
+
+	return reservation, nil
+}
+
+// releaseReservation returns a hold's units to the inventory and closes it with the given status.
+func releaseReservation(tx Tx, reservation *Reservation, status string) error {
+	if err := tx.Tickets().Release(reservation.TicketID, reservation.Units); err != nil {
+		return err
+	}
+	reservation.Status = status
+	return tx.Reservations().Save(reservation)
+}
+
+// addOwner records the event's creator as the owner of its team.
+func addOwner(tx Tx, event Event, email string) error {
+	owner := EventMember{
+		EventID: event.ID,
+		UserID: &event.UserID,
+		Email: email,
+		Role: MemberOwner,
+		Status: MemberActive,
+		InvitedByID: event.UserID,
+	}
+	return tx.Members().Create(&owner)
+}
+
+// invalidDate reports which date field could not be read.
+func invalidDate(field string) error {
+	return ErrInvalidDate.WithFields(apperror.FieldError{
+		Field: field,
+		Code: ErrInvalidDate.Code,
+		Message: ErrInvalidDate.Message,
+	})
+}
+
+func canOperate(userId, jobUserId uint) error {
+	if userId != jobUserId {
+		return ErrNoPermission
+	}
+	return nil
+}
+
+func getEventUpdateData(updateData UpdateEventSchema, event Event) (Event, error) {
+	if updateData.Name != "" {
+		event.Name = updateData.Name
+	}
+
+	if updateData.Location != "" {
+		event.Location = updateData.Location
+	}
+
+	if updateData.Organiser != "" {
+		event.Organiser = updateData.Organiser
+	}
+
+	if updateData.IsPaidEvent != nil {
+		event.IsPaidEvent = utils.BoolValue(updateData.IsPaidEvent, false)
+	}
+
+	if updateData.IsLimitedEvent != nil {
+		event.IsLimited = utils.BoolValue(updateData.IsLimitedEvent, false)
+	}
+
+	if updateData.Description != "" {
+		event.Description = updateData.Description
+	}
+
+	if updateData.MaxUnitReservation != nil {
+		event.MaxUnitReservation = utils.UintValue(updateData.MaxUnitReservation, 1)
+	}
+
+	if updateData.EventDate != "" {
+		eventDate, err := utils.ValidateDate(updateData.EventDate)
+		if err != nil {
+			return Event{}, invalidDate("EventDate")
+		}
+		event.EventDate = eventDate
+	}
+
+	if updateData.RegistrationExpirationDate != "" {
+		regDate, err := utils.ValidateDate(updateData.RegistrationExpirationDate)
+		if err != nil {
+			return Event{}, invalidDate("RegistrationExpirationDate")
+		}
+		event.RegistrationExpirationDate = regDate
+	}
+
+	// either date may have moved, so compare what the event ends up with
+	if !event.RegistrationExpirationDate.Before(event.EventDate) {
+		return Event{}, ErrRegistrationAfterEvent
+	}
+
+	return event, nil
+}
+
+func getTicketUpdateData(updateData UpdateTicketSchema, ticket Ticket, event Event) (Ticket, error) {
+	if updateData.Name != "" {
+		ticket.Name = updateData.Name
+	}
+
+	if updateData.Price != nil {
+		ticket.Price = utils.FloatValue(updateData.Price, 0)
+	}
+
+	if updateData.TotalAvailable != nil {
+		// units sold, held or being paid for cannot be taken back
+		if *updateData.TotalAvailable < ticket.Sold {
+			return Ticket{}, ErrBelowSold
+		}
+		total := *updateData.TotalAvailable
+		ticket.TotalAvailable = &total
+	}
+
+	if updateData.Unlimited {
+		ticket.TotalAvailable = nil
+	}
+
+	if updateData.SingleLimit != nil {
+		ticket.SingleLimit = utils.UintValue(updateData.SingleLimit, 0)
+	}
+
+	if updateData.ExpiryTime != "" {
+		expTime, err := utils.ValidateDate(updateData.ExpiryTime)
+		if err != nil {
+			return Ticket{}, invalidDate("ExpiryTime")
+		}
+		ticket.ExpiryTime = expTime
+	}
+
+	if err := checkTicketRules(event, ticket.Price, ticket.ExpiryTime); err != nil {
+		return Ticket{}, err
+	}
+
+	return ticket, nil
+}
@@ -0,0 +1,322 @@
+package events
+
+import (
+	"avana/internal/dbtest"
+	"avana/internal/mailer"
+	"avana/internal/payments"
+	"avana/internal/tokens"
+	"avana/internal/users"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// testBackend opens the stores a test runs against.
+type testBackend struct {
+	name string
+	open func(t *testing.T) (Store, users.Store)
+}
+
+// backends are the memory stores, which serialise every transaction, and
+// the gorm stores on postgres, which lock rows the way production does. The
+// postgres run is skipped unless AVANA_TEST_DATABASE_DSN is set.
+var backends = []testBackend{
+	{name: "memory", open: func(t *testing.T) (Store, users.Store) {
+		return NewMemoryStore(), users.NewMemoryStore()
+	}},
+	{name: "postgres", open: func(t *testing.T) (Store, users.Store) {
+		db := dbtest.Open(t)
+		return NewGormStore(db), users.NewGormStore(db)
+	}},
+}
+
+// newTestServices wires the event and ticket services to the backend's
+// stores and the fake payment provider.
+func newTestServices(t *testing.T, backend testBackend) (*EventService, *TicketService, users.Store) {
+	t.Helper()
+
+	seed := sha256.Sum256([]byte("avana-test"))
+	store, userStore := backend.open(t)
+	provider := payments.NewFakeProvider("test-secret")
+	signer := tokens.NewTicketSigner("test", ed25519.NewKeyFromSeed(seed[:]))
+
+	eventService := NewEventService(store, userStore.Users(), provider, mailer.NewMemoryMailer(), signer)
+	return eventService, NewTicketService(store, eventService, provider), userStore
+}
+
+// publishedTicket creates a published event with one ticket of the given
+// limit and price and returns that ticket.
+func publishedTicket(t *testing.T, eventService *EventService, userStore users.Store, total uint, price float64) Ticket {
+	t.Helper()
+
+	owner := users.User{FirstName: "Owner", LastName: "User", Email: "owner@avana.test", Role: users.RoleOrganiser}
+	if err := userStore.Users().Create(&owner); err != nil {
+		t.Fatalf("creating owner: %v", err)
+	}
+	actor := Actor{ID: owner.ID, Role: owner.Role}
+
+	event, err := eventService.Create(actor, CreateEventSchema{
+		Name: "Concurrency",
+		Location: "Lagos",
+		Organiser: "Avana",
+		IsPaidEvent: price > 0,
+		MaxUnitReservation: 1,
+		EventDate: "2030-01-02 15:04:05",
+		RegistrationExpirationDate: "2030-01-01 15:04:05",
+		Tickets: []TicketSchema{{
+			Name: "Limited",
+			Price: price,
+			TotalAvailable: &total,
+			SingleLimit: 1,
+			ExpiryTime: "2030-01-01 15:04:05",
+		}},
+	})
+	if err != nil {
+		t.Fatalf("creating event: %v", err)
+	}
+	if _, err = eventService.Publish(actor, event.ID); err != nil {
+		t.Fatalf("publishing event: %v", err)
+	}
+
+	tickets, err := eventService.store.Tickets().ListByEvent(event.ID)
+	if err != nil || len(tickets) != 1 {
+		t.Fatalf("listing tickets: %v, %d tickets", err, len(tickets))
+	}
+	return tickets[0]
+}
+
+func TestBuyParallelNeverOversells(t *testing.T) {
+	const seats = 50
+	const buyers = 300
+
+	for _, backend := range backends {
+		for _, tc := range []struct {
+			name string
+			price float64
+		}{
+			{name: "free", price: 0},
+			{name: "paid", price: 2500},
+		} {
+			t.Run(backend.name+"/"+tc.name, func(t *testing.T) {
+				eventService, ticketService, userStore := newTestServices(t, backend)
+				ticket := publishedTicket(t, eventService, userStore, seats, tc.price)
+
+				var mu sync.Mutex
+				var wg sync.WaitGroup
+				sales := 0
+				failures := map[error]int{}
+				for i := 0; i < buyers; i++ {
+					wg.Add(1)
+					// every buyer is a different user so only the limit can refuse them
+					go func(userId uint) {
+						defer wg.Done()
+						_, err := ticketService.Buy(userId, ticket.ID, 1)
+
+						mu.Lock()
+						defer mu.Unlock()
+						if err == nil {
+							sales++
+							return
+						}
+						failures[err]++
+					}(uint(1000 + i))
+				}
+				wg.Wait()
+
+				if sales != seats {
+					t.Errorf("sales = %d, want %d", sales, seats)
+				}
+				for err, count := range failures {
+					if !errors.Is(err, ErrSoldOut) {
+						t.Errorf("%d purchases failed with %v, want %v", count, err, ErrSoldOut)
+					}
+				}
+
+				stored, err := eventService.store.Tickets().FindByID(ticket.ID)
+				if err != nil {
+					t.Fatalf("reloading ticket: %v", err)
+				}
+				if stored.Sold != seats {
+					t.Errorf("sold = %d, want %d", stored.Sold, seats)
+				}
+			})
+		}
+	}
+}
+
+func TestCancelRefundsPaidAndInFlightOrders(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			eventService, ticketService, userStore := newTestServices(t, backend)
+			ticket := publishedTicket(t, eventService, userStore, 10, 2500)
+
+			owner, err := userStore.Users().FindByEmail("owner@avana.test")
+			if err != nil {
+				t.Fatalf("finding owner: %v", err)
+			}
+
+			// one buyer has paid, the other is still checking out
+			paid, err := ticketService.Buy(2001, ticket.ID, 1)
+			if err != nil {
+				t.Fatalf("buying: %v", err)
+			}
+			if _, err = ticketService.ConfirmOrder(2001, paid.Order.ID); err != nil {
+				t.Fatalf("confirming: %v", err)
+			}
+			inFlight, err := ticketService.Buy(2002, ticket.ID, 1)
+			if err != nil {
+				t.Fatalf("buying: %v", err)
+			}
+
+			report, err := eventService.Cancel(Actor{ID: owner.ID, Role: owner.Role}, ticket.EventID, CancelEventSchema{Reason: "Storm"})
+			if err != nil {
+				t.Fatalf("cancelling: %v", err)
+			}
+			if report.RefundedAmount != 2500 || report.OutstandingRefunds != 0 || report.OrdersVoided != 1 {
+				t.Errorf("report = %+v, want 2500 refunded, none outstanding, 1 voided", report)
+			}
+
+			// the payment that lands after the cancellation is refunded too
+			order, err := ticketService.ConfirmOrder(2002, inFlight.Order.ID)
+			if err != nil {
+				t.Fatalf("confirming after cancel: %v", err)
+			}
+			if order.Status != payments.OrderRefunded {
+				t.Errorf("late order status = %q, want %q", order.Status, payments.OrderRefunded)
+			}
+
+			// a second settlement finds nothing left to refund
+			again, err := eventService.RetryCancellation(Actor{ID: owner.ID, Role: owner.Role}, ticket.EventID)
+			if err != nil {
+				t.Fatalf("retrying: %v", err)
+			}
+			if again.RefundedAmount != 5000 || again.OutstandingRefunds != 0 {
+				t.Errorf("retried report = %+v, want 5000 refunded, none outstanding", again)
+			}
+		})
+	}
+}
+
+func TestBuyAfterCancelIsRefused(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			eventService, ticketService, userStore := newTestServices(t, backend)
+			ticket := publishedTicket(t, eventService, userStore, 10, 0)
+
+			owner, err := userStore.Users().FindByEmail("owner@avana.test")
+			if err != nil {
+				t.Fatalf("finding owner: %v", err)
+			}
+			actor := Actor{ID: owner.ID, Role: owner.Role}
+
+			// buyers racing the cancellation either end up cancelled or are refused
+			var wg sync.WaitGroup
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func(userId uint) {
+					defer wg.Done()
+					_, err := ticketService.Buy(userId, ticket.ID, 1)
+					if err != nil && !errors.Is(err, ErrSalesClosed) {
+						t.Errorf("buying during cancel: %v", err)
+					}
+				}(uint(3000 + i))
+			}
+			if _, err = eventService.Cancel(actor, ticket.EventID, CancelEventSchema{Reason: "Storm"}); err != nil {
+				t.Fatalf("cancelling: %v", err)
+			}
+			wg.Wait()
+
+			attendees, err := eventService.store.Attendees().ListByTickets([]uint{ticket.ID})
+			if err != nil {
+				t.Fatalf("listing attendees: %v", err)
+			}
+			for _, attendee := range attendees {
+				if attendee.Status != AttendeeCancelled {
+					t.Errorf("attendee %d is %q after the cancellation", attendee.ID, attendee.Status)
+				}
+			}
+		})
+	}
+}
+
+func TestPostponeKeepsTicketsBeforeTheEvent(t *testing.T) {
+	eventService, _, userStore := newTestServices(t, backends[0])
+	ticket := publishedTicket(t, eventService, userStore, 10, 0)
+
+	owner, err := userStore.Users().FindByEmail("owner@avana.test")
+	if err != nil {
+		t.Fatalf("finding owner: %v", err)
+	}
+	actor := Actor{ID: owner.ID, Role: owner.Role}
+
+	// the ticket sells until 2030-01-01, the event cannot move before that
+	_, err = eventService.Postpone(actor, ticket.EventID, PostponeEventSchema{
+		Reason: "Venue",
+		EventDate: "2029-12-31 10:00:00",
+		RegistrationExpirationDate: "2029-12-30 10:00:00",
+	})
+	if !errors.Is(err, ErrTicketAfterEvent) {
+		t.Fatalf("postponing before a ticket's sales close = %v, want %v", err, ErrTicketAfterEvent)
+	}
+
+	event, err := eventService.Postpone(actor, ticket.EventID, PostponeEventSchema{
+		Reason: "Venue",
+		EventDate: "2030-06-01 10:00:00",
+		RegistrationExpirationDate: "2030-05-30 10:00:00",
+	})
+	if err != nil {
+		t.Fatalf("postponing: %v", err)
+	}
+	if event.Status != EventPostponed {
+		t.Errorf("status = %q, want %q", event.Status, EventPostponed)
+	}
+}
+
+func TestExtendHoldStopsWithSales(t *testing.T) {
+	eventService, ticketService, userStore := newTestServices(t, backends[0])
+	ticket := publishedTicket(t, eventService, userStore, 10, 0)
+
+	owner, err := userStore.Users().FindByEmail("owner@avana.test")
+	if err != nil {
+		t.Fatalf("finding owner: %v", err)
+	}
+
+	hold, err := ticketService.Hold(4001, ticket.ID, 1)
+	if err != nil {
+		t.Fatalf("holding: %v", err)
+	}
+	if _, err = ticketService.ExtendHold(4001, hold.ID); err != nil {
+		t.Fatalf("extending while on sale: %v", err)
+	}
+
+	if _, err = eventService.Postpone(Actor{ID: owner.ID, Role: owner.Role}, ticket.EventID, PostponeEventSchema{Reason: "Venue"}); err != nil {
+		t.Fatalf("postponing: %v", err)
+	}
+	if _, err = ticketService.ExtendHold(4001, hold.ID); !errors.Is(err, ErrSalesClosed) {
+		t.Errorf("extending after sales stopped = %v, want %v", err, ErrSalesClosed)
+	}
+}
+
+func TestCancelledPurchaseDoesNotBlockBuyingAgain(t *testing.T) {
+	eventService, ticketService, userStore := newTestServices(t, backends[0])
+	ticket := publishedTicket(t, eventService, userStore, 10, 0)
+
+	purchase, err := ticketService.Buy(5001, ticket.ID, 1)
+	if err != nil {
+		t.Fatalf("buying: %v", err)
+	}
+	if _, err = ticketService.Buy(5001, ticket.ID, 1); !errors.Is(err, ErrAlreadyAttending) {
+		t.Fatalf("buying twice = %v, want %v", err, ErrAlreadyAttending)
+	}
+
+	attendee := *purchase.Attendee
+	attendee.Status = AttendeeCancelled
+	if err = eventService.store.Attendees().Save(&attendee); err != nil {
+		t.Fatalf("cancelling the purchase: %v", err)
+	}
+	if _, err = ticketService.Buy(5001, ticket.ID, 1); err != nil {
+		t.Errorf("buying after the purchase was cancelled = %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package events
+
+import (
+	"avana/internal/payments"
+	"log"
+	"time"
+)
+
+// ReservationTTL is how long a checkout hold keeps its units before the
+// sweeper returns them to the inventory.
+var ReservationTTL = 10 * time.Minute
+
+// OrderTTL is how long a checkout may wait for its payment before the
+// sweeper expires the order and returns its units to the inventory.
+var OrderTTL = 30 * time.Minute
+
+// StartReservationSweeper releases expired holds and stale pending orders
+// every interval until stop is closed. The returned channel is closed once
+// the sweep in progress, if any, has finished.
+func StartReservationSweeper(store Store, interval time.Duration, stop <-chan struct{}) <-chan struct{} {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				released, err := SweepExpiredReservations(store)
+				if err != nil {
+					log.Printf("reservation sweeper: %v", err)
+					continue
+				}
+				if released > 0 {
+					log.Printf("reservation sweeper: released %d expired holds", released)
+				}
+
+				expired, err := SweepStaleOrders(store)
+				if err != nil {
+					log.Printf("reservation sweeper: %v", err)
+					continue
+				}
+				if expired > 0 {
+					log.Printf("reservation sweeper: expired %d unpaid orders", expired)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return done
+}
+
+// SweepExpiredReservations returns the units of every expired hold to its
+// ticket. Rows locked by a checkout in progress are skipped and picked up
+// on the next run.
+func SweepExpiredReservations(store Store) (int, error) {
+	released := 0
+
+	err := withTransaction(store, func(tx Tx) error {
+		reservations, err := tx.Reservations().ListExpiredForUpdate(time.Now())
+		if err != nil {
+			return err
+		}
+
+		for i := range reservations {
+			if err := releaseReservation(tx, &reservations[i], ReservationExpired); err != nil {
+				return err
+			}
+			released++
+		}
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+	return released, nil
+}
+
+// SweepStaleOrders expires every order still pending after OrderTTL and
+// returns its units to the ticket. A payment that arrives for one later is
+// refunded when it settles.
+func SweepStaleOrders(store Store) (int, error) {
+	expired := 0
+
+	err := withTransaction(store, func(tx Tx) error {
+		orders, err := tx.Orders().ListStaleForUpdate(time.Now().Add(-OrderTTL))
+		if err != nil {
+			return err
+		}
+
+		for i := range orders {
+			if err := tx.Tickets().Release(orders[i].TicketID, orders[i].Units); err != nil {
+				return err
+			}
+			orders[i].Status = payments.OrderExpired
+			if err := tx.Orders().Save(&orders[i]); err != nil {
+				return err
+			}
+			expired++
+		}
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+	return expired, nil
+}
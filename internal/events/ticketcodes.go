@@ -0,0 +1,356 @@
+package events
+
+import (
+	"avana/internal/repository"
+	"avana/internal/users"
+	"avana/internal/utils"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// TicketSerialLength is how many characters identify one unit's code.
+const TicketSerialLength = 12
+
+const (
+	QRFormatPNG string = "png"
+	QRFormatSVG string = "svg"
+)
+
+// IssuedCode is one unit's code as its holder sees it. Token is what the
+// QR code carries, empty once the code is revoked.
+type IssuedCode struct {
+	Serial string
+	Unit uint
+	Status string
+	Token string
+	CheckedInAt *time.Time
+}
+
+// PurchasedTicket is a purchase with the codes of its units.
+type PurchasedTicket struct {
+	AttendeeID uint
+	EventID uint
+	EventName string
+	EventDate time.Time
+	TicketType string
+	Units uint
+	CheckedIn uint
+	Status string
+	// Code checks the whole purchase in at once
+	Code string
+	Codes []IssuedCode
+}
+
+// createAttendee stores a purchase of units of the ticket with its door
+// code and a signed code for every unit.
+func createAttendee(tx Tx, userId uint, ticket Ticket, units uint) (Attendee, error) {
+	code, err := utils.GenerateCode(AttendeeCodeLength)
+	if err != nil {
+		return Attendee{}, err
+	}
+
+	attendee := Attendee{
+		UserID: userId,
+		Units: units,
+		TicketID: ticket.ID,
+		Status: AttendeeActive,
+		Code: code,
+	}
+	if err = tx.Attendees().Create(&attendee); err != nil {
+		return Attendee{}, err
+	}
+
+	if err = issueTicketCodes(tx, attendee, ticket.EventID); err != nil {
+		return Attendee{}, err
+	}
+	return attendee, nil
+}
+
+func issueTicketCodes(tx Tx, attendee Attendee, eventId uint) error {
+	for unit := uint(1); unit <= attendee.Units; unit++ {
+		serial, err := utils.GenerateCode(TicketSerialLength)
+		if err != nil {
+			return err
+		}
+
+		code := TicketCode{
+			AttendeeID: attendee.ID,
+			EventID: eventId,
+			Unit: unit,
+			Serial: serial,
+			Status: TicketCodeValid,
+		}
+		if err = tx.TicketCodes().Create(&code); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revokeTicketCodes withdraws every valid code of the purchase, scanners
+// refuse them from then on.
+func revokeTicketCodes(tx Tx, attendeeId uint, reason string) error {
+	codes, err := tx.TicketCodes().ListByAttendees([]uint{attendeeId})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i := range codes {
+		if codes[i].Status != TicketCodeValid {
+			continue
+		}
+		codes[i].Status = TicketCodeRevoked
+		codes[i].RevokedReason = reason
+		codes[i].RevokedAt = &now
+		if err = tx.TicketCodes().Save(&codes[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ticketToken is what a unit's QR code carries: its serial and event,
+// signed so a scanner can tell a forged code without asking the server.
+func (s *EventService) ticketToken(code TicketCode) string {
+	message := code.Serial + "." + strconv.FormatUint(uint64(code.EventID), 10)
+	return message + "." + s.signer.Sign(message)
+}
+
+// isTicketToken tells a signed unit code from a purchase's door code.
+func isTicketToken(code string) bool {
+	return strings.Count(code, ".") == 2
+}
+
+// parseTicketToken checks the token's signature and returns the serial and
+// event it was issued for.
+func (s *EventService) parseTicketToken(token string) (string, uint, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", 0, ErrUnknownTicketCode
+	}
+
+	message := parts[0] + "." + parts[1]
+	if err := s.signer.Verify(message, parts[2]); err != nil {
+		return "", 0, ErrUnknownTicketCode
+	}
+
+	eventId, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return "", 0, ErrUnknownTicketCode
+	}
+	return parts[0], uint(eventId), nil
+}
+
+// MyTickets lists the actor's purchases newest first, with the codes of
+// every unit.
+func (s *EventService) MyTickets(actor Actor) ([]PurchasedTicket, error) {
+	attendees, err := s.store.Attendees().ListByOwner(actor.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	attendeeIds := make([]uint, 0, len(attendees))
+	for _, attendee := range attendees {
+		attendeeIds = append(attendeeIds, attendee.ID)
+	}
+	codes, err := s.store.TicketCodes().ListByAttendees(attendeeIds)
+	if err != nil {
+		return nil, err
+	}
+	codesByAttendee := map[uint][]IssuedCode{}
+	for _, code := range codes {
+		// codes revoked by a transfer belonged to the previous holder
+		if code.RevokedReason == RevokedTransferred {
+			continue
+		}
+		codesByAttendee[code.AttendeeID] = append(codesByAttendee[code.AttendeeID], s.issuedCode(code))
+	}
+
+	purchases := make([]PurchasedTicket, 0, len(attendees))
+	for _, attendee := range attendees {
+		ticket, err := s.store.Tickets().FindByID(attendee.TicketID)
+		if err != nil {
+			return nil, err
+		}
+		event, err := s.store.Events().FindByID(ticket.EventID)
+		if err != nil {
+			return nil, err
+		}
+
+		purchase := PurchasedTicket{
+			AttendeeID: attendee.ID,
+			EventID: event.ID,
+			EventName: event.Name,
+			EventDate: event.EventDate,
+			TicketType: ticket.Name,
+			Units: attendee.Units,
+			CheckedIn: attendee.CheckedIn,
+			Status: attendee.Status,
+			Codes: codesByAttendee[attendee.ID],
+		}
+		if attendee.Status == AttendeeActive {
+			purchase.Code = attendee.Code
+		}
+		if purchase.Codes == nil {
+			purchase.Codes = []IssuedCode{}
+		}
+		purchases = append(purchases, purchase)
+	}
+	return purchases, nil
+}
+
+func (s *EventService) issuedCode(code TicketCode) IssuedCode {
+	issued := IssuedCode{
+		Serial: code.Serial,
+		Unit: code.Unit,
+		Status: code.Status,
+		CheckedInAt: code.CheckedInAt,
+	}
+	if code.Status == TicketCodeValid {
+		issued.Token = s.ticketToken(code)
+	}
+	return issued
+}
+
+// TicketQR renders the unit's signed code as a QR image for its holder or
+// the event's team. It returns the image and its content type.
+func (s *EventService) TicketQR(actor Actor, serial string, schema TicketQRSchema) ([]byte, string, error) {
+	code, err := s.store.TicketCodes().FindBySerial(serial)
+	if err != nil {
+		return nil, "", err
+	}
+
+	attendee, err := s.store.Attendees().FindByID(code.AttendeeID)
+	if err != nil {
+		return nil, "", err
+	}
+	if attendee.UserID != actor.ID && !actor.can(users.PermViewAnyAttendees) {
+		event, err := s.store.Events().FindByID(code.EventID)
+		if err != nil {
+			return nil, "", err
+		}
+		if err = s.Authorize(actor, event, EventPermViewAttendees); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if code.Status != TicketCodeValid {
+		return nil, "", ErrTicketRevoked
+	}
+
+	size := schema.Size
+	if size == 0 {
+		size = 256
+	}
+	return renderQR(s.ticketToken(code), schema.Format, size)
+}
+
+func renderQR(content string, format string, size int) ([]byte, string, error) {
+	qr, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if format == QRFormatSVG {
+		return qrSVG(qr.Bitmap(), size), "image/svg+xml", nil
+	}
+
+	image, err := qr.PNG(size)
+	if err != nil {
+		return nil, "", err
+	}
+	return image, "image/png", nil
+}
+
+// qrSVG draws every dark module of the bitmap as a unit square, scaled to
+// size by the viewBox.
+func qrSVG(bitmap [][]bool, size int) []byte {
+	var svg bytes.Buffer
+	modules := len(bitmap)
+
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size, size, modules, modules)
+	fmt.Fprintf(&svg, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, modules, modules)
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&svg, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	svg.WriteString(`"/></svg>`)
+	return svg.Bytes()
+}
+
+// Transfer hands an unused purchase to another user. The codes issued so
+// far are revoked and the new holder gets fresh ones.
+func (s *TicketService) Transfer(actor Actor, attendeeId uint, schema TransferTicketSchema) (Attendee, error) {
+	recipient, err := s.events.users.FindByEmail(schema.Email)
+	if err != nil {
+		return Attendee{}, err
+	}
+	if recipient.ID == actor.ID {
+		return Attendee{}, ErrTransferToSelf
+	}
+
+	var attendee Attendee
+	err = withTransaction(s.store, func(tx Tx) error {
+		var err error
+		attendee, err = tx.Attendees().FindByIDForUpdate(attendeeId)
+		if err != nil {
+			return err
+		}
+
+		// other people's purchases are not there to be found
+		if attendee.UserID != actor.ID {
+			return repository.ErrNotFound
+		}
+		if attendee.Status != AttendeeActive {
+			return ErrTicketCancelled
+		}
+		if attendee.CheckedIn > 0 {
+			return ErrTicketUsed
+		}
+
+		ticket, err := tx.Tickets().FindByID(attendee.TicketID)
+		if err != nil {
+			return err
+		}
+		event, err := tx.Events().FindByID(ticket.EventID)
+		if err != nil {
+			return err
+		}
+		if isClosed(event) {
+			return ErrEventClosed
+		}
+
+		if err = ensureNotAttending(tx, recipient.ID, ticket.ID); err != nil {
+			return err
+		}
+
+		if err = revokeTicketCodes(tx, attendee.ID, RevokedTransferred); err != nil {
+			return err
+		}
+
+		// the old door code goes with the old holder
+		code, err := utils.GenerateCode(AttendeeCodeLength)
+		if err != nil {
+			return err
+		}
+		attendee.UserID = recipient.ID
+		attendee.Code = code
+		if err = tx.Attendees().Save(&attendee); err != nil {
+			return err
+		}
+
+		return issueTicketCodes(tx, attendee, event.ID)
+	})
+
+	return attendee, err
+}
@@ -0,0 +1,25 @@
+package mailer
+
+import (
+	"errors"
+)
+
+var ErrNoRecipient = errors.New("message has no recipient")
+
+type Mailer interface {
+	Send(message Message) error
+}
+
+type Message struct {
+	To string
+	Subject string
+	Text string
+	HTML string
+}
+
+func (m Message) validate() error {
+	if m.To == "" {
+		return ErrNoRecipient
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// MemoryMailer keeps every message it is given, for tests.
+type MemoryMailer struct {
+	mu sync.Mutex
+	messages []Message
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *MemoryMailer) Send(message Message) error {
+	if err := message.validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
+
+// Last returns the most recent message sent to the address.
+func (m *MemoryMailer) Last(to string) (Message, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To == to {
+			return m.messages[i], true
+		}
+	}
+	return Message{}, false
+}
+
+// FileMailer writes each message as an .eml file, for local development.
+type FileMailer struct {
+	Dir string
+	From string
+}
+
+func NewFileMailer(dir, from string) *FileMailer {
+	return &FileMailer{
+		Dir: dir,
+		From: from,
+	}
+}
+
+func (m *FileMailer) Send(message Message) error {
+	if err := message.validate(); err != nil {
+		return err
+	}
+
+	body, err := buildMIME(m.From, message)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), message.To)
+	return os.WriteFile(filepath.Join(m.Dir, name), body, 0o600)
+}
@@ -0,0 +1,91 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+)
+
+type SMTPMailer struct {
+	Host string
+	Port int
+	Username string
+	Password string
+	From string
+}
+
+func NewSMTPMailer(host string, port int, username, password, from string) *SMTPMailer {
+	return &SMTPMailer{
+		Host: host,
+		Port: port,
+		Username: username,
+		Password: password,
+		From: from,
+	}
+}
+
+func (m *SMTPMailer) Send(message Message) error {
+	if err := message.validate(); err != nil {
+		return err
+	}
+
+	body, err := buildMIME(m.From, message)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	addr := fmt.Sprintf("%s:%d", m.Host, m.Port)
+	return smtp.SendMail(addr, auth, m.From, []string{message.To}, body)
+}
+
+// buildMIME renders the message as multipart/alternative so clients that
+// cannot show HTML fall back to the text part.
+func buildMIME(from string, message Message) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	parts := []struct {
+		contentType string
+		content string
+	}{
+		{"text/plain; charset=UTF-8", message.Text},
+		{"text/html; charset=UTF-8", message.HTML},
+	}
+	for _, part := range parts {
+		if part.content == "" {
+			continue
+		}
+		w, err := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return nil, err
+		}
+		if _, err = w.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	headers := []string{
+		"From: " + from,
+		"To: " + message.To,
+		"Subject: " + message.Subject,
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + writer.Boundary(),
+	}
+	out.WriteString(strings.Join(headers, "\r\n"))
+	out.WriteString("\r\n\r\n")
+	out.Write(body.Bytes())
+
+	return out.Bytes(), nil
+}
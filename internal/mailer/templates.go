@@ -0,0 +1,55 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+)
+
+//go:embed templates/*
+var templateFS embed.FS
+
+var (
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/*.html"))
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/*.txt"))
+)
+
+type OtpData struct {
+	FirstName string
+	Otp string
+	ExpiresInMinutes int
+}
+
+type InviteData struct {
+	EventName string
+	EventID uint
+	Role string
+}
+
+type EventStatusData struct {
+	EventName string
+	EventID uint
+	Status string
+	Reason string
+	EventDate string
+}
+
+// Render builds a message from the <name>.html and <name>.txt templates.
+func Render(name, to, subject string, data any) (Message, error) {
+	var html, text bytes.Buffer
+
+	if err := htmlTemplates.ExecuteTemplate(&html, name+".html", data); err != nil {
+		return Message{}, err
+	}
+	if err := textTemplates.ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		To: to,
+		Subject: subject,
+		Text: text.String(),
+		HTML: html.String(),
+	}, nil
+}
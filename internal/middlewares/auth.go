@@ -1,11 +1,10 @@
 package middlewares
 
 import (
-	"avana/internal/config"
+	"avana/internal/apperror"
+	"avana/internal/tokens"
 	"avana/internal/users"
-	"avana/internal/utils"
-	"fmt"
-	"net/http"
+	"errors"
 	"strings"
 	"time"
 
@@ -13,56 +12,87 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 )
 
+var errNoToken = errors.New("no bearer token")
 
-func RequireAuth(c *gin.Context) {
+
+// RequireAuth returns a middleware that accepts requests carrying a valid
+// bearer token signed by one of the key set's keys, for a user and session
+// that still exist in the store.
+func RequireAuth(keys *tokens.KeySet, store users.Store) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if err := authenticate(c, keys, store); err != nil {
+            apperror.Abort(c, apperror.ErrUnauthenticated)
+            return
+        }
+        c.Next()
+    }
+}
+
+// OptionalAuth identifies the user like RequireAuth when the request has a
+// bearer token and lets anonymous requests through. A token that is sent
+// but not valid is still rejected, rather than silently served as anonymous.
+func OptionalAuth(keys *tokens.KeySet, store users.Store) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        err := authenticate(c, keys, store)
+        if err != nil && !errors.Is(err, errNoToken) {
+            apperror.Abort(c, apperror.ErrUnauthenticated)
+            return
+        }
+        c.Next()
+    }
+}
+
+// authenticate checks the bearer token and stores the user on the context.
+func authenticate(c *gin.Context, keys *tokens.KeySet, store users.Store) error {
     authHeader := c.GetHeader("Authorization")
-    if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-        c.JSON(http.StatusUnauthorized, gin.H{"message": utils.ValidateTokenError})
-        c.Abort()
-        return
+    if authHeader == "" {
+        return errNoToken
+    }
+    if !strings.HasPrefix(authHeader, "Bearer ") {
+        return errors.New("not a bearer token")
     }
 
     tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-    secret := "chgjfskiuyfgshdigjhv"
 
-    token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-        if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-            return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-        }
-        return []byte(secret), nil
-    })
+    token, err := keys.Parse(tokenString)
+    if err != nil {
+        return err
+    }
+
+    claims, ok := token.Claims.(jwt.MapClaims)
+    if !ok || !token.Valid {
+        return errors.New("invalid token")
+    }
 
+    exp, _ := claims["exp"].(float64)
+    if float64(time.Now().Unix()) > exp {
+        return errors.New("token expired")
+    }
+
+    email, _ := claims["sub"].(string)
+    user, err := store.Users().FindByEmail(email)
     if err != nil {
-        c.JSON(http.StatusUnauthorized, gin.H{"message": utils.ValidateTokenError})
-        c.Abort()
-        return
+        return err
     }
 
-    if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-        if float64(time.Now().Unix()) > claims["exp"].(float64) {
-            c.JSON(http.StatusUnauthorized, gin.H{"message": utils.ValidateTokenError})
-            c.Abort()
-            return
-        }
+    if user.ID == 0 {
+        return errors.New("unknown user")
+    }
 
-        var user users.User
-        if err := config.DB.First(&user, "email = ?", claims["sub"]).Error;
-				err != nil {
-					c.JSON(http.StatusUnauthorized, gin.H{"message": utils.ValidateTokenError})
-					c.Abort()
-					return
-				}
-
-        if user.ID == 0 {
-            c.JSON(http.StatusUnauthorized, gin.H{"message": utils.ValidateTokenError})
-            c.Abort()
-            return
-        }
+    // the token must belong to a session that is still signed in
+    sessionId, ok := claims["sid"].(float64)
+    if !ok {
+        return errors.New("token has no session")
+    }
 
-        c.Set("userID", user.ID)
-        c.Next()
-    } else {
-        c.JSON(http.StatusUnauthorized, gin.H{"error": utils.ValidateTokenError})
-        c.Abort()
+    session, err := store.Sessions().FindByID(uint(sessionId))
+    if err != nil ||
+        session.UserID != user.ID || !session.IsActive() {
+        return errors.New("session is not active")
     }
-}
\ No newline at end of file
+
+    c.Set("userID", user.ID)
+    c.Set("sessionID", session.ID)
+    c.Set("userRole", user.Role)
+    return nil
+}
@@ -0,0 +1,24 @@
+package middlewares
+
+import (
+	"avana/internal/apperror"
+	"avana/internal/users"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission lets the request through only when the authenticated
+// user's role grants the permission. It must run after RequireAuth.
+func RequirePermission(permission string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        role, _ := c.Get("userRole")
+        roleString, _ := role.(string)
+
+        if !users.HasPermission(roleString, permission) {
+            apperror.Abort(c, apperror.ErrForbidden)
+            return
+        }
+
+        c.Next()
+    }
+}
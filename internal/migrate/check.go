@@ -0,0 +1,81 @@
+package migrate
+
+import (
+	"avana/internal/events"
+	"avana/internal/payments"
+	"avana/internal/users"
+	"fmt"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// Models lists every model the migrations are expected to create tables for.
+func Models() []interface{} {
+	return []interface{}{
+		&users.User{},
+		&users.Session{},
+		&users.RefreshToken{},
+		&users.RoleRequest{},
+		&events.Event{},
+		&events.EventMember{},
+		&events.Ticket{},
+		&events.Reservation{},
+		&events.Attendee{},
+		&events.Review{},
+		&events.ReviewFlag{},
+		&events.CheckIn{},
+		&events.TicketCode{},
+		&payments.Order{},
+		&payments.Payment{},
+	}
+}
+
+// Check compares the models with the migrated schema and describes every
+// table or column that exists on one side only. No problems means they agree.
+func Check(db *gorm.DB, models ...interface{}) ([]string, error) {
+	var problems []string
+	migrator := db.Migrator()
+
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, err
+		}
+		table := stmt.Schema.Table
+
+		if !migrator.HasTable(table) {
+			problems = append(problems, fmt.Sprintf("table %s is missing", table))
+			continue
+		}
+
+		columns, err := migrator.ColumnTypes(model)
+		if err != nil {
+			return nil, err
+		}
+		inDatabase := map[string]bool{}
+		for _, column := range columns {
+			inDatabase[column.Name()] = true
+		}
+
+		inModel := map[string]bool{}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" {
+				continue
+			}
+			inModel[field.DBName] = true
+			if !inDatabase[field.DBName] {
+				problems = append(problems, fmt.Sprintf("column %s.%s is missing", table, field.DBName))
+			}
+		}
+
+		for name := range inDatabase {
+			if !inModel[name] {
+				problems = append(problems, fmt.Sprintf("column %s.%s is not in the model", table, name))
+			}
+		}
+	}
+
+	sort.Strings(problems)
+	return problems, nil
+}
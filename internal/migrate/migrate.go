@@ -0,0 +1,279 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNoMigrations = errors.New("no migrations have been applied")
+	ErrUnknownVersion = errors.New("applied migration has no file")
+)
+
+// files are named <version>_<name>.up.sql and <version>_<name>.down.sql
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int64
+	Name string
+	Up string
+	Down string
+}
+
+type Status struct {
+	Version int64
+	Name string
+	AppliedAt *time.Time
+}
+
+type SchemaMigration struct {
+	Version int64			`gorm:"primaryKey;autoIncrement:false"`
+	Name string				`gorm:"not null"`
+	AppliedAt time.Time		`gorm:"not null"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+type Migrator struct {
+	db *gorm.DB
+	migrations []Migration
+}
+
+func New(db *gorm.DB, migrations []Migration) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+// Load reads every migration in dir, ordered by version. Each version needs
+// both an up and a down file.
+func Load(dir string) ([]Migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(body)
+		} else {
+			migration.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if strings.TrimSpace(migration.Up) == "" || strings.TrimSpace(migration.Down) == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Create writes an empty up/down pair numbered after the highest existing version.
+func Create(dir, name string) (string, string, error) {
+	name = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), " ", "_"))
+	if !regexp.MustCompile(`^[a-z0-9_]+$`).MatchString(name) {
+		return "", "", fmt.Errorf("migration name %q may only contain letters, digits and underscores", name)
+	}
+
+	migrations, err := Load(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", "", err
+	}
+
+	var next int64 = 1
+	if len(migrations) > 0 {
+		next = migrations[len(migrations)-1].Version + 1
+	}
+
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return "", "", err
+	}
+
+	base := fmt.Sprintf("%04d_%s", next, name)
+	up := filepath.Join(dir, base+".up.sql")
+	down := filepath.Join(dir, base+".down.sql")
+
+	if err = os.WriteFile(up, []byte("-- "+base+" up\n"), 0o644); err != nil {
+		return "", "", err
+	}
+	if err = os.WriteFile(down, []byte("-- "+base+" down\n"), 0o644); err != nil {
+		return "", "", err
+	}
+
+	return up, down, nil
+}
+
+// Up applies every pending migration in order, each in its own transaction.
+func (m *Migrator) Up() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var ran []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if err := m.apply(migration); err != nil {
+			return ran, err
+		}
+		ran = append(ran, migration)
+	}
+	return ran, nil
+}
+
+// Down rolls back the last n applied migrations, newest first.
+func (m *Migrator) Down(n int) ([]Migration, error) {
+	var rows []SchemaMigration
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+	if err := m.db.Order("version DESC").Limit(n).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrNoMigrations
+	}
+
+	var ran []Migration
+	for _, row := range rows {
+		migration, ok := m.find(row.Version)
+		if !ok {
+			return ran, fmt.Errorf("%w: %d_%s", ErrUnknownVersion, row.Version, row.Name)
+		}
+		if err := m.revert(migration); err != nil {
+			return ran, err
+		}
+		ran = append(ran, migration)
+	}
+	return ran, nil
+}
+
+// Redo rolls back the newest migration and applies it again.
+func (m *Migrator) Redo() (Migration, error) {
+	reverted, err := m.Down(1)
+	if err != nil {
+		return Migration{}, err
+	}
+	if err = m.apply(reverted[0]); err != nil {
+		return Migration{}, err
+	}
+	return reverted[0], nil
+}
+
+// Status lists every known migration with the time it was applied, if it was.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if row, ok := applied[migration.Version]; ok {
+			appliedAt := row.AppliedAt
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func (m *Migrator) apply(migration Migration) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(migration.Up).Error; err != nil {
+			return fmt.Errorf("applying %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		return tx.Create(&SchemaMigration{
+			Version: migration.Version,
+			Name: migration.Name,
+			AppliedAt: time.Now(),
+		}).Error
+	})
+}
+
+func (m *Migrator) revert(migration Migration) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(migration.Down).Error; err != nil {
+			return fmt.Errorf("reverting %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		return tx.Delete(&SchemaMigration{}, migration.Version).Error
+	})
+}
+
+func (m *Migrator) applied() (map[int64]SchemaMigration, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+
+	var rows []SchemaMigration
+	if err := m.db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]SchemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+func (m *Migrator) ensureTable() error {
+	return m.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL
+	)`).Error
+}
+
+func (m *Migrator) find(version int64) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
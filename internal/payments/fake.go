@@ -0,0 +1,134 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// FakeProvider is an in-process provider for local development and tests.
+// Intents succeed on confirm unless they were marked to fail with FailNext.
+type FakeProvider struct {
+	mu sync.Mutex
+	secret []byte
+	counter int
+	intents map[string]*Intent
+	refunds map[string]bool
+	failNext bool
+}
+
+func NewFakeProvider(webhookSecret string) *FakeProvider {
+	return &FakeProvider{
+		secret: []byte(webhookSecret),
+		intents: map[string]*Intent{},
+		refunds: map[string]bool{},
+	}
+}
+
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeProvider) SignatureHeader() string {
+	return SignatureHeader
+}
+
+func (p *FakeProvider) CreateIntent(amount int64, currency string, reference string) (Intent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.counter++
+	intent := &Intent{
+		ID: fmt.Sprintf("fake_pi_%d", p.counter),
+		ClientSecret: fmt.Sprintf("fake_secret_%s_%d", reference, p.counter),
+		Amount: amount,
+		Currency: currency,
+		Status: IntentPending,
+	}
+	p.intents[intent.ID] = intent
+
+	return *intent, nil
+}
+
+func (p *FakeProvider) ConfirmIntent(intentId string) (Intent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	intent, ok := p.intents[intentId]
+	if !ok {
+		return Intent{}, ErrIntentNotFound
+	}
+
+	if intent.Status == IntentPending {
+		if p.failNext {
+			intent.Status = IntentFailed
+			p.failNext = false
+		} else {
+			intent.Status = IntentSucceeded
+		}
+	}
+
+	return *intent, nil
+}
+
+func (p *FakeProvider) Refund(intentId string, amount int64, key string) (Intent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	intent, ok := p.intents[intentId]
+	if !ok {
+		return Intent{}, ErrIntentNotFound
+	}
+
+	// a retried refund is answered with the intent as it stands
+	if p.refunds[key] {
+		return *intent, nil
+	}
+
+	if intent.Status != IntentSucceeded || intent.Refunded+amount > intent.Amount {
+		return Intent{}, ErrRefundAmount
+	}
+
+	p.refunds[key] = true
+	intent.Refunded += amount
+	if intent.Refunded == intent.Amount {
+		intent.Status = IntentRefunded
+	}
+
+	return *intent, nil
+}
+
+func (p *FakeProvider) VerifyWebhook(payload []byte, signature string) (WebhookEvent, error) {
+	expected, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, p.sign(payload)) {
+		return WebhookEvent{}, ErrInvalidSignature
+	}
+
+	var event WebhookEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return WebhookEvent{}, err
+	}
+
+	return event, nil
+}
+
+// FailNext makes the next confirmed intent fail instead of succeeding.
+func (p *FakeProvider) FailNext() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failNext = true
+}
+
+// SignWebhook returns the signature header value the fake expects for the payload.
+func (p *FakeProvider) SignWebhook(payload []byte) string {
+	return hex.EncodeToString(p.sign(payload))
+}
+
+func (p *FakeProvider) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,94 @@
+package payments
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFakeIntentLifecycle(t *testing.T) {
+	provider := NewFakeProvider("secret")
+
+	intent, err := provider.CreateIntent(1000, "NGN", "1")
+	if err != nil || intent.Status != IntentPending {
+		t.Fatalf("creating intent = %+v %v", intent, err)
+	}
+
+	confirmed, err := provider.ConfirmIntent(intent.ID)
+	if err != nil || confirmed.Status != IntentSucceeded {
+		t.Fatalf("confirming = %+v %v, want %s", confirmed, err, IntentSucceeded)
+	}
+
+	// FailNext only fails the next confirmed intent
+	provider.FailNext()
+	failing, _ := provider.CreateIntent(1000, "NGN", "2")
+	if failed, _ := provider.ConfirmIntent(failing.ID); failed.Status != IntentFailed {
+		t.Errorf("confirming after FailNext = %s, want %s", failed.Status, IntentFailed)
+	}
+	next, _ := provider.CreateIntent(1000, "NGN", "3")
+	if succeeded, _ := provider.ConfirmIntent(next.ID); succeeded.Status != IntentSucceeded {
+		t.Errorf("confirming after a failure = %s, want %s", succeeded.Status, IntentSucceeded)
+	}
+
+	if _, err = provider.ConfirmIntent("fake_pi_unknown"); !errors.Is(err, ErrIntentNotFound) {
+		t.Errorf("confirming an unknown intent = %v, want %v", err, ErrIntentNotFound)
+	}
+}
+
+func TestFakeRefund(t *testing.T) {
+	provider := NewFakeProvider("secret")
+	intent, _ := provider.CreateIntent(1000, "NGN", "1")
+
+	// an intent that has not been paid cannot be refunded
+	if _, err := provider.Refund(intent.ID, 1000, "order-1"); !errors.Is(err, ErrRefundAmount) {
+		t.Errorf("refunding a pending intent = %v, want %v", err, ErrRefundAmount)
+	}
+	provider.ConfirmIntent(intent.ID)
+
+	partial, err := provider.Refund(intent.ID, 400, "order-1-a")
+	if err != nil || partial.Refunded != 400 || partial.Status != IntentSucceeded {
+		t.Fatalf("partial refund = %+v %v", partial, err)
+	}
+
+	// the same key is the same refund
+	again, err := provider.Refund(intent.ID, 400, "order-1-a")
+	if err != nil || again.Refunded != 400 {
+		t.Errorf("retried refund = %+v %v, want 400 refunded once", again, err)
+	}
+
+	if _, err = provider.Refund(intent.ID, 700, "order-1-b"); !errors.Is(err, ErrRefundAmount) {
+		t.Errorf("refunding past the amount = %v, want %v", err, ErrRefundAmount)
+	}
+
+	full, err := provider.Refund(intent.ID, 600, "order-1-c")
+	if err != nil || full.Status != IntentRefunded || full.Refunded != 1000 {
+		t.Errorf("refunding the rest = %+v %v, want refunded in full", full, err)
+	}
+
+	if _, err = provider.Refund("fake_pi_unknown", 100, "order-2"); !errors.Is(err, ErrIntentNotFound) {
+		t.Errorf("refunding an unknown intent = %v, want %v", err, ErrIntentNotFound)
+	}
+}
+
+func TestFakeWebhook(t *testing.T) {
+	provider := NewFakeProvider("secret")
+	payload := []byte(`{"type":"payment.succeeded","intentId":"fake_pi_1"}`)
+
+	event, err := provider.VerifyWebhook(payload, provider.SignWebhook(payload))
+	if err != nil {
+		t.Fatalf("verifying: %v", err)
+	}
+	if event.Type != WebhookPaymentSucceeded || event.IntentID != "fake_pi_1" {
+		t.Errorf("event = %+v", event)
+	}
+
+	other := NewFakeProvider("other")
+	for name, signature := range map[string]string{
+		"other secret": other.SignWebhook(payload),
+		"not hex": "zz",
+		"missing": "",
+	} {
+		if _, err := provider.VerifyWebhook(payload, signature); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s signature: error %v, want %v", name, err, ErrInvalidSignature)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package payments
+
+import (
+	"gorm.io/gorm"
+)
+
+const (
+	OrderPending string = "pending"
+	OrderPaid string = "paid"
+	OrderFailed string = "failed"
+	OrderRefunded string = "refunded"
+	// OrderCancelled is a checkout abandoned because its event was cancelled
+	OrderCancelled string = "cancelled"
+	// OrderExpired is a checkout left unpaid past OrderTTL
+	OrderExpired string = "expired"
+	// OrderRefunding is an order whose refund has been asked of the
+	// provider but not yet recorded, asking again is safe
+	OrderRefunding string = "refunding"
+)
+
+type Order struct {
+	gorm.Model
+
+	// other fields
+	UserID uint
+	TicketID uint
+	Units uint			`gorm:"not null"`
+	Amount float64		`gorm:"not null"`
+	Currency string		`gorm:"not null;default:NGN"`
+	Status string		`gorm:"not null;default:pending"`
+}
+
+type Payment struct {
+	gorm.Model
+
+	// other fields
+	OrderID uint
+	Provider string		`gorm:"not null"`
+	Reference string	`gorm:"unique;not null"`
+	Amount float64		`gorm:"not null"`
+	Status string		`gorm:"not null"`
+	RefundedAmount float64	`gorm:"not null;default:0"`
+}
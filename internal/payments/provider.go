@@ -0,0 +1,60 @@
+package payments
+
+import (
+	"errors"
+	"math"
+)
+
+const (
+	IntentPending string = "pending"
+	IntentSucceeded string = "succeeded"
+	IntentFailed string = "failed"
+	IntentRefunded string = "refunded"
+
+	WebhookPaymentSucceeded string = "payment.succeeded"
+	WebhookPaymentFailed string = "payment.failed"
+
+	SignatureHeader string = "X-Avana-Signature"
+)
+
+var (
+	ErrIntentNotFound = errors.New("payment intent not found")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrRefundAmount = errors.New("refund exceeds captured amount")
+)
+
+// PaymentProvider is implemented by every payment gateway avana can charge through.
+// Amounts are always in the minor unit of the currency (kobo, cents).
+type PaymentProvider interface {
+	Name() string
+	// SignatureHeader names the request header webhooks carry their signature in
+	SignatureHeader() string
+	CreateIntent(amount int64, currency string, reference string) (Intent, error)
+	ConfirmIntent(intentId string) (Intent, error)
+	// Refund returns part or all of the intent. Calls with the same key are
+	// the same refund, so a retry never pays out twice.
+	Refund(intentId string, amount int64, key string) (Intent, error)
+	VerifyWebhook(payload []byte, signature string) (WebhookEvent, error)
+}
+
+type Intent struct {
+	ID string
+	ClientSecret string
+	Amount int64
+	Refunded int64
+	Currency string
+	Status string
+}
+
+type WebhookEvent struct {
+	Type string		`json:"type"`
+	IntentID string	`json:"intentId"`
+}
+
+func ToMinorUnits(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+func FromMinorUnits(amount int64) float64 {
+	return float64(amount) / 100
+}
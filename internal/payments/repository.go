@@ -0,0 +1,113 @@
+package payments
+
+import (
+	"avana/internal/repository"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OrderRepository interface {
+	Create(order *Order) error
+	FindByID(id uint) (Order, error)
+	FindByIDForUpdate(id uint) (Order, error)
+	CountPending(userId, ticketId uint) (int64, error)
+	// ListStaleForUpdate locks the pending orders created before the cutoff,
+	// skipping rows another transaction is already working on.
+	ListStaleForUpdate(before time.Time) ([]Order, error)
+	// ListByTickets returns the orders for any of the tickets in the given status.
+	ListByTickets(ticketIds []uint, status string) ([]Order, error)
+	Save(order *Order) error
+}
+
+type PaymentRepository interface {
+	Create(payment *Payment) error
+	FindByOrder(orderId uint) (Payment, error)
+	FindByReference(reference string) (Payment, error)
+	Save(payment *Payment) error
+}
+
+type gormOrders struct {
+	db *gorm.DB
+}
+
+func NewGormOrderRepository(db *gorm.DB) OrderRepository {
+	return &gormOrders{db: db}
+}
+
+func (r *gormOrders) Create(order *Order) error {
+	return repository.GormError(r.db.Create(order).Error)
+}
+
+func (r *gormOrders) FindByID(id uint) (Order, error) {
+	var order Order
+	err := r.db.First(&order, id).Error
+	return order, repository.GormError(err)
+}
+
+func (r *gormOrders) FindByIDForUpdate(id uint) (Order, error) {
+	var order Order
+	err := r.db.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).First(&order, id).Error
+	return order, repository.GormError(err)
+}
+
+func (r *gormOrders) CountPending(userId, ticketId uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&Order{}).
+				Where("user_id = ? AND ticket_id = ? AND status = ?", userId, ticketId, OrderPending).
+				Count(&count).Error
+	return count, err
+}
+
+func (r *gormOrders) ListStaleForUpdate(before time.Time) ([]Order, error) {
+	var orders []Order
+	err := r.db.Clauses(clause.Locking{
+					Strength: clause.LockingStrengthUpdate,
+					Options: clause.LockingOptionsSkipLocked,
+				}).
+				Where("status = ? AND created_at < ?", OrderPending, before).
+				Find(&orders).Error
+	return orders, err
+}
+
+func (r *gormOrders) ListByTickets(ticketIds []uint, status string) ([]Order, error) {
+	var orders []Order
+	if len(ticketIds) == 0 {
+		return orders, nil
+	}
+	err := r.db.Where("ticket_id IN ? AND status = ?", ticketIds, status).Order("id").Find(&orders).Error
+	return orders, err
+}
+
+func (r *gormOrders) Save(order *Order) error {
+	return repository.GormError(r.db.Save(order).Error)
+}
+
+type gormPayments struct {
+	db *gorm.DB
+}
+
+func NewGormPaymentRepository(db *gorm.DB) PaymentRepository {
+	return &gormPayments{db: db}
+}
+
+func (r *gormPayments) Create(payment *Payment) error {
+	return repository.GormError(r.db.Create(payment).Error)
+}
+
+func (r *gormPayments) FindByOrder(orderId uint) (Payment, error) {
+	var payment Payment
+	err := r.db.Where("order_id = ?", orderId).First(&payment).Error
+	return payment, repository.GormError(err)
+}
+
+func (r *gormPayments) FindByReference(reference string) (Payment, error) {
+	var payment Payment
+	err := r.db.Where("reference = ?", reference).First(&payment).Error
+	return payment, repository.GormError(err)
+}
+
+func (r *gormPayments) Save(payment *Payment) error {
+	return repository.GormError(r.db.Save(payment).Error)
+}
@@ -0,0 +1,89 @@
+package payments
+
+import (
+	"avana/internal/repository"
+	"time"
+)
+
+type memoryOrders struct {
+	orders *repository.Table[Order]
+}
+
+// NewMemoryOrderRepository wraps a table owned by an in-memory store.
+func NewMemoryOrderRepository(orders *repository.Table[Order]) OrderRepository {
+	return &memoryOrders{orders: orders}
+}
+
+func (r *memoryOrders) Create(order *Order) error {
+	r.orders.Insert(order)
+	return nil
+}
+
+func (r *memoryOrders) FindByID(id uint) (Order, error) {
+	return r.orders.Get(id)
+}
+
+func (r *memoryOrders) FindByIDForUpdate(id uint) (Order, error) {
+	return r.orders.Get(id)
+}
+
+func (r *memoryOrders) CountPending(userId, ticketId uint) (int64, error) {
+	count := r.orders.Count(func(order Order) bool {
+		return order.UserID == userId && order.TicketID == ticketId && order.Status == OrderPending
+	})
+	return int64(count), nil
+}
+
+func (r *memoryOrders) ListStaleForUpdate(before time.Time) ([]Order, error) {
+	return r.orders.Where(func(order Order) bool {
+		return order.Status == OrderPending && order.CreatedAt.Before(before)
+	}), nil
+}
+
+func (r *memoryOrders) ListByTickets(ticketIds []uint, status string) ([]Order, error) {
+	wanted := map[uint]bool{}
+	for _, id := range ticketIds {
+		wanted[id] = true
+	}
+	return r.orders.Where(func(order Order) bool {
+		return wanted[order.TicketID] && order.Status == status
+	}), nil
+}
+
+func (r *memoryOrders) Save(order *Order) error {
+	return r.orders.Save(order)
+}
+
+type memoryPayments struct {
+	payments *repository.Table[Payment]
+}
+
+// NewMemoryPaymentRepository wraps a table owned by an in-memory store.
+func NewMemoryPaymentRepository(payments *repository.Table[Payment]) PaymentRepository {
+	return &memoryPayments{payments: payments}
+}
+
+func (r *memoryPayments) Create(payment *Payment) error {
+	_, err := r.FindByReference(payment.Reference)
+	if err == nil {
+		return repository.ErrDuplicate
+	}
+	r.payments.Insert(payment)
+	return nil
+}
+
+func (r *memoryPayments) FindByOrder(orderId uint) (Payment, error) {
+	return r.payments.First(func(payment Payment) bool {
+		return payment.OrderID == orderId
+	})
+}
+
+func (r *memoryPayments) FindByReference(reference string) (Payment, error) {
+	return r.payments.First(func(payment Payment) bool {
+		return payment.Reference == reference
+	})
+}
+
+func (r *memoryPayments) Save(payment *Payment) error {
+	return r.payments.Save(payment)
+}
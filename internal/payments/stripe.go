@@ -0,0 +1,251 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StripeSignatureTolerance is how old a signed webhook may be before it is
+// refused as a replay.
+const StripeSignatureTolerance = 5 * time.Minute
+
+const stripeAPI = "https://api.stripe.com"
+
+// StripeProvider charges through Stripe payment intents. The client
+// confirms the intent with its client secret, the server only reads its
+// status back and listens to the webhooks.
+type StripeProvider struct {
+	secretKey string
+	webhookSecret []byte
+	keyPrefix string
+	baseURL string
+	client *http.Client
+}
+
+// NewStripeProvider charges with the secret key. Every idempotency key is
+// prefixed with keyPrefix, so deployments sharing an account cannot replay
+// each other's requests.
+func NewStripeProvider(secretKey string, webhookSecret string, keyPrefix string) *StripeProvider {
+	return &StripeProvider{
+		secretKey: secretKey,
+		webhookSecret: []byte(webhookSecret),
+		keyPrefix: keyPrefix,
+		baseURL: stripeAPI,
+		client: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+type stripeIntent struct {
+	ID string				`json:"id"`
+	ClientSecret string		`json:"client_secret"`
+	Amount int64			`json:"amount"`
+	Currency string			`json:"currency"`
+	Status string			`json:"status"`
+	LatestCharge *struct {
+		AmountRefunded int64	`json:"amount_refunded"`
+	}						`json:"latest_charge"`
+}
+
+type stripeError struct {
+	Error struct {
+		Code string			`json:"code"`
+		Message string		`json:"message"`
+	}						`json:"error"`
+}
+
+func (p *StripeProvider) Name() string {
+	return "stripe"
+}
+
+func (p *StripeProvider) SignatureHeader() string {
+	return "Stripe-Signature"
+}
+
+func (p *StripeProvider) CreateIntent(amount int64, currency string, reference string) (Intent, error) {
+	form := url.Values{}
+	form.Set("amount", strconv.FormatInt(amount, 10))
+	form.Set("currency", strings.ToLower(currency))
+	form.Set("metadata[reference]", reference)
+	form.Set("automatic_payment_methods[enabled]", "true")
+
+	// the reference is the order, retrying it must not open a second intent
+	var intent stripeIntent
+	if err := p.do(http.MethodPost, "/v1/payment_intents", form, p.idempotencyKey("intent", reference), &intent); err != nil {
+		return Intent{}, err
+	}
+	return intent.toIntent(), nil
+}
+
+// ConfirmIntent reads the intent's status, the client has already
+// confirmed it with Stripe.
+func (p *StripeProvider) ConfirmIntent(intentId string) (Intent, error) {
+	return p.intent(intentId)
+}
+
+func (p *StripeProvider) Refund(intentId string, amount int64, key string) (Intent, error) {
+	form := url.Values{}
+	form.Set("payment_intent", intentId)
+	form.Set("amount", strconv.FormatInt(amount, 10))
+
+	if err := p.do(http.MethodPost, "/v1/refunds", form, p.idempotencyKey("refund", key), nil); err != nil {
+		return Intent{}, err
+	}
+	return p.intent(intentId)
+}
+
+// VerifyWebhook checks the Stripe-Signature header, "t=<unix>,v1=<hex>",
+// against the payload and reports payment intent events in avana's terms.
+// A failed attempt is not final, the customer may try again on the same
+// intent, so only a cancelled intent is reported as failed.
+func (p *StripeProvider) VerifyWebhook(payload []byte, signature string) (WebhookEvent, error) {
+	var timestamp string
+	var signatures [][]byte
+	for _, part := range strings.Split(signature, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			if decoded, err := hex.DecodeString(value); err == nil {
+				signatures = append(signatures, decoded)
+			}
+		}
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || time.Since(time.Unix(seconds, 0)) > StripeSignatureTolerance {
+		return WebhookEvent{}, ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, p.webhookSecret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	expected := mac.Sum(nil)
+
+	valid := false
+	for _, candidate := range signatures {
+		if hmac.Equal(candidate, expected) {
+			valid = true
+		}
+	}
+	if !valid {
+		return WebhookEvent{}, ErrInvalidSignature
+	}
+
+	var event struct {
+		Type string		`json:"type"`
+		Data struct {
+			Object struct {
+				ID string	`json:"id"`
+			}				`json:"object"`
+		}					`json:"data"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return WebhookEvent{}, err
+	}
+
+	webhook := WebhookEvent{Type: event.Type, IntentID: event.Data.Object.ID}
+	switch event.Type {
+	case "payment_intent.succeeded":
+		webhook.Type = WebhookPaymentSucceeded
+	case "payment_intent.canceled":
+		webhook.Type = WebhookPaymentFailed
+	}
+	return webhook, nil
+}
+
+func (p *StripeProvider) idempotencyKey(kind string, key string) string {
+	return p.keyPrefix + "-" + kind + "-" + key
+}
+
+func (p *StripeProvider) intent(intentId string) (Intent, error) {
+	var intent stripeIntent
+	path := "/v1/payment_intents/" + url.PathEscape(intentId) + "?expand[]=latest_charge"
+	if err := p.do(http.MethodGet, path, nil, "", &intent); err != nil {
+		return Intent{}, err
+	}
+	return intent.toIntent(), nil
+}
+
+func (p *StripeProvider) do(method string, path string, form url.Values, idempotencyKey string, out interface{}) error {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+
+	req, err := http.NewRequest(method, p.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.secretKey)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode >= 300 {
+		var failure stripeError
+		json.Unmarshal(data, &failure)
+		switch {
+		case res.StatusCode == http.StatusNotFound:
+			return ErrIntentNotFound
+		case failure.Error.Code == "charge_already_refunded" || failure.Error.Code == "amount_too_large":
+			return ErrRefundAmount
+		case failure.Error.Message != "":
+			return errors.New("stripe: " + failure.Error.Message)
+		}
+		return fmt.Errorf("stripe: unexpected status %d", res.StatusCode)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+func (i stripeIntent) toIntent() Intent {
+	intent := Intent{
+		ID: i.ID,
+		ClientSecret: i.ClientSecret,
+		Amount: i.Amount,
+		Currency: strings.ToUpper(i.Currency),
+		Status: IntentPending,
+	}
+	if i.LatestCharge != nil {
+		intent.Refunded = i.LatestCharge.AmountRefunded
+	}
+
+	switch i.Status {
+	case "succeeded":
+		intent.Status = IntentSucceeded
+		if intent.Refunded > 0 && intent.Refunded >= intent.Amount {
+			intent.Status = IntentRefunded
+		}
+	case "canceled":
+		intent.Status = IntentFailed
+	}
+	return intent
+}
@@ -0,0 +1,198 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestStripe points a provider at a local server standing in for the
+// Stripe API.
+func newTestStripe(t *testing.T, handler http.HandlerFunc) *StripeProvider {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	provider := NewStripeProvider("sk_test", "whsec_test", "test")
+	provider.baseURL = server.URL
+	return provider
+}
+
+func TestStripeCreateIntent(t *testing.T) {
+	provider := newTestStripe(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/payment_intents" {
+			t.Errorf("request = %s %s, want POST /v1/payment_intents", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer sk_test" {
+			t.Errorf("authorization = %q", got)
+		}
+		// the key is namespaced so other deployments on the account cannot collide
+		if got := r.Header.Get("Idempotency-Key"); got != "test-intent-42" {
+			t.Errorf("idempotency key = %q, want %q", got, "test-intent-42")
+		}
+		r.ParseForm()
+		if r.Form.Get("amount") != "250000" || r.Form.Get("currency") != "ngn" || r.Form.Get("metadata[reference]") != "42" {
+			t.Errorf("form = %v", r.Form)
+		}
+		fmt.Fprint(w, `{"id":"pi_1","client_secret":"pi_1_secret","amount":250000,"currency":"ngn","status":"requires_payment_method"}`)
+	})
+
+	intent, err := provider.CreateIntent(250000, "NGN", "42")
+	if err != nil {
+		t.Fatalf("creating intent: %v", err)
+	}
+	want := Intent{ID: "pi_1", ClientSecret: "pi_1_secret", Amount: 250000, Currency: "NGN", Status: IntentPending}
+	if intent != want {
+		t.Errorf("intent = %+v, want %+v", intent, want)
+	}
+}
+
+func TestStripeConfirmIntentStatuses(t *testing.T) {
+	for _, tc := range []struct {
+		body string
+		status string
+		refunded int64
+	}{
+		{body: `{"id":"pi_1","amount":500,"status":"processing"}`, status: IntentPending},
+		{body: `{"id":"pi_1","amount":500,"status":"succeeded","latest_charge":{"amount_refunded":0}}`, status: IntentSucceeded},
+		{body: `{"id":"pi_1","amount":500,"status":"succeeded","latest_charge":{"amount_refunded":200}}`, status: IntentSucceeded, refunded: 200},
+		{body: `{"id":"pi_1","amount":500,"status":"succeeded","latest_charge":{"amount_refunded":500}}`, status: IntentRefunded, refunded: 500},
+		{body: `{"id":"pi_1","amount":500,"status":"canceled"}`, status: IntentFailed},
+	} {
+		provider := newTestStripe(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet || r.URL.Path != "/v1/payment_intents/pi_1" || r.URL.Query().Get("expand[]") != "latest_charge" {
+				t.Errorf("request = %s %s", r.Method, r.URL)
+			}
+			fmt.Fprint(w, tc.body)
+		})
+
+		intent, err := provider.ConfirmIntent("pi_1")
+		if err != nil {
+			t.Fatalf("confirming %s: %v", tc.body, err)
+		}
+		if intent.Status != tc.status || intent.Refunded != tc.refunded {
+			t.Errorf("%s: status %q refunded %d, want %q and %d", tc.body, intent.Status, intent.Refunded, tc.status, tc.refunded)
+		}
+	}
+}
+
+func TestStripeRefund(t *testing.T) {
+	refunded := false
+	provider := newTestStripe(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/refunds":
+			if got := r.Header.Get("Idempotency-Key"); got != "test-refund-order-7" {
+				t.Errorf("idempotency key = %q, want %q", got, "test-refund-order-7")
+			}
+			r.ParseForm()
+			if r.Form.Get("payment_intent") != "pi_1" || r.Form.Get("amount") != "500" {
+				t.Errorf("form = %v", r.Form)
+			}
+			refunded = true
+			fmt.Fprint(w, `{"id":"re_1","status":"succeeded"}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/payment_intents/pi_1":
+			fmt.Fprint(w, `{"id":"pi_1","amount":500,"status":"succeeded","latest_charge":{"amount_refunded":500}}`)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+	})
+
+	intent, err := provider.Refund("pi_1", 500, "order-7")
+	if err != nil {
+		t.Fatalf("refunding: %v", err)
+	}
+	if !refunded {
+		t.Fatal("no refund was created")
+	}
+	if intent.Status != IntentRefunded || intent.Refunded != 500 {
+		t.Errorf("intent = %+v, want refunded in full", intent)
+	}
+}
+
+func TestStripeErrors(t *testing.T) {
+	for _, tc := range []struct {
+		status int
+		body string
+		want error
+		message string
+	}{
+		{status: http.StatusNotFound, body: `{"error":{"code":"resource_missing","message":"No such payment_intent"}}`, want: ErrIntentNotFound},
+		{status: http.StatusBadRequest, body: `{"error":{"code":"charge_already_refunded","message":"Charge has already been refunded"}}`, want: ErrRefundAmount},
+		{status: http.StatusBadRequest, body: `{"error":{"code":"amount_too_large","message":"Refund amount is greater than unrefunded amount"}}`, want: ErrRefundAmount},
+		{status: http.StatusPaymentRequired, body: `{"error":{"code":"card_declined","message":"Your card was declined"}}`, message: "stripe: Your card was declined"},
+		{status: http.StatusInternalServerError, body: `oops`, message: "stripe: unexpected status 500"},
+	} {
+		provider := newTestStripe(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tc.status)
+			fmt.Fprint(w, tc.body)
+		})
+
+		_, err := provider.Refund("pi_1", 500, "order-1")
+		if err == nil {
+			t.Errorf("%d %s: no error", tc.status, tc.body)
+			continue
+		}
+		if tc.want != nil && !errors.Is(err, tc.want) {
+			t.Errorf("%d %s: error %v, want %v", tc.status, tc.body, err, tc.want)
+		}
+		if tc.message != "" && err.Error() != tc.message {
+			t.Errorf("%d %s: error %q, want %q", tc.status, tc.body, err, tc.message)
+		}
+	}
+}
+
+// stripeSignature signs the payload the way Stripe does.
+func stripeSignature(secret string, at time.Time, payload []byte) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestStripeVerifyWebhook(t *testing.T) {
+	provider := NewStripeProvider("sk_test", "whsec_test", "test")
+	payload := []byte(`{"type":"payment_intent.succeeded","data":{"object":{"id":"pi_1"}}}`)
+
+	event, err := provider.VerifyWebhook(payload, stripeSignature("whsec_test", time.Now(), payload))
+	if err != nil {
+		t.Fatalf("verifying: %v", err)
+	}
+	if event.Type != WebhookPaymentSucceeded || event.IntentID != "pi_1" {
+		t.Errorf("event = %+v", event)
+	}
+
+	canceled := []byte(`{"type":"payment_intent.canceled","data":{"object":{"id":"pi_2"}}}`)
+	event, err = provider.VerifyWebhook(canceled, stripeSignature("whsec_test", time.Now(), canceled))
+	if err != nil || event.Type != WebhookPaymentFailed {
+		t.Errorf("canceled intent = %+v %v, want %s", event, err, WebhookPaymentFailed)
+	}
+
+	// other events pass through under their own type
+	other := []byte(`{"type":"charge.refunded","data":{"object":{"id":"ch_1"}}}`)
+	event, err = provider.VerifyWebhook(other, stripeSignature("whsec_test", time.Now(), other))
+	if err != nil || event.Type != "charge.refunded" {
+		t.Errorf("other event = %+v %v", event, err)
+	}
+
+	for name, signature := range map[string]string{
+		"wrong secret": stripeSignature("whsec_other", time.Now(), payload),
+		"stale": stripeSignature("whsec_test", time.Now().Add(-StripeSignatureTolerance-time.Minute), payload),
+		"tampered": stripeSignature("whsec_test", time.Now(), []byte(strings.Replace(string(payload), "pi_1", "pi_9", 1))),
+		"missing": "",
+		"no timestamp": "v1=" + strings.Repeat("0", 64),
+	} {
+		if _, err := provider.VerifyWebhook(payload, signature); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s signature: error %v, want %v", name, err, ErrInvalidSignature)
+		}
+	}
+}
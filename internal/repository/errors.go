@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"avana/internal/apperror"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// The repository errors are client errors as they stand, a missing row is a
+// 404 and a duplicate is a conflict wherever they surface.
+var (
+	ErrNotFound = apperror.ErrNotFound
+	ErrDuplicate = apperror.ErrConflict
+)
+
+// GormError maps gorm's errors onto the repository errors so callers do not
+// depend on which implementation they were given.
+func GormError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrDuplicate
+	}
+	return err
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Table is an in-memory table of gorm models keyed by their ID, used by the
+// in-memory repositories. Every table of one store shares the store's lock,
+// so a transaction can hold the whole store while it works on a copy.
+type Table[T any] struct {
+	mu *sync.Mutex
+	nextID uint
+	rows map[uint]T
+}
+
+func NewTable[T any](mu *sync.Mutex) *Table[T] {
+	return &Table[T]{
+		mu: mu,
+		nextID: 1,
+		rows: map[uint]T{},
+	}
+}
+
+// Insert stores the row under a new ID and fills in its ID and timestamps.
+func (t *Table[T]) Insert(row *T) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	setField(row, "ID", t.nextID)
+	setField(row, "CreatedAt", now)
+	setField(row, "UpdatedAt", now)
+
+	t.rows[t.nextID] = *row
+	t.nextID++
+}
+
+// Save overwrites the stored row with the same ID, inserting it when the ID is zero.
+func (t *Table[T]) Save(row *T) error {
+	id := getID(row)
+	if id == 0 {
+		t.Insert(row)
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.rows[id]; !ok {
+		return ErrNotFound
+	}
+	setField(row, "UpdatedAt", time.Now())
+	t.rows[id] = *row
+	return nil
+}
+
+// Modify applies fn to the stored row under the lock, keeping the change
+// only when fn succeeds.
+func (t *Table[T]) Modify(id uint, fn func(row *T) error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	row, ok := t.rows[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := fn(&row); err != nil {
+		return err
+	}
+	setField(&row, "UpdatedAt", time.Now())
+	t.rows[id] = row
+	return nil
+}
+
+func (t *Table[T]) Get(id uint) (T, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	row, ok := t.rows[id]
+	if !ok {
+		var zero T
+		return zero, ErrNotFound
+	}
+	return row, nil
+}
+
+func (t *Table[T]) Delete(id uint) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.rows, id)
+}
+
+func (t *Table[T]) DeleteWhere(match func(T) bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, row := range t.rows {
+		if match(row) {
+			delete(t.rows, id)
+		}
+	}
+}
+
+// Where returns the matching rows in ID order.
+func (t *Table[T]) Where(match func(T) bool) []T {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ids := make([]uint, 0, len(t.rows))
+	for id, row := range t.rows {
+		if match == nil || match(row) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	rows := make([]T, 0, len(ids))
+	for _, id := range ids {
+		rows = append(rows, t.rows[id])
+	}
+	return rows
+}
+
+func (t *Table[T]) First(match func(T) bool) (T, error) {
+	rows := t.Where(match)
+	if len(rows) == 0 {
+		var zero T
+		return zero, ErrNotFound
+	}
+	return rows[0], nil
+}
+
+func (t *Table[T]) Count(match func(T) bool) int {
+	return len(t.Where(match))
+}
+
+// Clone copies the table for a transaction, guarded by its own lock.
+func (t *Table[T]) Clone(mu *sync.Mutex) *Table[T] {
+	clone := NewTable[T](mu)
+	clone.nextID = t.nextID
+	for id, row := range t.rows {
+		clone.rows[id] = row
+	}
+	return clone
+}
+
+// Replace takes over the rows of a committed transaction's copy. The caller
+// must already hold the table's lock.
+func (t *Table[T]) Replace(from *Table[T]) {
+	t.nextID = from.nextID
+	t.rows = from.rows
+}
+
+func getID(row any) uint {
+	field := reflect.ValueOf(row).Elem().FieldByName("ID")
+	if !field.IsValid() {
+		return 0
+	}
+	return uint(field.Uint())
+}
+
+func setField(row any, name string, value any) {
+	field := reflect.ValueOf(row).Elem().FieldByName(name)
+	if field.IsValid() && field.CanSet() {
+		field.Set(reflect.ValueOf(value))
+	}
+}
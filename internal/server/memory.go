@@ -0,0 +1,70 @@
+package server
+
+import (
+	"avana/internal/config"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"avana/internal/events"
+	"avana/internal/mailer"
+	"avana/internal/payments"
+	"avana/internal/tokens"
+	"avana/internal/users"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// NewMemoryDependencies wires the routes to in-process stores, an HMAC
+// signing key, the fake payment provider and a memory mailer, so the whole
+// API can be driven without a database or network. Ticket codes are signed
+// with a key derived from the same secret.
+func NewMemoryDependencies(cfg *config.Config) Dependencies {
+	secret := cfg.Auth.JWTSecret
+	if secret == "" {
+		secret = "avana-memory-secret"
+	}
+
+	seed := sha256.Sum256([]byte(secret))
+
+	return Dependencies{
+		Config: cfg,
+		Keys: tokens.NewHMACKeySet(secret),
+		TicketSigner: tokens.NewTicketSigner("memory", ed25519.NewKeyFromSeed(seed[:])),
+		Users: users.NewMemoryStore(),
+		Events: events.NewMemoryStore(),
+		Payments: payments.NewFakeProvider(cfg.Payments.WebhookSecret),
+		Mailer: mailer.NewMemoryMailer(),
+	}
+}
+
+// SeedUser stores a user with the given password and role, bypassing signup.
+func SeedUser(store users.Store, email, password, role string) (users.User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		return users.User{}, err
+	}
+
+	user := users.User{
+		FirstName: "Seed",
+		LastName: "User",
+		Email: email,
+		Password: string(hash),
+		Role: role,
+	}
+	if err = store.Users().Create(&user); err != nil {
+		return users.User{}, err
+	}
+	return user, nil
+}
+
+// SeedEvent creates and publishes an event owned by the user through the
+// same rules the create and publish routes apply.
+func SeedEvent(deps Dependencies, owner users.User, schema events.CreateEventSchema) (events.Event, error) {
+	service := events.NewEventService(deps.Events, deps.Users.Users(), deps.Payments, deps.Mailer, deps.TicketSigner)
+	actor := events.Actor{ID: owner.ID, Role: owner.Role}
+
+	event, err := service.Create(actor, schema)
+	if err != nil {
+		return events.Event{}, err
+	}
+	return service.Publish(actor, event.ID)
+}
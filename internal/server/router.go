@@ -0,0 +1,118 @@
+package server
+
+import (
+	"avana/internal/config"
+	"avana/internal/events"
+	"avana/internal/mailer"
+	"avana/internal/middlewares"
+	"avana/internal/payments"
+	"avana/internal/tokens"
+	"avana/internal/users"
+	"avana/internal/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Dependencies is everything the routes need. cmd/server fills it from the
+// database and config, NewMemoryDependencies builds one that runs in process.
+type Dependencies struct {
+	Config *config.Config
+	Keys *tokens.KeySet
+	Users users.Store
+	Events events.Store
+	Payments payments.PaymentProvider
+	Mailer mailer.Mailer
+	TicketSigner *tokens.TicketSigner
+}
+
+// NewRouter registers every avana route on a new engine.
+func NewRouter(deps Dependencies) *gin.Engine {
+	// the schemas' binding tags refer to these rules, a failure here is a
+	// programming error rather than something to recover from
+	if err := validation.Register(); err != nil {
+		panic(err)
+	}
+
+	userHandler := users.NewHandler(deps.Users, deps.Config.Auth, deps.Keys, deps.Mailer)
+	eventHandler := events.NewHandler(deps.Events, deps.Users.Users(), deps.Payments, deps.Mailer, deps.TicketSigner)
+
+	r := gin.Default()
+	requireAuth := middlewares.RequireAuth(deps.Keys, deps.Users)
+	optionalAuth := middlewares.OptionalAuth(deps.Keys, deps.Users)
+
+	r.GET("/.well-known/jwks.json",deps.Keys.JWKSHandler)
+	r.GET("/.well-known/ticket-keys.json",deps.TicketSigner.JWKSHandler)
+
+	usergroup := r.Group("/user")
+	usergroup.POST("/create",userHandler.CreateUser)
+	usergroup.POST("/login",userHandler.Login)
+	usergroup.POST("/otp",userHandler.GetOtp)
+	usergroup.POST("/otp/verify",userHandler.VerifyOtp)
+	usergroup.POST("/password/change",userHandler.ChangePassword)
+	usergroup.POST("/token/refresh",userHandler.Refresh)
+	usergroup.POST("/logout",requireAuth,userHandler.Logout)
+	usergroup.POST("/logout/all",requireAuth,userHandler.LogoutAll)
+	usergroup.POST("/role-request",requireAuth,userHandler.RequestRole)
+
+
+	eventgroup := r.Group("/event")
+	eventgroup.POST("/create",requireAuth,middlewares.RequirePermission(users.PermCreateEvent),eventHandler.CreateEvent)
+	eventgroup.GET("/:id",optionalAuth,eventHandler.GetEventByID)
+	eventgroup.GET("/all",eventHandler.GetAllEvent)
+	eventgroup.GET("/mine",requireAuth,eventHandler.GetMyEvents)
+	eventgroup.GET("/tickets/mine",requireAuth,eventHandler.GetMyTickets)
+	eventgroup.GET("/tickets/code/:serial/qr",requireAuth,eventHandler.GetTicketQR)
+	eventgroup.POST("/attendee/:id/transfer",requireAuth,eventHandler.TransferTicket)
+	eventgroup.GET("/dashboard",requireAuth,middlewares.RequirePermission(users.PermCreateEvent),eventHandler.GetDashboard)
+	eventgroup.GET("/:id/ticket/all",optionalAuth,eventHandler.GetAllTickets)
+	eventgroup.GET("/ticket/:id",optionalAuth,eventHandler.GetTicketById)
+	eventgroup.PATCH("/update/:id",requireAuth,eventHandler.UpdateEvent)
+	eventgroup.POST("/:id/publish",requireAuth,eventHandler.PublishEvent)
+	eventgroup.POST("/:id/postpone",requireAuth,eventHandler.PostponeEvent)
+	eventgroup.POST("/:id/cancel",requireAuth,eventHandler.CancelEvent)
+	eventgroup.GET("/:id/cancellation",requireAuth,eventHandler.GetCancellationReport)
+	eventgroup.POST("/:id/cancellation/retry",requireAuth,eventHandler.RetryCancellation)
+	eventgroup.POST("/:id/complete",requireAuth,eventHandler.CompleteEvent)
+	eventgroup.POST("/:id/ticket/create",requireAuth,eventHandler.AddTicket)
+	eventgroup.PATCH("/ticket/:id",requireAuth,eventHandler.UpdateTicket)
+	eventgroup.DELETE("/ticket/:id",requireAuth,eventHandler.DeleteTicket)
+	eventgroup.DELETE("/:id",requireAuth,eventHandler.DeleteEvent)
+	eventgroup.POST("/ticket/:id/buy", requireAuth,middlewares.RequirePermission(users.PermBuyTicket),eventHandler.BuyTicket)
+	eventgroup.GET("/:id/attendees",requireAuth,eventHandler.GetTotalAttendees)
+	eventgroup.POST("/:id/checkin",requireAuth,eventHandler.VerifyAttendance)
+	eventgroup.GET("/:id/checkins",requireAuth,eventHandler.GetCheckIns)
+	eventgroup.GET("/:id/checkin/manifest",requireAuth,eventHandler.GetCheckInManifest)
+	eventgroup.POST("/:id/checkin/sync",requireAuth,eventHandler.SyncCheckIns)
+	eventgroup.GET("/:id/reviews",optionalAuth,eventHandler.GetAllReviews)
+	eventgroup.POST("/:id/reviews",requireAuth,eventHandler.ReviewEvent)
+	eventgroup.PATCH("/:id/reviews",requireAuth,eventHandler.EditReview)
+	eventgroup.GET("/:id/reviews/stats",optionalAuth,eventHandler.GetReviewStats)
+	eventgroup.POST("/reviews/:id/flag",requireAuth,eventHandler.FlagReview)
+	eventgroup.PUT("/reviews/:id/reply",requireAuth,eventHandler.ReplyToReview)
+	eventgroup.GET("/organiser/:id/reviews/stats",eventHandler.GetOrganiserReviewStats)
+	eventgroup.GET("/:id/members",requireAuth,eventHandler.GetEventMembers)
+	eventgroup.POST("/:id/members/invite",requireAuth,eventHandler.InviteMember)
+	eventgroup.POST("/:id/members/accept",requireAuth,eventHandler.AcceptInvite)
+	eventgroup.DELETE("/:id/members/:memberId",requireAuth,eventHandler.RemoveMember)
+	eventgroup.POST("/order/:id/confirm", requireAuth,eventHandler.ConfirmOrder)
+	eventgroup.POST("/ticket/:id/hold", requireAuth,middlewares.RequirePermission(users.PermBuyTicket),eventHandler.HoldTicket)
+	eventgroup.PATCH("/hold/:id/extend", requireAuth,eventHandler.ExtendHold)
+	eventgroup.DELETE("/hold/:id", requireAuth,eventHandler.CancelHold)
+	eventgroup.POST("/hold/:id/checkout", requireAuth,eventHandler.CheckoutHold)
+
+	admingroup := r.Group("/admin",requireAuth,middlewares.RequirePermission(users.PermManageUsers))
+	admingroup.GET("/users",userHandler.ListUsers)
+	admingroup.PATCH("/users/:id/role",userHandler.UpdateUserRole)
+	admingroup.DELETE("/users/:id",userHandler.DeleteUser)
+	admingroup.GET("/role-requests",userHandler.ListRoleRequests)
+	admingroup.POST("/role-requests/:id/decide",userHandler.DecideRoleRequest)
+
+	moderationgroup := r.Group("/admin/reviews",requireAuth,middlewares.RequirePermission(users.PermModerateReviews))
+	moderationgroup.GET("",eventHandler.GetModerationQueue)
+	moderationgroup.POST("/:id/moderate",eventHandler.ModerateReview)
+
+	paymentgroup := r.Group("/payment")
+	paymentgroup.POST("/webhook",eventHandler.PaymentWebhook)
+
+	return r
+}
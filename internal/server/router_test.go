@@ -0,0 +1,1020 @@
+package server
+
+import (
+	"avana/internal/config"
+	"avana/internal/dbtest"
+	"avana/internal/events"
+	"avana/internal/mailer"
+	"avana/internal/payments"
+	"avana/internal/users"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testPassword = "Passw0rd!x"
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	os.Exit(m.Run())
+}
+
+// testBackend builds the dependencies the router runs on.
+type testBackend struct {
+	name string
+	open func(t *testing.T, cfg *config.Config) Dependencies
+}
+
+// backends are the memory stores and the gorm stores on postgres, whose
+// queries, row locks and conditional updates are what production runs. The
+// postgres run is skipped unless AVANA_TEST_DATABASE_DSN is set.
+var backends = []testBackend{
+	{name: "memory", open: func(t *testing.T, cfg *config.Config) Dependencies {
+		return NewMemoryDependencies(cfg)
+	}},
+	{name: "postgres", open: func(t *testing.T, cfg *config.Config) Dependencies {
+		db := dbtest.Open(t)
+		deps := NewMemoryDependencies(cfg)
+		deps.Users = users.NewGormStore(db)
+		deps.Events = events.NewGormStore(db)
+		return deps
+	}},
+}
+
+// testAPI drives the real router over a backend's dependencies.
+type testAPI struct {
+	t *testing.T
+	deps Dependencies
+	router *gin.Engine
+}
+
+func newTestAPI(t *testing.T, backend testBackend) *testAPI {
+	t.Helper()
+
+	cfg := config.Defaults()
+	deps := backend.open(t, &cfg)
+	return &testAPI{t: t, deps: deps, router: NewRouter(deps)}
+}
+
+// forEachBackend runs the test on a fresh API over each backend.
+func forEachBackend(t *testing.T, test func(t *testing.T, api *testAPI)) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			test(t, newTestAPI(t, backend))
+		})
+	}
+}
+
+// do sends a JSON request, with a bearer token when one is given, and
+// decodes the JSON response.
+func (a *testAPI) do(method, path, token string, body interface{}) (int, map[string]interface{}) {
+	a.t.Helper()
+
+	var payload io.Reader = http.NoBody
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			a.t.Fatalf("encoding %s %s: %v", method, path, err)
+		}
+		payload = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, payload)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, req)
+
+	var out map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &out)
+	return w.Code, out
+}
+
+// expect is do that fails the test unless the response has the status.
+func (a *testAPI) expect(status int, method, path, token string, body interface{}) map[string]interface{} {
+	a.t.Helper()
+
+	code, out := a.do(method, path, token, body)
+	if code != status {
+		a.t.Fatalf("%s %s = %d %v, want %d", method, path, code, out, status)
+	}
+	return out
+}
+
+func (a *testAPI) login(email, password string) string {
+	a.t.Helper()
+
+	out := a.expect(http.StatusOK, "POST", "/user/login", "", gin.H{"Email": email, "Password": password})
+	token, _ := out["token"].(string)
+	if token == "" {
+		a.t.Fatalf("login for %s returned no token: %v", email, out)
+	}
+	return token
+}
+
+// user seeds an account with the role and signs it in.
+func (a *testAPI) user(email, role string) (users.User, string) {
+	a.t.Helper()
+
+	user, err := SeedUser(a.deps.Users, email, testPassword, role)
+	if err != nil {
+		a.t.Fatalf("seeding %s: %v", email, err)
+	}
+	return user, a.login(email, testPassword)
+}
+
+// event seeds a published event owned by the user and returns it with its
+// first ticket.
+func (a *testAPI) event(owner users.User, schema events.CreateEventSchema) (events.Event, events.Ticket) {
+	a.t.Helper()
+
+	event, err := SeedEvent(a.deps, owner, schema)
+	if err != nil {
+		a.t.Fatalf("seeding event %s: %v", schema.Name, err)
+	}
+	tickets, err := a.deps.Events.Tickets().ListByEvent(event.ID)
+	if err != nil || len(tickets) == 0 {
+		a.t.Fatalf("listing tickets of %s: %v", schema.Name, err)
+	}
+	return event, tickets[0]
+}
+
+func freeEvent(name string) events.CreateEventSchema {
+	return events.CreateEventSchema{
+		Name: name,
+		Location: "Lagos",
+		Organiser: "Avana",
+		Description: "An event",
+		MaxUnitReservation: 2,
+		IsLimitedEvent: true,
+		TotalTicketLimit: 10,
+		EventDate: "2030-01-02 15:04:05",
+		RegistrationExpirationDate: "2030-01-01 15:04:05",
+	}
+}
+
+func paidEvent(name string, price float64) events.CreateEventSchema {
+	total := uint(10)
+	schema := freeEvent(name)
+	schema.IsPaidEvent = true
+	schema.Tickets = []events.TicketSchema{{
+		Name: "General",
+		Price: price,
+		TotalAvailable: &total,
+		SingleLimit: 2,
+		ExpiryTime: "2029-12-01 00:00:00",
+	}}
+	return schema
+}
+
+func (a *testAPI) lastOtp(email string) string {
+	a.t.Helper()
+
+	message, ok := a.deps.Mailer.(*mailer.MemoryMailer).Last(email)
+	if !ok {
+		a.t.Fatalf("no mail sent to %s", email)
+	}
+	otp := regexp.MustCompile(`\b\d{5}\b`).FindString(message.Text)
+	if otp == "" {
+		a.t.Fatalf("no code in the mail to %s: %q", email, message.Text)
+	}
+	return otp
+}
+
+func TestAccountFlow(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, api *testAPI) {
+		email := "ada@avana.test"
+
+		signup := gin.H{"Email": email, "Password": testPassword, "FirstName": "Ada", "LastName": "Obi"}
+		api.expect(http.StatusCreated, "POST", "/user/create", "", signup)
+		api.expect(http.StatusConflict, "POST", "/user/create", "", signup)
+		api.expect(http.StatusBadRequest, "POST", "/user/create", "", gin.H{"Email": "x@avana.test", "Password": "short", "FirstName": "A", "LastName": "B"})
+
+		// signups start as attendees
+		stored, err := api.deps.Users.Users().FindByEmail(email)
+		if err != nil {
+			t.Fatalf("finding the new user: %v", err)
+		}
+		if stored.Role != users.RoleAttendee {
+			t.Errorf("new user role = %q, want %q", stored.Role, users.RoleAttendee)
+		}
+
+		api.expect(http.StatusUnauthorized, "POST", "/user/login", "", gin.H{"Email": email, "Password": "Wrong-pass1"})
+		api.login(email, testPassword)
+
+		// a password change needs the token a verified code hands out
+		newPassword := "N3w-passw0rd"
+		api.expect(http.StatusBadRequest, "POST", "/user/password/change", "", gin.H{"Email": email, "Password": newPassword})
+		api.expect(http.StatusForbidden, "POST", "/user/password/change", "", gin.H{"ResetToken": "guessed", "Password": newPassword})
+
+		// an unknown email gets the same answer and no mail
+		unknown := api.expect(http.StatusOK, "POST", "/user/otp", "", gin.H{"Email": "nobody@avana.test"})
+		known := api.expect(http.StatusOK, "POST", "/user/otp", "", gin.H{"Email": email})
+		if unknown["message"] != known["message"] {
+			t.Errorf("otp answer for an unknown email = %v, want %v", unknown, known)
+		}
+		if _, sent := api.deps.Mailer.(*mailer.MemoryMailer).Last("nobody@avana.test"); sent {
+			t.Errorf("a code was mailed to an unknown email")
+		}
+		api.expect(http.StatusBadRequest, "POST", "/user/otp/verify", "", gin.H{"Email": "nobody@avana.test", "Otp": "12345"})
+		otp := api.lastOtp(email)
+		wrong := "00000"
+		if otp == wrong {
+			wrong = "11111"
+		}
+		api.expect(http.StatusBadRequest, "POST", "/user/otp/verify", "", gin.H{"Email": email, "Otp": wrong})
+		verified := api.expect(http.StatusOK, "POST", "/user/otp/verify", "", gin.H{"Email": email, "Otp": otp})
+		resetToken, _ := verified["resetToken"].(string)
+		if resetToken == "" {
+			t.Fatalf("verifying the code returned no reset token: %v", verified)
+		}
+		// a code is good for one verification
+		api.expect(http.StatusBadRequest, "POST", "/user/otp/verify", "", gin.H{"Email": email, "Otp": otp})
+
+		api.expect(http.StatusOK, "POST", "/user/password/change", "", gin.H{"ResetToken": resetToken, "Password": newPassword})
+		// and the token is good for one change
+		api.expect(http.StatusForbidden, "POST", "/user/password/change", "", gin.H{"ResetToken": resetToken, "Password": "An0ther-passw0rd"})
+		api.expect(http.StatusUnauthorized, "POST", "/user/login", "", gin.H{"Email": email, "Password": testPassword})
+		token := api.login(email, newPassword)
+
+		api.expect(http.StatusOK, "POST", "/user/logout", token, nil)
+		api.expect(http.StatusUnauthorized, "GET", "/event/mine", token, nil)
+	})
+}
+
+func TestEventAndTicketManagement(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, api *testAPI) {
+		_, organiser := api.user("organiser@avana.test", users.RoleOrganiser)
+
+		create := freeEvent("Launch")
+		api.expect(http.StatusOK, "POST", "/event/create", organiser, create)
+
+		mine := api.expect(http.StatusOK, "GET", "/event/mine", organiser, nil)
+		list, _ := mine["event"].([]interface{})
+		if len(list) != 1 {
+			t.Fatalf("organiser has %d events, want 1", len(list))
+		}
+		eventId := int(list[0].(map[string]interface{})["ID"].(float64))
+		eventPath := fmt.Sprintf("/event/%d", eventId)
+
+		// a draft and its reviews are hidden outside the team
+		_, stranger := api.user("stranger@avana.test", users.RoleAttendee)
+		for _, path := range []string{eventPath, eventPath + "/reviews", eventPath + "/reviews/stats"} {
+			api.expect(http.StatusNotFound, "GET", path, "", nil)
+			api.expect(http.StatusNotFound, "GET", path, stranger, nil)
+			api.expect(http.StatusOK, "GET", path, organiser, nil)
+		}
+
+		api.expect(http.StatusOK, "PATCH", fmt.Sprintf("/event/update/%d", eventId), organiser, gin.H{"Name": "Relaunch"})
+		got := api.expect(http.StatusOK, "GET", eventPath, organiser, nil)
+		if name := got["event"].(map[string]interface{})["Name"]; name != "Relaunch" {
+			t.Errorf("event name = %v, want Relaunch", name)
+		}
+
+		api.expect(http.StatusOK, "POST", eventPath+"/ticket/create", organiser, gin.H{
+			"Name": "Early bird", "TotalAvailable": 5, "SingleLimit": 1, "ExpiryTime": "2029-06-01 00:00:00",
+		})
+		all := api.expect(http.StatusOK, "GET", eventPath+"/ticket/all", organiser, nil)
+		tickets, _ := all["tickets"].([]interface{})
+		if len(tickets) != 2 {
+			t.Fatalf("event has %d tickets, want the default one and the new one", len(tickets))
+		}
+		ticketId := int(tickets[1].(map[string]interface{})["ID"].(float64))
+		ticketPath := fmt.Sprintf("/event/ticket/%d", ticketId)
+
+		api.expect(http.StatusOK, "PATCH", ticketPath, organiser, gin.H{"Name": "Late bird", "TotalAvailable": 8})
+		ticket := api.expect(http.StatusOK, "GET", ticketPath, organiser, nil)["ticket"].(map[string]interface{})
+		if ticket["Name"] != "Late bird" || ticket["TotalAvailable"] != float64(8) {
+			t.Errorf("updated ticket = %v", ticket)
+		}
+		api.expect(http.StatusOK, "PATCH", ticketPath, organiser, gin.H{"Unlimited": true})
+		ticket = api.expect(http.StatusOK, "GET", ticketPath, organiser, nil)["ticket"].(map[string]interface{})
+		if ticket["TotalAvailable"] != nil {
+			t.Errorf("unlimited ticket total = %v, want null", ticket["TotalAvailable"])
+		}
+
+		api.expect(http.StatusOK, "DELETE", ticketPath, organiser, nil)
+		api.expect(http.StatusNotFound, "GET", ticketPath, organiser, nil)
+
+		// deleting an event cancels it, buyers keep a record of what happened
+		api.expect(http.StatusOK, "DELETE", eventPath, organiser, nil)
+		got = api.expect(http.StatusOK, "GET", eventPath, organiser, nil)
+		if status := got["event"].(map[string]interface{})["Status"]; status != events.EventCancelled {
+			t.Errorf("deleted event status = %v, want %s", status, events.EventCancelled)
+		}
+	})
+}
+
+func TestBuyAndAttendeeList(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, api *testAPI) {
+		owner, organiser := api.user("organiser@avana.test", users.RoleOrganiser)
+		_, buyer := api.user("buyer@avana.test", users.RoleAttendee)
+
+		free, freeTicket := api.event(owner, freeEvent("Meetup"))
+		buyPath := fmt.Sprintf("/event/ticket/%d/buy", freeTicket.ID)
+		api.expect(http.StatusOK, "POST", buyPath, buyer, gin.H{"Units": 2})
+		api.expect(http.StatusConflict, "POST", buyPath, buyer, gin.H{"Units": 1})
+
+		attendees := api.expect(http.StatusOK, "GET", fmt.Sprintf("/event/%d/attendees", free.ID), organiser, nil)
+		if count := attendees["attendeeCount"]; count != float64(1) {
+			t.Errorf("free event attendee count = %v, want 1", count)
+		}
+
+		paid, paidTicket := api.event(owner, paidEvent("Concert", 2500))
+		order := api.expect(http.StatusCreated, "POST", fmt.Sprintf("/event/ticket/%d/buy", paidTicket.ID), buyer, gin.H{"Units": 1})
+		if order["amount"] != float64(2500) || order["paymentReference"] == "" {
+			t.Fatalf("paid purchase = %v", order)
+		}
+
+		// nobody attends until the payment goes through
+		attendees = api.expect(http.StatusOK, "GET", fmt.Sprintf("/event/%d/attendees", paid.ID), organiser, nil)
+		if count := attendees["attendeeCount"]; count != float64(0) {
+			t.Errorf("attendee count before payment = %v, want 0", count)
+		}
+
+		confirmPath := fmt.Sprintf("/event/order/%d/confirm", int(order["orderId"].(float64)))
+		confirmed := api.expect(http.StatusOK, "POST", confirmPath, buyer, nil)
+		if confirmed["status"] != "paid" {
+			t.Errorf("confirmed order status = %v, want paid", confirmed["status"])
+		}
+
+		attendees = api.expect(http.StatusOK, "GET", fmt.Sprintf("/event/%d/attendees", paid.ID), organiser, nil)
+		if count := attendees["attendeeCount"]; count != float64(1) {
+			t.Errorf("attendee count after payment = %v, want 1", count)
+		}
+		// the amount is money, not the unit count
+		if list, _ := attendees["attendees"].([]interface{}); len(list) == 1 {
+			attendee := list[0].(map[string]interface{})
+			if attendee["Amount"] != float64(2500) || attendee["Units"] != float64(1) {
+				t.Errorf("paid attendee = %v, want 1 unit for 2500", attendee)
+			}
+		}
+
+		mine := api.expect(http.StatusOK, "GET", "/event/tickets/mine", buyer, nil)
+		if held, _ := mine["tickets"].([]interface{}); len(held) != 2 {
+			t.Errorf("buyer holds %d purchases, want 2", len(held))
+		}
+	})
+}
+
+// route is a request to a protected route, built once its fixtures exist.
+type route struct {
+	method string
+	path string
+	body interface{}
+}
+
+// protectedRoutes lists every route behind authentication with a body it
+// accepts, so the only thing that can refuse the request is who sends it.
+func protectedRoutes(f fixtures) []route {
+	event := fmt.Sprintf("/event/%d", f.event.ID)
+	ticket := fmt.Sprintf("/event/ticket/%d", f.ticket.ID)
+	return []route{
+		{"POST", "/user/logout", nil},
+		{"POST", "/user/logout/all", nil},
+		{"POST", "/user/role-request", gin.H{"Role": users.RoleOrganiser}},
+		{"POST", "/event/create", freeEvent("Other")},
+		{"GET", "/event/mine", nil},
+		{"GET", "/event/tickets/mine", nil},
+		{"GET", "/event/tickets/code/" + f.serial + "/qr", nil},
+		{"POST", fmt.Sprintf("/event/attendee/%d/transfer", f.attendee.ID), gin.H{"Email": "payer@avana.test"}},
+		{"GET", "/event/dashboard", nil},
+		{"PATCH", fmt.Sprintf("/event/update/%d", f.event.ID), gin.H{"Name": "Renamed"}},
+		{"POST", event + "/publish", nil},
+		{"POST", event + "/postpone", gin.H{"Reason": "Rain"}},
+		{"POST", event + "/cancel", gin.H{"Reason": "Rain"}},
+		{"GET", event + "/cancellation", nil},
+		{"POST", event + "/cancellation/retry", nil},
+		{"POST", event + "/complete", nil},
+		{"POST", event + "/ticket/create", gin.H{"Name": "Extra", "SingleLimit": 1, "ExpiryTime": "2029-06-01 00:00:00"}},
+		{"PATCH", ticket, gin.H{"Name": "Renamed"}},
+		{"DELETE", ticket, nil},
+		{"DELETE", event, nil},
+		{"POST", ticket + "/buy", gin.H{"Units": 1}},
+		{"GET", event + "/attendees", nil},
+		{"POST", event + "/checkin", gin.H{"Code": f.attendee.Code}},
+		{"GET", event + "/checkins", nil},
+		{"GET", event + "/checkin/manifest", nil},
+		{"POST", event + "/checkin/sync", gin.H{"DeviceID": "door-1", "Scans": []gin.H{{"Code": f.attendee.Code, "ScannedAt": time.Now()}}}},
+		{"POST", fmt.Sprintf("/event/%d/reviews", f.past.ID), gin.H{"Rating": 4}},
+		{"PATCH", fmt.Sprintf("/event/%d/reviews", f.past.ID), gin.H{"Rating": 3}},
+		{"POST", fmt.Sprintf("/event/reviews/%d/flag", f.review), gin.H{"Reason": "spam"}},
+		{"PUT", fmt.Sprintf("/event/reviews/%d/reply", f.review), gin.H{"Reply": "Thanks"}},
+		{"GET", event + "/members", nil},
+		{"POST", event + "/members/invite", gin.H{"Email": "crew@avana.test", "Role": events.MemberViewer}},
+		{"POST", event + "/members/accept", nil},
+		{"DELETE", fmt.Sprintf("%s/members/%d", event, f.member), nil},
+		{"POST", fmt.Sprintf("/event/order/%d/confirm", f.order), nil},
+		{"POST", fmt.Sprintf("/event/ticket/%d/hold", f.paidTicket.ID), gin.H{"Units": 1}},
+		{"PATCH", fmt.Sprintf("/event/hold/%d/extend", f.hold), nil},
+		{"DELETE", fmt.Sprintf("/event/hold/%d", f.hold), nil},
+		{"POST", fmt.Sprintf("/event/hold/%d/checkout", f.hold), nil},
+		{"GET", "/admin/users", nil},
+		{"PATCH", "/admin/users/1/role", gin.H{"Role": users.RoleStaff}},
+		{"DELETE", "/admin/users/1", nil},
+		{"GET", "/admin/role-requests", nil},
+		{"POST", "/admin/role-requests/1/decide", gin.H{"Action": "approve"}},
+		{"GET", "/admin/reviews", nil},
+		{"POST", fmt.Sprintf("/admin/reviews/%d/moderate", f.review), gin.H{"Action": "hide"}},
+	}
+}
+
+// fixtures is an organiser's events with a buyer's purchases, holds and
+// reviews on them, for other users to be refused access to.
+type fixtures struct {
+	event events.Event
+	ticket events.Ticket
+	past events.Event
+	paidTicket events.Ticket
+	attendee events.Attendee
+	serial string
+	order int
+	hold int
+	member int
+	review int
+}
+
+func newFixtures(api *testAPI) fixtures {
+	t := api.t
+	t.Helper()
+
+	owner, organiser := api.user("organiser@avana.test", users.RoleOrganiser)
+	_, buyer := api.user("buyer@avana.test", users.RoleAttendee)
+	_, payer := api.user("payer@avana.test", users.RoleAttendee)
+
+	var f fixtures
+	f.event, f.ticket = api.event(owner, freeEvent("Meetup"))
+	api.expect(http.StatusOK, "POST", fmt.Sprintf("/event/ticket/%d/buy", f.ticket.ID), buyer, gin.H{"Units": 2})
+	attendees, err := api.deps.Events.Attendees().ListByTickets([]uint{f.ticket.ID})
+	if err != nil || len(attendees) != 1 {
+		t.Fatalf("listing attendees: %v", err)
+	}
+	f.attendee = attendees[0]
+	codes, err := api.deps.Events.TicketCodes().ListByAttendees([]uint{f.attendee.ID})
+	if err != nil || len(codes) == 0 {
+		t.Fatalf("listing ticket codes: %v", err)
+	}
+	f.serial = codes[0].Serial
+
+	invite := api.expect(http.StatusCreated, "POST", fmt.Sprintf("/event/%d/members/invite", f.event.ID), organiser,
+		gin.H{"Email": "crew@avana.test", "Role": events.MemberViewer})
+	f.member = int(invite["member"].(map[string]interface{})["ID"].(float64))
+
+	_, f.paidTicket = api.event(owner, paidEvent("Concert", 2500))
+	order := api.expect(http.StatusCreated, "POST", fmt.Sprintf("/event/ticket/%d/buy", f.paidTicket.ID), payer, gin.H{"Units": 1})
+	f.order = int(order["orderId"].(float64))
+	hold := api.expect(http.StatusCreated, "POST", fmt.Sprintf("/event/ticket/%d/hold", f.paidTicket.ID), buyer, gin.H{"Units": 1})
+	f.hold = int(hold["reservation"].(map[string]interface{})["ID"].(float64))
+
+	// reviews open once the event has taken place
+	var pastTicket events.Ticket
+	f.past, pastTicket = api.event(owner, freeEvent("Workshop"))
+	api.expect(http.StatusOK, "POST", fmt.Sprintf("/event/ticket/%d/buy", pastTicket.ID), buyer, gin.H{"Units": 1})
+	f.past.EventDate = time.Now().Add(-time.Hour)
+	if err = api.deps.Events.Events().Save(&f.past); err != nil {
+		t.Fatalf("moving the workshop into the past: %v", err)
+	}
+	review := api.expect(http.StatusCreated, "POST", fmt.Sprintf("/event/%d/reviews", f.past.ID), buyer, gin.H{"Rating": 5, "Review": "Great"})
+	f.review = int(review["review"].(map[string]interface{})["ID"].(float64))
+
+	return f
+}
+
+func TestProtectedRoutesRequireAuthentication(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, api *testAPI) {
+		f := newFixtures(api)
+
+		for _, r := range protectedRoutes(f) {
+			for _, token := range []string{"", "not-a-token"} {
+				code, out := api.do(r.method, r.path, token, r.body)
+				if code != http.StatusUnauthorized {
+					t.Errorf("%s %s with token %q = %d %v, want 401", r.method, r.path, token, code, out)
+				}
+			}
+		}
+	})
+}
+
+func TestProtectedRoutesRefuseOtherUsers(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, api *testAPI) {
+		f := newFixtures(api)
+
+		// a signed in user with no stake in the fixtures, and one whose role
+		// grants nothing at all
+		_, outsider := api.user("outsider@avana.test", users.RoleAttendee)
+		_, nobody := api.user("nobody@avana.test", "suspended")
+
+		// these act only on the caller's own account, there is nobody else to refuse
+		own := map[string]bool{
+			"POST /user/logout": true,
+			"POST /user/logout/all": true,
+			"POST /user/role-request": true,
+			"GET /event/mine": true,
+			"GET /event/tickets/mine": true,
+			// an invite is found by the caller's email, others have none to accept
+			fmt.Sprintf("POST /event/%d/members/accept", f.event.ID): true,
+			// any user may flag a review
+			fmt.Sprintf("POST /event/reviews/%d/flag", f.review): true,
+		}
+		// someone else's purchase is hidden rather than refused
+		hidden := map[string]bool{
+			fmt.Sprintf("POST /event/attendee/%d/transfer", f.attendee.ID): true,
+		}
+		// the outsider may buy and hold, only a role without that permission is refused
+		byRole := map[string]bool{
+			fmt.Sprintf("POST /event/ticket/%d/buy", f.ticket.ID): true,
+			fmt.Sprintf("POST /event/ticket/%d/hold", f.paidTicket.ID): true,
+		}
+
+		for _, r := range protectedRoutes(f) {
+			key := r.method + " " + r.path
+			if own[key] {
+				continue
+			}
+			token := outsider
+			if byRole[key] {
+				token = nobody
+			}
+			want := http.StatusForbidden
+			if hidden[key] {
+				want = http.StatusNotFound
+			}
+			code, out := api.do(r.method, r.path, token, r.body)
+			if code != want {
+				t.Errorf("%s by another user = %d %v, want %d", key, code, out, want)
+			}
+		}
+	})
+}
+
+// eventNames lists the names of the events in a listing, in order.
+func eventNames(out map[string]interface{}) string {
+	list, _ := out["event"].([]interface{})
+	names := make([]string, 0, len(list))
+	for _, event := range list {
+		names = append(names, event.(map[string]interface{})["Name"].(string))
+	}
+	return strings.Join(names, ", ")
+}
+
+func TestEventListing(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, api *testAPI) {
+		owner, organiser := api.user("organiser@avana.test", users.RoleOrganiser)
+		_, buyer := api.user("buyer@avana.test", users.RoleAttendee)
+
+		seed := func(schema events.CreateEventSchema, location, organiserName, date string) (events.Event, events.Ticket) {
+			schema.Location = location
+			schema.Organiser = organiserName
+			schema.EventDate = date
+			return api.event(owner, schema)
+		}
+		_, night := seed(freeEvent("Jazz Night"), "Lagos", "Avana", "2030-01-02 15:04:05")
+		seed(paidEvent("Jazz Brunch", 2000), "Lagos Island", "Avana", "2030-02-01 10:00:00")
+		seed(paidEvent("Rock Concert", 5000), "Abuja", "Sound Co", "2030-03-01 20:00:00")
+		// an event whose only ticket is gone has no price and nothing on sale
+		_, slam := seed(freeEvent("Poetry Slam"), "Ibadan", "Avana", "2030-04-01 18:00:00")
+		if err := api.deps.Events.Tickets().Delete(slam.ID); err != nil {
+			t.Fatalf("deleting the slam's ticket: %v", err)
+		}
+		// drafts are never listed
+		api.expect(http.StatusOK, "POST", "/event/create", organiser, freeEvent("Jazz Draft"))
+
+		api.expect(http.StatusOK, "POST", fmt.Sprintf("/event/ticket/%d/buy", night.ID), buyer, gin.H{"Units": 2})
+
+		for _, tc := range []struct {
+			query url.Values
+			want string
+		}{
+			{query: url.Values{"sort": {"date"}}, want: "Jazz Night, Jazz Brunch, Rock Concert, Poetry Slam"},
+			{query: url.Values{"sort": {"-date"}}, want: "Poetry Slam, Rock Concert, Jazz Brunch, Jazz Night"},
+			{query: url.Values{"q": {"jazz"}, "sort": {"date"}}, want: "Jazz Night, Jazz Brunch"},
+			{query: url.Values{"q": {"rock concert"}}, want: "Rock Concert"},
+			{query: url.Values{"location": {"lagos"}, "sort": {"date"}}, want: "Jazz Night, Jazz Brunch"},
+			{query: url.Values{"organiser": {"SOUND"}}, want: "Rock Concert"},
+			{query: url.Values{"paid": {"true"}, "sort": {"date"}}, want: "Jazz Brunch, Rock Concert"},
+			{query: url.Values{"from": {"2030-02-01 00:00:00"}, "to": {"2030-03-31 00:00:00"}, "sort": {"date"}}, want: "Jazz Brunch, Rock Concert"},
+			{query: url.Values{"available": {"false"}}, want: "Poetry Slam"},
+			{query: url.Values{"status": {"cancelled"}}, want: ""},
+			// events without a price sort last either way
+			{query: url.Values{"sort": {"price"}}, want: "Jazz Night, Jazz Brunch, Rock Concert, Poetry Slam"},
+			{query: url.Values{"sort": {"-price"}}, want: "Rock Concert, Jazz Brunch, Jazz Night, Poetry Slam"},
+			{query: url.Values{"sort": {"popularity"}, "pageSize": {"1"}}, want: "Jazz Night"},
+		} {
+			out := api.expect(http.StatusOK, "GET", "/event/all?"+tc.query.Encode(), "", nil)
+			if got := eventNames(out); got != tc.want {
+				t.Errorf("listing %s = %q, want %q", tc.query.Encode(), got, tc.want)
+			}
+		}
+
+		first := api.expect(http.StatusOK, "GET", "/event/all?sort=date&pageSize=3", "", nil)
+		meta := first["meta"].(map[string]interface{})
+		if eventNames(first) != "Jazz Night, Jazz Brunch, Rock Concert" || meta["total"] != float64(4) || meta["nextPage"] != float64(2) {
+			t.Errorf("first page = %q %v", eventNames(first), meta)
+		}
+		last := api.expect(http.StatusOK, "GET", "/event/all?sort=date&pageSize=3&page=2", "", nil)
+		meta = last["meta"].(map[string]interface{})
+		if eventNames(last) != "Poetry Slam" || meta["nextPage"] != nil {
+			t.Errorf("last page = %q %v", eventNames(last), meta)
+		}
+
+		api.expect(http.StatusBadRequest, "GET", "/event/all?sort=cheapest", "", nil)
+		api.expect(http.StatusBadRequest, "GET", "/event/all?pageSize=500", "", nil)
+	})
+}
+
+func TestWellKnownKeys(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, api *testAPI) {
+		// the memory key set signs with an HMAC secret, which is never published
+		jwks := api.expect(http.StatusOK, "GET", "/.well-known/jwks.json", "", nil)
+		if keys, ok := jwks["keys"].([]interface{}); !ok || len(keys) != 0 {
+			t.Errorf("jwks = %v, want an empty key list", jwks)
+		}
+
+		ticketKeys := api.expect(http.StatusOK, "GET", "/.well-known/ticket-keys.json", "", nil)
+		keys, _ := ticketKeys["keys"].([]interface{})
+		if len(keys) != 1 {
+			t.Fatalf("ticket keys = %v, want the signing key", ticketKeys)
+		}
+		want := api.deps.TicketSigner.JWKS().Keys[0]
+		key := keys[0].(map[string]interface{})
+		if key["kty"] != "OKP" || key["crv"] != "Ed25519" || key["kid"] != want.Kid || key["x"] != want.X {
+			t.Errorf("ticket key = %v, want %+v", key, want)
+		}
+	})
+}
+
+func TestTokenRefresh(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, api *testAPI) {
+		if _, err := SeedUser(api.deps.Users, "ada@avana.test", testPassword, users.RoleAttendee); err != nil {
+			t.Fatalf("seeding: %v", err)
+		}
+		login := api.expect(http.StatusOK, "POST", "/user/login", "", gin.H{"Email": "ada@avana.test", "Password": testPassword})
+		refreshToken, _ := login["refreshToken"].(string)
+		if refreshToken == "" {
+			t.Fatalf("login returned no refresh token: %v", login)
+		}
+
+		refreshed := api.expect(http.StatusOK, "POST", "/user/token/refresh", "", gin.H{"RefreshToken": refreshToken})
+		token, _ := refreshed["token"].(string)
+		rotated, _ := refreshed["refreshToken"].(string)
+		if token == "" || rotated == "" || rotated == refreshToken {
+			t.Fatalf("refresh = %v, want a new pair", refreshed)
+		}
+		api.expect(http.StatusOK, "GET", "/event/tickets/mine", token, nil)
+		api.expect(http.StatusUnauthorized, "POST", "/user/token/refresh", "", gin.H{"RefreshToken": "not-a-token"})
+
+		// a used token presented again means it leaked, the whole session ends
+		api.expect(http.StatusUnauthorized, "POST", "/user/token/refresh", "", gin.H{"RefreshToken": refreshToken})
+		api.expect(http.StatusUnauthorized, "POST", "/user/token/refresh", "", gin.H{"RefreshToken": rotated})
+		api.expect(http.StatusUnauthorized, "GET", "/event/tickets/mine", token, nil)
+	})
+}
+
+// webhook posts the payload to the payment webhook signed as the fake
+// provider would sign it, or with the signature given.
+func (a *testAPI) webhook(payload string, signature string) (int, map[string]interface{}) {
+	a.t.Helper()
+
+	provider := a.deps.Payments.(*payments.FakeProvider)
+	if signature == "" {
+		signature = provider.SignWebhook([]byte(payload))
+	}
+
+	req := httptest.NewRequest("POST", "/payment/webhook", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(provider.SignatureHeader(), signature)
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, req)
+
+	var out map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &out)
+	return w.Code, out
+}
+
+func TestPaymentWebhook(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, api *testAPI) {
+		owner, organiser := api.user("organiser@avana.test", users.RoleOrganiser)
+		_, buyer := api.user("buyer@avana.test", users.RoleAttendee)
+		event, ticket := api.event(owner, paidEvent("Concert", 2500))
+
+		order := api.expect(http.StatusCreated, "POST", fmt.Sprintf("/event/ticket/%d/buy", ticket.ID), buyer, gin.H{"Units": 1})
+		intentId := order["paymentReference"].(string)
+		succeeded := fmt.Sprintf(`{"type":%q,"intentId":%q}`, payments.WebhookPaymentSucceeded, intentId)
+
+		if code, out := api.webhook(succeeded, "00"); code != http.StatusUnauthorized {
+			t.Errorf("badly signed webhook = %d %v, want 401", code, out)
+		}
+		// events we do not act on are acknowledged
+		if code, out := api.webhook(`{"type":"charge.refunded","intentId":"ch_1"}`, ""); code != http.StatusOK {
+			t.Errorf("unhandled webhook = %d %v, want 200", code, out)
+		}
+
+		// the webhook settles the order without the buyer confirming it, and
+		// a redelivery changes nothing
+		for i := 0; i < 2; i++ {
+			if code, out := api.webhook(succeeded, ""); code != http.StatusOK {
+				t.Fatalf("delivery %d of the payment webhook = %d %v, want 200", i+1, code, out)
+			}
+		}
+
+		attendees := api.expect(http.StatusOK, "GET", fmt.Sprintf("/event/%d/attendees", event.ID), organiser, nil)
+		if count := attendees["attendeeCount"]; count != float64(1) {
+			t.Errorf("attendee count after the webhook = %v, want 1", count)
+		}
+		stored, err := api.deps.Events.Tickets().FindByID(ticket.ID)
+		if err != nil || stored.Sold != 1 {
+			t.Errorf("ticket after the webhook = %+v %v, want 1 sold", stored, err)
+		}
+		mine := api.expect(http.StatusOK, "GET", "/event/tickets/mine", buyer, nil)
+		if held, _ := mine["tickets"].([]interface{}); len(held) != 1 {
+			t.Errorf("buyer holds %d purchases, want 1", len(held))
+		}
+	})
+}
+
+// pastEvent seeds an event the buyer has a ticket for and moves it into the
+// past, so it can be reviewed.
+func (a *testAPI) pastEvent(owner users.User, buyer string) events.Event {
+	a.t.Helper()
+
+	event, ticket := a.event(owner, freeEvent("Workshop"))
+	a.expect(http.StatusOK, "POST", fmt.Sprintf("/event/ticket/%d/buy", ticket.ID), buyer, gin.H{"Units": 1})
+	event.EventDate = time.Now().Add(-time.Hour)
+	if err := a.deps.Events.Events().Save(&event); err != nil {
+		a.t.Fatalf("moving %s into the past: %v", event.Name, err)
+	}
+	return event
+}
+
+func TestReviewFlow(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, api *testAPI) {
+		owner, _ := api.user("organiser@avana.test", users.RoleOrganiser)
+		_, buyer := api.user("buyer@avana.test", users.RoleAttendee)
+		_, other := api.user("other@avana.test", users.RoleAttendee)
+		_, admin := api.user("admin@avana.test", users.RoleAdmin)
+		event := api.pastEvent(owner, buyer)
+		reviewsPath := fmt.Sprintf("/event/%d/reviews", event.ID)
+
+		created := api.expect(http.StatusCreated, "POST", reviewsPath, buyer, gin.H{"Rating": 5, "Review": "Great"})
+		reviewId := int(created["review"].(map[string]interface{})["ID"].(float64))
+		api.expect(http.StatusConflict, "POST", reviewsPath, buyer, gin.H{"Rating": 4})
+
+		// the author may change their mind while the edit window is open
+		edited := api.expect(http.StatusOK, "PATCH", reviewsPath, buyer, gin.H{"Rating": 3, "Review": "Good, not great"})
+		if review := edited["review"].(map[string]interface{}); review["Rating"] != float64(3) || review["Review"] != "Good, not great" {
+			t.Errorf("edited review = %v", review)
+		}
+
+		listed := api.expect(http.StatusOK, "GET", reviewsPath, "", nil)
+		reviews, _ := listed["reviews"].([]interface{})
+		if len(reviews) != 1 || reviews[0].(map[string]interface{})["Rating"] != float64(3) {
+			t.Fatalf("reviews = %v, want the edited review", listed)
+		}
+		stats := api.expect(http.StatusOK, "GET", reviewsPath+"/stats", "", nil)["stats"].(map[string]interface{})
+		distribution, _ := stats["Distribution"].(map[string]interface{})
+		if stats["Count"] != float64(1) || stats["Average"] != float64(3) || distribution["3"] != float64(1) {
+			t.Errorf("stats = %v, want one rating of 3", stats)
+		}
+
+		flagPath := fmt.Sprintf("/event/reviews/%d/flag", reviewId)
+		api.expect(http.StatusForbidden, "POST", flagPath, buyer, gin.H{"Reason": "spam"})
+		api.expect(http.StatusCreated, "POST", flagPath, other, gin.H{"Reason": "spam", "Note": "Advertising"})
+		api.expect(http.StatusConflict, "POST", flagPath, other, gin.H{"Reason": "spam"})
+
+		queue := api.expect(http.StatusOK, "GET", "/admin/reviews", admin, nil)
+		items, _ := queue["reviews"].([]interface{})
+		if len(items) != 1 {
+			t.Fatalf("moderation queue = %v, want the flagged review", queue)
+		}
+		flags, _ := items[0].(map[string]interface{})["Flags"].([]interface{})
+		if len(flags) != 1 || flags[0].(map[string]interface{})["Note"] != "Advertising" {
+			t.Errorf("queued flags = %v", flags)
+		}
+
+		// hiding resolves the flags and takes the review out of the listing
+		moderated := api.expect(http.StatusOK, "POST", fmt.Sprintf("/admin/reviews/%d/moderate", reviewId), admin, gin.H{"Action": "hide", "Note": "Spam"})
+		if review := moderated["review"].(map[string]interface{}); review["Status"] != events.ReviewHidden {
+			t.Errorf("moderated review = %v, want %s", review, events.ReviewHidden)
+		}
+		if queue := api.expect(http.StatusOK, "GET", "/admin/reviews", admin, nil); queue["reviewCount"] != float64(0) {
+			t.Errorf("moderation queue after hiding = %v, want empty", queue)
+		}
+		listed = api.expect(http.StatusOK, "GET", reviewsPath, "", nil)
+		if reviews, _ := listed["reviews"].([]interface{}); len(reviews) != 0 {
+			t.Errorf("reviews after hiding = %v, want none", listed)
+		}
+		stats = api.expect(http.StatusOK, "GET", reviewsPath+"/stats", "", nil)["stats"].(map[string]interface{})
+		if stats["Count"] != float64(0) || stats["Average"] != nil {
+			t.Errorf("stats after hiding = %v, want no ratings", stats)
+		}
+		// and a hidden review can no longer be edited
+		api.expect(http.StatusConflict, "PATCH", reviewsPath, buyer, gin.H{"Rating": 5})
+
+		// approving brings it back
+		api.expect(http.StatusOK, "POST", fmt.Sprintf("/admin/reviews/%d/moderate", reviewId), admin, gin.H{"Action": "approve"})
+		listed = api.expect(http.StatusOK, "GET", reviewsPath, "", nil)
+		if reviews, _ := listed["reviews"].([]interface{}); len(reviews) != 1 {
+			t.Errorf("reviews after approving = %v, want the review", listed)
+		}
+	})
+}
+
+func TestTeamAndDoor(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, api *testAPI) {
+		owner, organiser := api.user("organiser@avana.test", users.RoleOrganiser)
+		_, buyer := api.user("buyer@avana.test", users.RoleAttendee)
+		event, ticket := api.event(owner, freeEvent("Meetup"))
+		eventPath := fmt.Sprintf("/event/%d", event.ID)
+		api.expect(http.StatusOK, "POST", fmt.Sprintf("/event/ticket/%d/buy", ticket.ID), buyer, gin.H{"Units": 2})
+
+		api.expect(http.StatusCreated, "POST", eventPath+"/members/invite", organiser, gin.H{"Email": "door@avana.test", "Role": events.MemberCheckIn})
+		crewUser, crew := api.user("door@avana.test", users.RoleAttendee)
+
+		// an invite grants nothing until it is accepted
+		api.expect(http.StatusForbidden, "GET", eventPath+"/checkin/manifest", crew, nil)
+		accepted := api.expect(http.StatusOK, "POST", eventPath+"/members/accept", crew, nil)
+		member := accepted["member"].(map[string]interface{})
+		if member["Status"] != events.MemberActive || member["UserID"] != float64(crewUser.ID) {
+			t.Errorf("accepted member = %v", member)
+		}
+		api.expect(http.StatusNotFound, "POST", eventPath+"/members/accept", crew, nil)
+
+		team := api.expect(http.StatusOK, "GET", eventPath+"/members", organiser, nil)
+		if members, _ := team["members"].([]interface{}); len(members) != 2 {
+			t.Errorf("team = %v, want the owner and the door", team)
+		}
+
+		manifest := api.expect(http.StatusOK, "GET", eventPath+"/checkin/manifest", crew, nil)["manifest"].(map[string]interface{})
+		if manifest["Unused"] != float64(2) || manifest["Used"] != float64(0) || manifest["Manifest"] == "" {
+			t.Errorf("manifest = %v, want 2 unused codes", manifest)
+		}
+
+		purchases := api.expect(http.StatusOK, "GET", "/event/tickets/mine", buyer, nil)["tickets"].([]interface{})
+		purchase := purchases[0].(map[string]interface{})
+		codes := purchase["Codes"].([]interface{})
+		unit := codes[0].(map[string]interface{})["Token"].(string)
+
+		// a scanner that was offline reports its scans later, a resent batch
+		// settles the same way
+		scannedAt := time.Now().Add(-time.Minute)
+		batch := gin.H{"DeviceID": "door-1", "Scans": []gin.H{
+			{"Code": unit, "ScannedAt": scannedAt},
+			{"Code": unit, "ScannedAt": scannedAt.Add(time.Second)},
+			{"Code": "not-a-code", "ScannedAt": scannedAt},
+		}}
+		for i := 0; i < 2; i++ {
+			sync := api.expect(http.StatusOK, "POST", eventPath+"/checkin/sync", crew, batch)["sync"].(map[string]interface{})
+			if sync["Admitted"] != float64(1) || sync["Duplicates"] != float64(1) || sync["Refused"] != float64(1) ||
+				sync["CheckedIn"] != float64(1) || sync["Valid"] != float64(2) {
+				t.Errorf("sync %d = %v, want one admitted, one duplicate and one refused", i+1, sync)
+			}
+		}
+
+		// the door code lets in whatever is left of the purchase
+		checkIn := api.expect(http.StatusOK, "POST", eventPath+"/checkin", crew, gin.H{"Code": purchase["Code"]})["checkIn"].(map[string]interface{})
+		if checkIn["CheckedIn"] != float64(2) || checkIn["Remaining"] != float64(0) {
+			t.Errorf("check-in = %v, want both units in", checkIn)
+		}
+		api.expect(http.StatusConflict, "POST", eventPath+"/checkin", crew, gin.H{"Code": purchase["Code"]})
+		api.expect(http.StatusNotFound, "POST", eventPath+"/checkin", crew, gin.H{"Code": "ZZZZZZZZ"})
+
+		checkIns := api.expect(http.StatusOK, "GET", eventPath+"/checkins", organiser, nil)
+		if checkIns["checkInCount"] != float64(2) {
+			t.Errorf("check-ins = %v, want the synced scan and the door code", checkIns)
+		}
+		manifest = api.expect(http.StatusOK, "GET", eventPath+"/checkin/manifest", crew, nil)["manifest"].(map[string]interface{})
+		if manifest["Unused"] != float64(0) || manifest["Used"] != float64(2) {
+			t.Errorf("manifest after the door = %v, want 2 used codes", manifest)
+		}
+	})
+}
+
+func TestHoldCheckout(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, api *testAPI) {
+		owner, organiser := api.user("organiser@avana.test", users.RoleOrganiser)
+		_, buyer := api.user("buyer@avana.test", users.RoleAttendee)
+		_, other := api.user("other@avana.test", users.RoleAttendee)
+		event, ticket := api.event(owner, paidEvent("Concert", 2500))
+		holdPath := fmt.Sprintf("/event/ticket/%d/hold", ticket.ID)
+
+		sold := func() uint {
+			t.Helper()
+			stored, err := api.deps.Events.Tickets().FindByID(ticket.ID)
+			if err != nil {
+				t.Fatalf("finding the ticket: %v", err)
+			}
+			return stored.Sold
+		}
+
+		held := api.expect(http.StatusCreated, "POST", holdPath, buyer, gin.H{"Units": 2})["reservation"].(map[string]interface{})
+		hold := fmt.Sprintf("/event/hold/%d", int(held["ID"].(float64)))
+		if held["Status"] != events.ReservationHeld || sold() != 2 {
+			t.Fatalf("hold = %v with %d sold, want 2 units held", held, sold())
+		}
+		api.expect(http.StatusConflict, "POST", holdPath, buyer, gin.H{"Units": 1})
+
+		extended := api.expect(http.StatusOK, "PATCH", hold+"/extend", buyer, nil)["reservation"].(map[string]interface{})
+		if extended["ExpiresAt"] == held["ExpiresAt"] {
+			t.Errorf("extended hold expires at %v, want later than %v", extended["ExpiresAt"], held["ExpiresAt"])
+		}
+
+		// checking out turns the held units into an order without taking them again
+		order := api.expect(http.StatusCreated, "POST", hold+"/checkout", buyer, nil)
+		if order["amount"] != float64(5000) || order["paymentReference"] == "" || sold() != 2 {
+			t.Fatalf("checkout = %v with %d sold, want 5000 for the 2 held units", order, sold())
+		}
+		if code, out := api.do("POST", hold+"/checkout", buyer, nil); code < 400 {
+			t.Errorf("second checkout = %d %v, want it refused", code, out)
+		}
+		api.expect(http.StatusOK, "POST", fmt.Sprintf("/event/order/%d/confirm", int(order["orderId"].(float64))), buyer, nil)
+		attendees := api.expect(http.StatusOK, "GET", fmt.Sprintf("/event/%d/attendees", event.ID), organiser, nil)
+		if attendees["attendeeCount"] != float64(1) {
+			t.Errorf("attendees after checkout = %v, want the buyer", attendees)
+		}
+
+		// a cancelled hold gives its units back
+		cancelled := api.expect(http.StatusCreated, "POST", holdPath, other, gin.H{"Units": 1})["reservation"].(map[string]interface{})
+		api.expect(http.StatusOK, "DELETE", fmt.Sprintf("/event/hold/%d", int(cancelled["ID"].(float64))), other, nil)
+		if sold() != 2 {
+			t.Errorf("sold after cancelling a hold = %d, want 2", sold())
+		}
+
+		// and so does one left to expire, once the sweeper comes round
+		lapsed := api.expect(http.StatusCreated, "POST", holdPath, other, gin.H{"Units": 1})["reservation"].(map[string]interface{})
+		reservation, err := api.deps.Events.Reservations().FindByID(uint(lapsed["ID"].(float64)))
+		if err != nil {
+			t.Fatalf("finding the hold: %v", err)
+		}
+		reservation.ExpiresAt = time.Now().Add(-time.Minute)
+		if err = api.deps.Events.Reservations().Save(&reservation); err != nil {
+			t.Fatalf("expiring the hold: %v", err)
+		}
+		released, err := events.SweepExpiredReservations(api.deps.Events)
+		if err != nil || released != 1 {
+			t.Fatalf("sweeping = %d %v, want the lapsed hold released", released, err)
+		}
+		if sold() != 2 {
+			t.Errorf("sold after the sweep = %d, want 2", sold())
+		}
+		if code, out := api.do("POST", fmt.Sprintf("/event/hold/%d/checkout", reservation.ID), other, nil); code < 400 {
+			t.Errorf("checking out an expired hold = %d %v, want it refused", code, out)
+		}
+	})
+}
+
+func TestTransfer(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, api *testAPI) {
+		owner, organiser := api.user("organiser@avana.test", users.RoleOrganiser)
+		_, buyer := api.user("buyer@avana.test", users.RoleAttendee)
+		_, friend := api.user("friend@avana.test", users.RoleAttendee)
+		event, ticket := api.event(owner, freeEvent("Meetup"))
+		eventPath := fmt.Sprintf("/event/%d", event.ID)
+		api.expect(http.StatusOK, "POST", fmt.Sprintf("/event/ticket/%d/buy", ticket.ID), buyer, gin.H{"Units": 2})
+
+		purchase := api.expect(http.StatusOK, "GET", "/event/tickets/mine", buyer, nil)["tickets"].([]interface{})[0].(map[string]interface{})
+		transferPath := fmt.Sprintf("/event/attendee/%d/transfer", int(purchase["AttendeeID"].(float64)))
+
+		api.expect(http.StatusBadRequest, "POST", transferPath, buyer, gin.H{"Email": "buyer@avana.test"})
+		api.expect(http.StatusOK, "POST", transferPath, buyer, gin.H{"Email": "friend@avana.test"})
+
+		if mine := api.expect(http.StatusOK, "GET", "/event/tickets/mine", buyer, nil); len(mine["tickets"].([]interface{})) != 0 {
+			t.Errorf("buyer still holds %v", mine["tickets"])
+		}
+		received := api.expect(http.StatusOK, "GET", "/event/tickets/mine", friend, nil)["tickets"].([]interface{})
+		if len(received) != 1 {
+			t.Fatalf("friend holds %d purchases, want the transferred one", len(received))
+		}
+		moved := received[0].(map[string]interface{})
+		if moved["Units"] != float64(2) || moved["Code"] == purchase["Code"] || len(moved["Codes"].([]interface{})) != 2 {
+			t.Errorf("transferred purchase = %v, want 2 units under new codes", moved)
+		}
+
+		// the codes the buyer kept no longer open the door, the new ones do
+		api.expect(http.StatusNotFound, "POST", eventPath+"/checkin", organiser, gin.H{"Code": purchase["Code"]})
+		oldUnit := purchase["Codes"].([]interface{})[0].(map[string]interface{})["Token"]
+		api.expect(http.StatusGone, "POST", eventPath+"/checkin", organiser, gin.H{"Code": oldUnit})
+		api.expect(http.StatusOK, "POST", eventPath+"/checkin", organiser, gin.H{"Code": moved["Code"]})
+		manifest := api.expect(http.StatusOK, "GET", eventPath+"/checkin/manifest", organiser, nil)["manifest"].(map[string]interface{})
+		if manifest["Revoked"] != float64(2) || manifest["Used"] != float64(2) {
+			t.Errorf("manifest after the transfer = %v, want the old codes revoked", manifest)
+		}
+
+		// it is the friend's to pass on now, and used tickets stay put
+		api.expect(http.StatusNotFound, "POST", transferPath, buyer, gin.H{"Email": "organiser@avana.test"})
+		api.expect(http.StatusConflict, "POST", transferPath, friend, gin.H{"Email": "buyer@avana.test"})
+	})
+}
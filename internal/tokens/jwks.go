@@ -0,0 +1,64 @@
+package tokens
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JWK struct {
+	Kty string		`json:"kty"`
+	Kid string		`json:"kid"`
+	Use string		`json:"use"`
+	Alg string		`json:"alg"`
+	N string		`json:"n,omitempty"`
+	E string		`json:"e,omitempty"`
+	Crv string		`json:"crv,omitempty"`
+	X string		`json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK		`json:"keys"`
+}
+
+// JWKS publishes the public half of every verification key. The HMAC
+// secret is never published.
+func (k *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+
+	for _, kid := range k.KeyIDs() {
+		key := k.verifiers[kid]
+		jwk := JWK{
+			Kid: kid,
+			Use: "sig",
+			Alg: key.method.Alg(),
+		}
+
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+// JWKSHandler serves the key set at /.well-known/jwks.json.
+func (k *KeySet) JWKSHandler(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, k.JWKS())
+}
@@ -0,0 +1,207 @@
+package tokens
+
+import (
+	"avana/internal/config"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	ErrUnknownKey = errors.New("unknown signing key")
+	ErrUnsupportedKey = errors.New("unsupported key type")
+	ErrHMACDisabled = errors.New("hmac tokens are no longer accepted")
+)
+
+// KeySet signs access tokens with the active key and verifies tokens
+// against every key it knows, so keys can be rotated without signing
+// everyone out. Tokens signed with the shared HMAC secret stay valid
+// while AcceptHMAC is on.
+type KeySet struct {
+	activeKid string
+	signer crypto.Signer
+	method jwt.SigningMethod
+	verifiers map[string]verificationKey
+
+	hmacSecret []byte
+	acceptHMAC bool
+}
+
+type verificationKey struct {
+	method jwt.SigningMethod
+	public crypto.PublicKey
+}
+
+// NewHMACKeySet builds a key set that only signs and verifies with the shared secret.
+func NewHMACKeySet(secret string) *KeySet {
+	return &KeySet{
+		verifiers: map[string]verificationKey{},
+		hmacSecret: []byte(secret),
+		acceptHMAC: true,
+	}
+}
+
+// LoadKeySet reads every <kid>.pem file in dir. Private keys can sign and
+// verify, public keys only verify, which is how retired keys are kept
+// around until their tokens expire. The key named activeKid signs new tokens.
+func LoadKeySet(dir, activeKid, hmacSecret string, acceptHMAC bool) (*KeySet, error) {
+	keys := &KeySet{
+		verifiers: map[string]verificationKey{},
+		hmacSecret: []byte(hmacSecret),
+		acceptHMAC: acceptHMAC,
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		signer, public, err := parsePEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", kid, err)
+		}
+
+		method, err := methodFor(public)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", kid, err)
+		}
+
+		keys.verifiers[kid] = verificationKey{method: method, public: public}
+
+		if kid == activeKid {
+			if signer == nil {
+				return nil, fmt.Errorf("active key %s has no private part", kid)
+			}
+			keys.activeKid = kid
+			keys.signer = signer
+			keys.method = method
+		}
+	}
+
+	if keys.activeKid == "" {
+		return nil, fmt.Errorf("active key %q not found in %s", activeKid, dir)
+	}
+
+	return keys, nil
+}
+
+// Sign signs the claims with the active key, or the HMAC secret when no
+// asymmetric key is configured.
+func (k *KeySet) Sign(claims jwt.Claims) (string, error) {
+	if k.signer == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(k.hmacSecret)
+	}
+
+	token := jwt.NewWithClaims(k.method, claims)
+	token.Header["kid"] = k.activeKid
+	return token.SignedString(k.signer)
+}
+
+// Parse verifies the token against the key named by its kid header.
+func (k *KeySet) Parse(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, k.keyFunc)
+}
+
+func (k *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	// legacy tokens signed with the shared secret have no kid
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if !k.acceptHMAC {
+			return nil, ErrHMACDisabled
+		}
+		return k.hmacSecret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := k.verifiers[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.public, nil
+}
+
+// KeyIDs lists the verification keys in a stable order.
+func (k *KeySet) KeyIDs() []string {
+	ids := make([]string, 0, len(k.verifiers))
+	for kid := range k.verifiers {
+		ids = append(ids, kid)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func parsePEM(data []byte) (crypto.Signer, crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, nil, errors.New("no pem block found")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, nil, ErrUnsupportedKey
+		}
+		return signer, signer.Public(), nil
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		return key, key.Public(), nil
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, key, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported pem block %q", block.Type)
+	}
+}
+
+func methodFor(public crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch public.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, ErrUnsupportedKey
+	}
+}
+
+// NewKeySet builds the key set described by the auth config, falling back
+// to the shared HMAC secret when no key directory is configured.
+func NewKeySet(cfg config.AuthConfig) (*KeySet, error) {
+	if cfg.KeysDir == "" {
+		return NewHMACKeySet(cfg.JWTSecret), nil
+	}
+	return LoadKeySet(cfg.KeysDir, cfg.ActiveKeyID, cfg.JWTSecret, cfg.AcceptHMACTokens)
+}
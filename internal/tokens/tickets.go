@@ -0,0 +1,93 @@
+package tokens
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var ErrInvalidTicketSignature = errors.New("invalid ticket signature")
+
+// TicketSigner signs the codes printed on tickets with Ed25519, so a door
+// scanner holding only the public key can check them without a connection.
+type TicketSigner struct {
+	kid string
+	private ed25519.PrivateKey
+	public ed25519.PublicKey
+}
+
+func NewTicketSigner(kid string, key ed25519.PrivateKey) *TicketSigner {
+	return &TicketSigner{
+		kid: kid,
+		private: key,
+		public: key.Public().(ed25519.PublicKey),
+	}
+}
+
+// LoadTicketSigner reads an Ed25519 private key from a PKCS8 pem file,
+// named by the file like the access token keys.
+func LoadTicketSigner(path string) (*TicketSigner, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, _, err := parsePEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("ticket key: %w", err)
+	}
+	key, ok := signer.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("ticket key: %w", ErrUnsupportedKey)
+	}
+
+	kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+	return NewTicketSigner(kid, key), nil
+}
+
+// Sign returns the signature of the message, base64url encoded.
+func (s *TicketSigner) Sign(message string) string {
+	return base64.RawURLEncoding.EncodeToString(ed25519.Sign(s.private, []byte(message)))
+}
+
+func (s *TicketSigner) Verify(message, signature string) error {
+	raw, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !ed25519.Verify(s.public, []byte(message), raw) {
+		return ErrInvalidTicketSignature
+	}
+	return nil
+}
+
+// SignClaims signs the claims as an EdDSA JWT named by the ticket key, so
+// whatever verifies ticket codes can verify it too.
+func (s *TicketSigner) SignClaims(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = s.kid
+	return token.SignedString(s.private)
+}
+
+// JWKS publishes the public key scanners verify ticket codes with.
+func (s *TicketSigner) JWKS() JWKS {
+	return JWKS{Keys: []JWK{{
+		Kty: "OKP",
+		Kid: s.kid,
+		Use: "sig",
+		Alg: "EdDSA",
+		Crv: "Ed25519",
+		X: base64.RawURLEncoding.EncodeToString(s.public),
+	}}}
+}
+
+// JWKSHandler serves the ticket key at /.well-known/ticket-keys.json.
+func (s *TicketSigner) JWKSHandler(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, s.JWKS())
+}
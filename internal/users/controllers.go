@@ -1,9 +1,20 @@
 package users
 
 import (
+	"avana/internal/apperror"
 	"avana/internal/config"
+	"avana/internal/mailer"
+	"avana/internal/repository"
+	"avana/internal/tokens"
 	"avana/internal/utils"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -11,32 +22,48 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-func CreateUser(c *gin.Context) {
+// Handler serves the user routes. Its dependencies are handed in by NewHandler.
+type Handler struct {
+	store Store
+	auth config.AuthConfig
+	keys *tokens.KeySet
+	mailer mailer.Mailer
+}
+
+func NewHandler(store Store, auth config.AuthConfig, keys *tokens.KeySet, mail mailer.Mailer) *Handler {
+	return &Handler{
+		store: store,
+		auth: auth,
+		keys: keys,
+		mailer: mail,
+	}
+}
+
+const (
+	otpLifetimeMinutes = 10
+	otpLockoutMinutes = 15
+	maxOtpAttempts = 5
+	resetTokenLifetimeMinutes = 15
+)
+
+func (h *Handler) CreateUser(c *gin.Context) {
 	var userSchema CreateUserSchema
 	// bind the schema
-	if c.Bind(&userSchema) != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": utils.ReadRequestError,
-		})
+	if err := c.ShouldBind(&userSchema); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
 		return
 	}
 
 	// check if the user exists
-	var userCheck User
-	if (config.DB.Where("email = ?", userSchema.Email).
-	First(&userCheck).RowsAffected > 0) {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": utils.ExistingDataError,
-		})
+	if _, err := h.store.Users().FindByEmail(userSchema.Email); err == nil {
+		apperror.Write(c, ErrEmailTaken)
 		return
 	}
 
 	// hash the password
 	hash, err := bcrypt.GenerateFromPassword([]byte(userSchema.Password),10)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": utils.HashingError,
-		})
+		apperror.Write(c, err)
 		return
 	}
 
@@ -46,15 +73,15 @@ func CreateUser(c *gin.Context) {
 		LastName: userSchema.LastName,
 		Email: userSchema.Email,
 		Password: string(hash),
+		// new accounts can only buy tickets until an admin promotes them
+		Role: RoleAttendee,
 
 	}
 
 	// save the model
-	err = config.DB.Create(&user).Error
+	err = h.store.Users().Create(&user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": utils.CreateRecordError,
-		})
+		apperror.Write(c, err)
 		return
 	}
 
@@ -65,182 +92,402 @@ func CreateUser(c *gin.Context) {
 
 }
 
-func Login(c *gin.Context) {
+// Login exchanges credentials for an access token and a refresh token.
+func (h *Handler) Login(c *gin.Context) {
 	// receive the request body
 	var loginSchema UserCredentials
-	if c.Bind(&loginSchema) != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": utils.ReadRequestError,
-		})
+	if err := c.ShouldBind(&loginSchema); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
 		return
 	}
 
 	// get the user details
-	var user User
-	if err := config.DB.Table("users").
-					Where("email= ?",loginSchema.Email).
-					First(&user).Error; err !=nil {
-						c.JSON(http.StatusInternalServerError, gin.H{
-							"message": utils.DatabaseCallError,
-						})
-						return
-					}
+	user, err := h.store.Users().FindByEmail(loginSchema.Email)
+	if err != nil {
+		// an unknown email looks the same as a wrong password
+		if errors.Is(err, repository.ErrNotFound) {
+			err = ErrInvalidCredentials
+		}
+		apperror.Write(c, err)
+		return
+	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password),[]byte(loginSchema.Password));
 			err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{
-					"message": utils.CredentialsError,
-				})
+				apperror.Write(c, ErrInvalidCredentials)
 				return
 				}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256,jwt.MapClaims{
-		"sub": user.Email,
-		"exp": time.Now().Add(time.Hour * 24 * 30).Unix(),
-	})
+	// open a session for this device
+	session := Session{
+		UserID: user.ID,
+		ExpiresAt: time.Now().Add(h.auth.RefreshTokenTTL()),
+	}
+	if err := h.store.Sessions().Create(&session); err != nil {
+		apperror.Write(c, err)
+		return
+	}
 
-	tokenString, err := token.SignedString([]byte("chgjfskiuyfgshdigjhv"))
+	pair, err := h.issueTokens(h.store, user, session)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": utils.TokenError,
-		})
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, pair)
+}
+
+// Refresh swaps a refresh token for a new access and refresh token pair.
+// Each refresh token works once, presenting a used one again revokes the
+// whole session since it has been leaked.
+func (h *Handler) Refresh(c *gin.Context) {
+	// bind the request data
+	var refreshSchema RefreshTokenSchema
+	if err := c.ShouldBind(&refreshSchema); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
+		return
+	}
+
+	tx, err := h.store.Begin()
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	// find the token and lock it against parallel refreshes
+	refreshToken, err := tx.Sessions().FindRefreshTokenForUpdate(hashToken(refreshSchema.RefreshToken))
+	if err != nil {
+		tx.Rollback()
+		apperror.Write(c, ErrInvalidToken)
+		return
+	}
+
+	session, err := tx.Sessions().FindByID(refreshToken.SessionID)
+	if err != nil {
+		tx.Rollback()
+		apperror.Write(c, ErrInvalidToken)
+		return
+	}
+
+	// a second use means the token was stolen, kill the session
+	if refreshToken.UsedAt != nil {
+		if err = tx.Sessions().Revoke(session.ID); err != nil {
+			tx.Rollback()
+			apperror.Write(c, err)
+			return
+		}
+		if err = tx.Commit(); err != nil {
+			apperror.Write(c, err)
+			return
+		}
+		apperror.Write(c, ErrInvalidToken)
+		return
+	}
+
+	if !session.IsActive() || time.Now().After(refreshToken.ExpiresAt) {
+		tx.Rollback()
+		apperror.Write(c, ErrInvalidToken)
+		return
+	}
+
+	user, err := tx.Users().FindByID(session.UserID)
+	if err != nil {
+		tx.Rollback()
+		apperror.Write(c, ErrInvalidToken)
+		return
+	}
+
+	// rotate the refresh token
+	now := time.Now()
+	refreshToken.UsedAt = &now
+	if err := tx.Sessions().SaveRefreshToken(&refreshToken); err != nil {
+		tx.Rollback()
+		apperror.Write(c, err)
+		return
+	}
+
+	pair, err := h.issueTokens(tx, user, session)
+	if err != nil {
+		tx.Rollback()
+		apperror.Write(c, err)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, pair)
+}
+
+func (h *Handler) Logout(c *gin.Context) {
+	// get the session of the current token
+	sessionId, exist := c.Get("sessionID")
+	if !exist {
+		apperror.Write(c, apperror.ErrUnauthenticated)
+		return
+	}
+
+	if err := h.store.Sessions().Revoke(sessionId.(uint)); err != nil {
+		apperror.Write(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"token":tokenString,
-		"type":"Bearer",
-		"expiresIn": 86400,
+		"message": utils.OperationSucess,
 	})
 }
 
+func (h *Handler) LogoutAll(c *gin.Context) {
+	// get the user of the current token
+	userId, exist := c.Get("userID")
+	if !exist {
+		apperror.Write(c, apperror.ErrUnauthenticated)
+		return
+	}
 
-func GetOtp(c *gin.Context) {
+	if err := h.store.Sessions().RevokeAllForUser(userId.(uint)); err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": utils.OperationSucess,
+	})
+}
+
+
+func (h *Handler) GetOtp(c *gin.Context) {
 	// get the request body
 	var getOtpSchema UserEmail
-	if c.Bind(&getOtpSchema) != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message":utils.ReadRequestError,
-		})
+	if err := c.ShouldBind(&getOtpSchema); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
 		return
 	}
 
+	// the answer is the same whether or not the email has an account, so
+	// the route cannot be used to find out who is signed up
+	sent := gin.H{
+		"message": utils.OperationSucess,
+	}
+
 	// get the user
-	var user User
-	if err := config.DB.Table("users").
-					Where("email= ?",getOtpSchema.Email).
-					First(&user).Error; err !=nil {
-						c.JSON(http.StatusInternalServerError, gin.H{
-							"message": utils.DatabaseCallError,
-						})
-						return
-					}
+	user, err := h.store.Users().FindByEmail(getOtpSchema.Email)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusOK, sent)
+		return
+	}
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
 	
+	// no new codes while the account is locked out
+	if time.Now().Before(user.OtpLockedUntil) {
+		c.JSON(http.StatusOK, sent)
+		return
+	}
+
 	// generate otp 
-	user.Otp = utils.GenerateOtp()
-	user.OtpExpires = time.Now().Add(time.Minute*10)
+	otp, err := utils.GenerateOtp()
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
 
-	//save the otp 
-	if err := config.DB.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": utils.UpdateRecordError,
-		})
+	// only the hash of the otp is stored
+	otpHash, err := bcrypt.GenerateFromPassword([]byte(otp),10)
+	if err != nil {
+		apperror.Write(c, err)
 		return
 	}
 
-	// send out the otp (to be changed to mailing)
-	c.JSON(http.StatusOK, gin.H{
-		"message": utils.OperationSucess,
-		"otp": user.Otp,
+	// failed guesses carry over to the new code, asking for another one
+	// does not buy more attempts before the lockout
+	expires := time.Now().Add(time.Minute*otpLifetimeMinutes)
+	if err := h.store.Users().IssueOtp(user.ID, string(otpHash), expires); err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	// mail the otp to the user
+	message, err := mailer.Render("otp", user.Email, "Your avana password reset code", mailer.OtpData{
+		FirstName: user.FirstName,
+		Otp: otp,
+		ExpiresInMinutes: otpLifetimeMinutes,
 	})
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	if err := h.mailer.Send(message); err != nil {
+		log.Printf("sending otp to %s: %v", user.Email, err)
+		apperror.Write(c, ErrMailFailed)
+		return
+	}
+
+	c.JSON(http.StatusOK, sent)
 
 }
 
-func VerifyOtp(c *gin.Context) {
+func (h *Handler) VerifyOtp(c *gin.Context) {
 	// bind the body 
 	var verifyOtpSchema OtpCredentials
-	if c.Bind(&verifyOtpSchema) != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message":utils.ReadRequestError,
-		})
+	if err := c.ShouldBind(&verifyOtpSchema); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
 		return
 	}
 
-	// fetch the user data
-	var user User
-	if err := config.DB.Table("users").
-					Where("email= ?",verifyOtpSchema.Email).
-					First(&user).Error; err !=nil {
-						c.JSON(http.StatusInternalServerError, gin.H{
-							"message": utils.DatabaseCallError,
-						})
-						return
-					}
+	// fetch the user data, an unknown email has no code to verify
+	user, err := h.store.Users().FindByEmail(verifyOtpSchema.Email)
+	if errors.Is(err, repository.ErrNotFound) {
+		apperror.Write(c, ErrOtpExpired)
+		return
+	}
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
 	
-	// verify and save the user
-	user.OtpVerified = true
-	if err := config.DB.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": utils.UpdateRecordError,
-		})
+	// stop guessing once the account is locked out
+	if time.Now().Before(user.OtpLockedUntil) {
+		apperror.Write(c, ErrOtpLocked)
+		return
+	}
+
+	// the code must exist and still be within its lifetime
+	if user.Otp == "" || time.Now().After(user.OtpExpires) {
+		apperror.Write(c, ErrOtpExpired)
+		return
+	}
+
+	// count the guess before checking it, so parallel guesses cannot go
+	// past maxOtpAttempts between reading and saving the counter
+	taken, err := h.store.Users().TakeOtpAttempt(user.ID, user.Otp, maxOtpAttempts)
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+	if !taken {
+		apperror.Write(c, h.otpUnavailable(user))
+		return
+	}
+
+	// compare against the stored hash, bcrypt compares in constant time
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Otp),[]byte(verifyOtpSchema.Otp));
+			err != nil {
+				// burn the code and lock the account for a while once the attempts are used up
+				if err := h.lockOtp(user); err != nil {
+					apperror.Write(c, err)
+					return
+				}
+				apperror.Write(c, ErrOtpInvalid)
+				return
+			}
+
+	// the raw reset token is only ever returned to the client
+	raw := make([]byte, 32)
+	if _, err = rand.Read(raw); err != nil {
+		apperror.Write(c, err)
+		return
+	}
+	resetToken := base64.RawURLEncoding.EncodeToString(raw)
+
+	// trade the code for the token, the code can only be used once
+	expires := time.Now().Add(time.Minute*resetTokenLifetimeMinutes)
+	consumed, err := h.store.Users().ConsumeOtp(user.ID, user.Otp, hashToken(resetToken), expires)
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+	if !consumed {
+		apperror.Write(c, ErrOtpExpired)
 		return
 	}
 	
-	// send success message
+	// send the token that changes the password
 	c.JSON(http.StatusOK, gin.H{
 		"message": utils.OperationSucess,
+		"resetToken": resetToken,
+		"expiresIn": int64(resetTokenLifetimeMinutes * 60),
 	})
 
 }
 
-func ChangePassword(c *gin.Context) {
-	// bind the request data
-	var changePasswordSchema UserCredentials
-	if c.Bind(&changePasswordSchema) != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message":utils.ReadRequestError,
-		})
-		return
+// otpUnavailable tells why a guess at the user's code was not counted: the
+// attempts are used up, or the code was replaced or burned meanwhile.
+func (h *Handler) otpUnavailable(user User) error {
+	current, err := h.store.Users().FindByID(user.ID)
+	if err != nil {
+		return err
+	}
+	if current.OtpAttempts < maxOtpAttempts {
+		return ErrOtpExpired
 	}
 
-	// get the user
-	var user User
-	if err := config.DB.Table("users").
-					Where("email= ?",changePasswordSchema.Email).
-					First(&user).Error; err !=nil {
-						c.JSON(http.StatusInternalServerError, gin.H{
-							"message": utils.DatabaseCallError,
-						})
-						return
-					}
-
-	// check if the user verification is valid
-	if !user.OtpVerified || time.Now().After(user.OtpExpires) {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": utils.ExpiresVerificationError,
-		})
+	// the guess that used the attempts up may have lost its code before locking
+	if err = h.lockOtp(user); err != nil {
+		return err
+	}
+	return ErrOtpLocked
+}
+
+func (h *Handler) lockOtp(user User) error {
+	lockedUntil := time.Now().Add(time.Minute*otpLockoutMinutes)
+	return h.store.Users().LockOtp(user.ID, maxOtpAttempts, lockedUntil)
+}
+
+func (h *Handler) ChangePassword(c *gin.Context) {
+	// bind the request data
+	var changePasswordSchema ChangePasswordSchema
+	if err := c.ShouldBind(&changePasswordSchema); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
 		return
 	}
 
 	// hash the password
 	hash, err := bcrypt.GenerateFromPassword([]byte(changePasswordSchema.Password),10)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": utils.HashingError,
-		})
+		apperror.Write(c, err)
+		return
+	}
+
+	tx, err := h.store.Begin()
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	// the token works once, a parallel request with it finds it gone
+	user, err := tx.Users().ConsumeResetToken(hashToken(changePasswordSchema.ResetToken), time.Now())
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, repository.ErrNotFound) {
+			err = ErrResetTokenInvalid
+		}
+		apperror.Write(c, err)
 		return
 	}
 
 	// change the new password and save it
 	user.Password = string(hash)
-	user.OtpVerified = false
-	user.OtpExpires = time.Now()
-	user.Otp = ""
+	if err := tx.Users().Save(&user); err != nil {
+		tx.Rollback()
+		apperror.Write(c, err)
+		return
+	}
 
-	if err := config.DB.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": utils.UpdateRecordError,
-		})
+	// a new password signs out every device
+	if err := tx.Sessions().RevokeAllForUser(user.ID); err != nil {
+		tx.Rollback()
+		apperror.Write(c, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		apperror.Write(c, err)
 		return
 	}
 
@@ -250,3 +497,299 @@ func ChangePassword(c *gin.Context) {
 	})
 }
 
+
+func (h *Handler) ListUsers(c *gin.Context) {
+	found, err := h.store.Users().List()
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	// never send password hashes or otp state back
+	summaries := make([]UserSummary, 0, len(found))
+	for _, user := range found {
+		summaries = append(summaries, UserSummary{
+			ID: user.ID,
+			Email: user.Email,
+			FirstName: user.FirstName,
+			LastName: user.LastName,
+			Role: user.Role,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"users": summaries,
+	})
+}
+
+func (h *Handler) UpdateUserRole(c *gin.Context) {
+	// bind the request data
+	var roleSchema UpdateRoleSchema
+	if err := c.ShouldBind(&roleSchema); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
+		return
+	}
+	if !IsValidRole(roleSchema.Role) {
+		apperror.Write(c, ErrInvalidRole)
+		return
+	}
+
+	// get the user id
+	userIdStr := c.Param("id")
+	userId, err := strconv.Atoi(userIdStr)
+	if err != nil {
+		apperror.Write(c, apperror.InvalidParam("id"))
+		return
+	}
+
+	user, err := h.store.Users().FindByID(uint(userId))
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	user.Role = roleSchema.Role
+	if err = h.store.Users().Save(&user); err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": utils.UpdateRecordSuccess,
+	})
+}
+
+// RequestRole asks the admins to promote the signed in user, such as to
+// organiser so they can create events.
+func (h *Handler) RequestRole(c *gin.Context) {
+	// get the user of the current token
+	userId, exist := c.Get("userID")
+	if !exist {
+		apperror.Write(c, apperror.ErrUnauthenticated)
+		return
+	}
+
+	// bind the request data
+	var roleSchema RoleRequestSchema
+	if err := c.ShouldBind(&roleSchema); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
+		return
+	}
+	if !IsRequestableRole(roleSchema.Role) {
+		apperror.Write(c, ErrRoleNotRequestable)
+		return
+	}
+
+	user, err := h.store.Users().FindByID(userId.(uint))
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+	if user.Role == roleSchema.Role || user.Role == RoleAdmin {
+		apperror.Write(c, ErrRoleHeld)
+		return
+	}
+
+	// one open request per user
+	pending, err := h.store.RoleRequests().CountPending(user.ID)
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+	if pending > 0 {
+		apperror.Write(c, ErrRoleRequestPending)
+		return
+	}
+
+	request := RoleRequest{
+		UserID: user.ID,
+		Role: roleSchema.Role,
+		Note: roleSchema.Note,
+		Status: RoleRequestPending,
+	}
+	err = h.store.RoleRequests().Create(&request)
+	if errors.Is(err, repository.ErrDuplicate) {
+		err = ErrRoleRequestPending
+	}
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": utils.CreateRecordSuccess,
+		"request": request,
+	})
+}
+
+func (h *Handler) ListRoleRequests(c *gin.Context) {
+	requests, err := h.store.RoleRequests().ListPending()
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"requests": requests,
+	})
+}
+
+// DecideRoleRequest approves or rejects a pending role request, approving
+// gives the user the role.
+func (h *Handler) DecideRoleRequest(c *gin.Context) {
+	// get the admin of the current token
+	adminId, exist := c.Get("userID")
+	if !exist {
+		apperror.Write(c, apperror.ErrUnauthenticated)
+		return
+	}
+
+	// bind the request data
+	var decideSchema DecideRoleRequestSchema
+	if err := c.ShouldBind(&decideSchema); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
+		return
+	}
+
+	// get the request id
+	requestIdStr := c.Param("id")
+	requestId, err := strconv.Atoi(requestIdStr)
+	if err != nil {
+		apperror.Write(c, apperror.InvalidParam("id"))
+		return
+	}
+
+	tx, err := h.store.Begin()
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	// lock the request so it is decided once
+	request, err := tx.RoleRequests().FindByIDForUpdate(uint(requestId))
+	if err != nil {
+		tx.Rollback()
+		apperror.Write(c, err)
+		return
+	}
+	if request.Status != RoleRequestPending {
+		tx.Rollback()
+		apperror.Write(c, ErrRoleRequestDecided)
+		return
+	}
+
+	request.Status = RoleRequestRejected
+	if decideSchema.Action == "approve" {
+		user, err := tx.Users().FindByID(request.UserID)
+		if err != nil {
+			tx.Rollback()
+			apperror.Write(c, err)
+			return
+		}
+		user.Role = request.Role
+		if err = tx.Users().Save(&user); err != nil {
+			tx.Rollback()
+			apperror.Write(c, err)
+			return
+		}
+		request.Status = RoleRequestApproved
+	}
+
+	deciderId := adminId.(uint)
+	now := time.Now()
+	request.DecidedByID = &deciderId
+	request.DecidedAt = &now
+	if err = tx.RoleRequests().Save(&request); err != nil {
+		tx.Rollback()
+		apperror.Write(c, err)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": utils.UpdateRecordSuccess,
+		"request": request,
+	})
+}
+
+func (h *Handler) DeleteUser(c *gin.Context) {
+	// get the user id
+	userIdStr := c.Param("id")
+	userId, err := strconv.Atoi(userIdStr)
+	if err != nil {
+		apperror.Write(c, apperror.InvalidParam("id"))
+		return
+	}
+
+	// sign the user out everywhere before removing them
+	if err = h.store.Sessions().RevokeAllForUser(uint(userId)); err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	if err = h.store.Users().Delete(uint(userId)); err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": utils.DeleteRecordSuccess,
+	})
+}
+
+
+// internal functions
+type tokenPair struct {
+	Token string			`json:"token"`
+	RefreshToken string		`json:"refreshToken"`
+	Type string				`json:"type"`
+	ExpiresIn int64			`json:"expiresIn"`
+}
+
+// issueTokens signs an access token bound to the session and stores a new
+// single-use refresh token for it.
+func (h *Handler) issueTokens(repos Repositories, user User, session Session) (tokenPair, error) {
+	accessTTL := h.auth.AccessTokenTTL()
+
+	tokenString, err := h.keys.Sign(jwt.MapClaims{
+		"sub": user.Email,
+		"sid": session.ID,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(accessTTL).Unix(),
+	})
+	if err != nil {
+		return tokenPair{}, err
+	}
+
+	// the raw refresh token is only ever returned to the client
+	raw := make([]byte, 32)
+	if _, err = rand.Read(raw); err != nil {
+		return tokenPair{}, err
+	}
+	refreshString := base64.RawURLEncoding.EncodeToString(raw)
+
+	refreshToken := RefreshToken{
+		SessionID: session.ID,
+		TokenHash: hashToken(refreshString),
+		ExpiresAt: session.ExpiresAt,
+	}
+	if err = repos.Sessions().CreateRefreshToken(&refreshToken); err != nil {
+		return tokenPair{}, err
+	}
+
+	return tokenPair{
+		Token: tokenString,
+		RefreshToken: refreshString,
+		Type: "Bearer",
+		ExpiresIn: int64(accessTTL.Seconds()),
+	}, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,22 @@
+package users
+
+import (
+	"avana/internal/apperror"
+	"avana/internal/utils"
+)
+
+var (
+	ErrInvalidCredentials = apperror.New(apperror.KindUnauthenticated, "invalid_credentials", utils.CredentialsError)
+	ErrEmailTaken = apperror.New(apperror.KindConflict, "email_taken", utils.ExistingDataError)
+	ErrInvalidToken = apperror.New(apperror.KindUnauthenticated, "invalid_token", utils.ValidateTokenError)
+	ErrInvalidRole = apperror.New(apperror.KindInvalid, "invalid_role", "Unknown role")
+	ErrRoleNotRequestable = apperror.New(apperror.KindInvalid, "role_not_requestable", "That role cannot be requested")
+	ErrRoleHeld = apperror.New(apperror.KindConflict, "role_held", "The user already has that role")
+	ErrRoleRequestPending = apperror.New(apperror.KindConflict, "role_request_pending", "A role request is already awaiting an admin")
+	ErrRoleRequestDecided = apperror.New(apperror.KindConflict, "role_request_decided", "The role request has already been decided")
+	ErrOtpInvalid = apperror.New(apperror.KindInvalid, "otp_invalid", utils.OtpInvalidError)
+	ErrOtpExpired = apperror.New(apperror.KindInvalid, "otp_expired", utils.OtpExpiredError)
+	ErrResetTokenInvalid = apperror.New(apperror.KindForbidden, "reset_token_invalid", "The reset token is invalid, used or expired, verify a new code")
+	ErrOtpLocked = apperror.New(apperror.KindTooManyRequests, "otp_locked", utils.OtpLockedError)
+	ErrMailFailed = apperror.New(apperror.KindUpstream, "mail_failed", utils.MailError)
+)
@@ -12,8 +12,53 @@ type User struct {
 	Password string			`gorm:"not null"`
 	FirstName string		`gorm:"not null"`
 	LastName string			`gorm:"not null"`
+	Role string				`gorm:"not null;default:attendee"`
 	Otp string
 	OtpExpires time.Time
-	OtpVerified bool 		`gorm:"default:false"`
+	OtpAttempts uint		`gorm:"not null;default:0"`
+	OtpLockedUntil time.Time
+	// ResetToken is the hash of the token a verified code hands out, it
+	// changes the password once
+	ResetToken string
+	ResetTokenExpires time.Time
 
-}
\ No newline at end of file
+}
+
+// Session is one signed in device. Access tokens carry its id so revoking
+// the session invalidates them before they expire.
+type Session struct {
+	gorm.Model
+	UserID uint				`gorm:"not null;index"`
+	ExpiresAt time.Time		`gorm:"not null"`
+	RevokedAt *time.Time
+}
+
+func (s Session) IsActive() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
+
+type RefreshToken struct {
+	gorm.Model
+	SessionID uint			`gorm:"not null;index"`
+	TokenHash string		`gorm:"unique;not null"`
+	ExpiresAt time.Time		`gorm:"not null"`
+	UsedAt *time.Time
+}
+
+const (
+	RoleRequestPending string = "pending"
+	RoleRequestApproved string = "approved"
+	RoleRequestRejected string = "rejected"
+)
+
+// RoleRequest is a user asking to be promoted to a role, such as organiser
+// to create events. An admin approves or rejects it.
+type RoleRequest struct {
+	gorm.Model
+	UserID uint				`gorm:"not null;index"`
+	Role string				`gorm:"not null"`
+	Note string
+	Status string			`gorm:"not null;default:pending;index"`
+	DecidedByID *uint
+	DecidedAt *time.Time
+}
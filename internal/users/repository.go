@@ -0,0 +1,276 @@
+package users
+
+import (
+	"avana/internal/repository"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type UserRepository interface {
+	Create(user *User) error
+	FindByID(id uint) (User, error)
+	FindByEmail(email string) (User, error)
+	FindByIDs(ids []uint) ([]User, error)
+	List() ([]User, error)
+	Save(user *User) error
+	Delete(id uint) error
+	// IssueOtp stores a new code for the user, leaving the failed attempts alone.
+	IssueOtp(userId uint, otpHash string, expires time.Time) error
+	// TakeOtpAttempt counts a guess at the given code in one conditional
+	// update. It reports false when the code was replaced or burned, or the
+	// user already has max attempts.
+	TakeOtpAttempt(userId uint, otpHash string, max uint) (bool, error)
+	// LockOtp burns the code and locks the user out until the given time,
+	// if the user's attempts have reached max.
+	LockOtp(userId uint, max uint, until time.Time) error
+	// ConsumeOtp trades the code for a reset token, once. It reports false
+	// when the code was replaced or burned in the meantime.
+	ConsumeOtp(userId uint, otpHash string, resetHash string, expires time.Time) (bool, error)
+	// ConsumeResetToken clears a reset token that has not expired by now and
+	// returns its user, or repository.ErrNotFound. A token works once.
+	ConsumeResetToken(resetHash string, now time.Time) (User, error)
+}
+
+type SessionRepository interface {
+	Create(session *Session) error
+	FindByID(id uint) (Session, error)
+	Revoke(id uint) error
+	RevokeAllForUser(userId uint) error
+	CreateRefreshToken(token *RefreshToken) error
+	FindRefreshTokenForUpdate(tokenHash string) (RefreshToken, error)
+	SaveRefreshToken(token *RefreshToken) error
+}
+
+type RoleRequestRepository interface {
+	Create(request *RoleRequest) error
+	FindByIDForUpdate(id uint) (RoleRequest, error)
+	CountPending(userId uint) (int64, error)
+	// ListPending returns the requests awaiting an admin, oldest first.
+	ListPending() ([]RoleRequest, error)
+	Save(request *RoleRequest) error
+}
+
+type Repositories interface {
+	Users() UserRepository
+	Sessions() SessionRepository
+	RoleRequests() RoleRequestRepository
+}
+
+// Store hands out the repositories and opens transactions across them.
+type Store interface {
+	Repositories
+	Begin() (Tx, error)
+}
+
+// Tx is a Store scoped to one transaction, it must end with Commit or Rollback.
+type Tx interface {
+	Repositories
+	Commit() error
+	Rollback()
+}
+
+type gormStore struct {
+	db *gorm.DB
+}
+
+func NewGormStore(db *gorm.DB) Store {
+	return &gormStore{db: db}
+}
+
+func (s *gormStore) Users() UserRepository {
+	return &gormUsers{db: s.db}
+}
+
+func (s *gormStore) Sessions() SessionRepository {
+	return &gormSessions{db: s.db}
+}
+
+func (s *gormStore) RoleRequests() RoleRequestRepository {
+	return &gormRoleRequests{db: s.db}
+}
+
+func (s *gormStore) Begin() (Tx, error) {
+	tx := s.db.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &gormTx{gormStore{db: tx}}, nil
+}
+
+type gormTx struct {
+	gormStore
+}
+
+func (t *gormTx) Commit() error {
+	return t.db.Commit().Error
+}
+
+func (t *gormTx) Rollback() {
+	t.db.Rollback()
+}
+
+type gormUsers struct {
+	db *gorm.DB
+}
+
+func (r *gormUsers) Create(user *User) error {
+	return repository.GormError(r.db.Create(user).Error)
+}
+
+func (r *gormUsers) FindByID(id uint) (User, error) {
+	var user User
+	err := r.db.First(&user, id).Error
+	return user, repository.GormError(err)
+}
+
+func (r *gormUsers) FindByEmail(email string) (User, error) {
+	var user User
+	err := r.db.Where("email = ?", email).First(&user).Error
+	return user, repository.GormError(err)
+}
+
+func (r *gormUsers) FindByIDs(ids []uint) ([]User, error) {
+	var users []User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	err := r.db.Where("id IN ?", ids).Find(&users).Error
+	return users, err
+}
+
+func (r *gormUsers) List() ([]User, error) {
+	var users []User
+	err := r.db.Order("created_at DESC").Find(&users).Error
+	return users, err
+}
+
+func (r *gormUsers) Save(user *User) error {
+	return repository.GormError(r.db.Save(user).Error)
+}
+
+func (r *gormUsers) Delete(id uint) error {
+	return r.db.Delete(&User{}, id).Error
+}
+
+func (r *gormUsers) IssueOtp(userId uint, otpHash string, expires time.Time) error {
+	return r.db.Model(&User{}).
+				Where("id = ?", userId).
+				Updates(map[string]interface{}{"otp": otpHash, "otp_expires": expires, "reset_token": ""}).Error
+}
+
+func (r *gormUsers) TakeOtpAttempt(userId uint, otpHash string, max uint) (bool, error) {
+	result := r.db.Model(&User{}).
+				Where("id = ? AND otp = ? AND otp_attempts < ?", userId, otpHash, max).
+				UpdateColumn("otp_attempts", gorm.Expr("otp_attempts + 1"))
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *gormUsers) LockOtp(userId uint, max uint, until time.Time) error {
+	return r.db.Model(&User{}).
+				Where("id = ? AND otp_attempts >= ?", userId, max).
+				Updates(map[string]interface{}{"otp": "", "otp_attempts": 0, "otp_locked_until": until}).Error
+}
+
+func (r *gormUsers) ConsumeOtp(userId uint, otpHash string, resetHash string, expires time.Time) (bool, error) {
+	result := r.db.Model(&User{}).
+				Where("id = ? AND otp = ?", userId, otpHash).
+				Updates(map[string]interface{}{"otp": "", "otp_attempts": 0, "reset_token": resetHash, "reset_token_expires": expires})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *gormUsers) ConsumeResetToken(resetHash string, now time.Time) (User, error) {
+	var user User
+	err := r.db.Where("reset_token = ? AND reset_token_expires > ?", resetHash, now).First(&user).Error
+	if err != nil {
+		return User{}, repository.GormError(err)
+	}
+
+	// only the request that clears the token gets to use it
+	result := r.db.Model(&User{}).
+				Where("id = ? AND reset_token = ?", user.ID, resetHash).
+				Update("reset_token", "")
+	if result.Error != nil {
+		return User{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return User{}, repository.ErrNotFound
+	}
+	user.ResetToken = ""
+	return user, nil
+}
+
+type gormSessions struct {
+	db *gorm.DB
+}
+
+func (r *gormSessions) Create(session *Session) error {
+	return r.db.Create(session).Error
+}
+
+func (r *gormSessions) FindByID(id uint) (Session, error) {
+	var session Session
+	err := r.db.First(&session, id).Error
+	return session, repository.GormError(err)
+}
+
+func (r *gormSessions) Revoke(id uint) error {
+	return r.db.Model(&Session{}).
+				Where("id = ? AND revoked_at IS NULL", id).
+				Update("revoked_at", time.Now()).Error
+}
+
+func (r *gormSessions) RevokeAllForUser(userId uint) error {
+	return r.db.Model(&Session{}).
+				Where("user_id = ? AND revoked_at IS NULL", userId).
+				Update("revoked_at", time.Now()).Error
+}
+
+func (r *gormSessions) CreateRefreshToken(token *RefreshToken) error {
+	return repository.GormError(r.db.Create(token).Error)
+}
+
+func (r *gormSessions) FindRefreshTokenForUpdate(tokenHash string) (RefreshToken, error) {
+	var token RefreshToken
+	err := r.db.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).
+				Where("token_hash = ?", tokenHash).
+				First(&token).Error
+	return token, repository.GormError(err)
+}
+
+func (r *gormSessions) SaveRefreshToken(token *RefreshToken) error {
+	return r.db.Save(token).Error
+}
+
+type gormRoleRequests struct {
+	db *gorm.DB
+}
+
+func (r *gormRoleRequests) Create(request *RoleRequest) error {
+	return repository.GormError(r.db.Create(request).Error)
+}
+
+func (r *gormRoleRequests) FindByIDForUpdate(id uint) (RoleRequest, error) {
+	var request RoleRequest
+	err := r.db.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).First(&request, id).Error
+	return request, repository.GormError(err)
+}
+
+func (r *gormRoleRequests) CountPending(userId uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&RoleRequest{}).
+				Where("user_id = ? AND status = ?", userId, RoleRequestPending).
+				Count(&count).Error
+	return count, err
+}
+
+func (r *gormRoleRequests) ListPending() ([]RoleRequest, error) {
+	var requests []RoleRequest
+	err := r.db.Where("status = ?", RoleRequestPending).Order("created_at").Find(&requests).Error
+	return requests, err
+}
+
+func (r *gormRoleRequests) Save(request *RoleRequest) error {
+	return repository.GormError(r.db.Save(request).Error)
+}
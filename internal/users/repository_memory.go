@@ -0,0 +1,287 @@
+package users
+
+import (
+	"avana/internal/repository"
+	"sync"
+	"time"
+)
+
+// memoryStore keeps everything in process, for tests. A transaction holds
+// the store's lock and works on a copy that replaces the tables on commit.
+type memoryStore struct {
+	mu *sync.Mutex
+	users *repository.Table[User]
+	sessions *repository.Table[Session]
+	refreshTokens *repository.Table[RefreshToken]
+	roleRequests *repository.Table[RoleRequest]
+}
+
+func NewMemoryStore() Store {
+	mu := &sync.Mutex{}
+	return &memoryStore{
+		mu: mu,
+		users: repository.NewTable[User](mu),
+		sessions: repository.NewTable[Session](mu),
+		refreshTokens: repository.NewTable[RefreshToken](mu),
+		roleRequests: repository.NewTable[RoleRequest](mu),
+	}
+}
+
+func (s *memoryStore) Users() UserRepository {
+	return &memoryUsers{users: s.users}
+}
+
+func (s *memoryStore) Sessions() SessionRepository {
+	return &memorySessions{sessions: s.sessions, refreshTokens: s.refreshTokens}
+}
+
+func (s *memoryStore) RoleRequests() RoleRequestRepository {
+	return &memoryRoleRequests{requests: s.roleRequests}
+}
+
+func (s *memoryStore) Begin() (Tx, error) {
+	s.mu.Lock()
+	mu := &sync.Mutex{}
+	return &memoryTx{
+		memoryStore: memoryStore{
+			mu: mu,
+			users: s.users.Clone(mu),
+			sessions: s.sessions.Clone(mu),
+			refreshTokens: s.refreshTokens.Clone(mu),
+			roleRequests: s.roleRequests.Clone(mu),
+		},
+		parent: s,
+	}, nil
+}
+
+type memoryTx struct {
+	memoryStore
+	parent *memoryStore
+	done bool
+}
+
+func (t *memoryTx) Commit() error {
+	if t.done {
+		return nil
+	}
+	t.done = true
+	t.parent.users.Replace(t.users)
+	t.parent.sessions.Replace(t.sessions)
+	t.parent.refreshTokens.Replace(t.refreshTokens)
+	t.parent.roleRequests.Replace(t.roleRequests)
+	t.parent.mu.Unlock()
+	return nil
+}
+
+func (t *memoryTx) Rollback() {
+	if t.done {
+		return
+	}
+	t.done = true
+	t.parent.mu.Unlock()
+}
+
+type memoryUsers struct {
+	users *repository.Table[User]
+}
+
+func (r *memoryUsers) Create(user *User) error {
+	if _, err := r.FindByEmail(user.Email); err == nil {
+		return repository.ErrDuplicate
+	}
+	r.users.Insert(user)
+	return nil
+}
+
+func (r *memoryUsers) FindByID(id uint) (User, error) {
+	return r.users.Get(id)
+}
+
+func (r *memoryUsers) FindByEmail(email string) (User, error) {
+	return r.users.First(func(user User) bool {
+		return user.Email == email
+	})
+}
+
+func (r *memoryUsers) FindByIDs(ids []uint) ([]User, error) {
+	wanted := map[uint]bool{}
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	return r.users.Where(func(user User) bool {
+		return wanted[user.ID]
+	}), nil
+}
+
+func (r *memoryUsers) List() ([]User, error) {
+	users := r.users.Where(nil)
+	// newest first, like the gorm repository
+	for i, j := 0, len(users)-1; i < j; i, j = i+1, j-1 {
+		users[i], users[j] = users[j], users[i]
+	}
+	return users, nil
+}
+
+func (r *memoryUsers) Save(user *User) error {
+	return r.users.Save(user)
+}
+
+func (r *memoryUsers) Delete(id uint) error {
+	r.users.Delete(id)
+	return nil
+}
+
+func (r *memoryUsers) IssueOtp(userId uint, otpHash string, expires time.Time) error {
+	return r.users.Modify(userId, func(user *User) error {
+		user.Otp = otpHash
+		user.OtpExpires = expires
+		user.ResetToken = ""
+		return nil
+	})
+}
+
+func (r *memoryUsers) TakeOtpAttempt(userId uint, otpHash string, max uint) (bool, error) {
+	taken := false
+	err := r.users.Modify(userId, func(user *User) error {
+		if user.Otp == otpHash && user.OtpAttempts < max {
+			user.OtpAttempts++
+			taken = true
+		}
+		return nil
+	})
+	return taken, err
+}
+
+func (r *memoryUsers) LockOtp(userId uint, max uint, until time.Time) error {
+	return r.users.Modify(userId, func(user *User) error {
+		if user.OtpAttempts >= max {
+			user.Otp = ""
+			user.OtpAttempts = 0
+			user.OtpLockedUntil = until
+		}
+		return nil
+	})
+}
+
+func (r *memoryUsers) ConsumeOtp(userId uint, otpHash string, resetHash string, expires time.Time) (bool, error) {
+	consumed := false
+	err := r.users.Modify(userId, func(user *User) error {
+		if user.Otp == otpHash {
+			user.Otp = ""
+			user.OtpAttempts = 0
+			user.ResetToken = resetHash
+			user.ResetTokenExpires = expires
+			consumed = true
+		}
+		return nil
+	})
+	return consumed, err
+}
+
+func (r *memoryUsers) ConsumeResetToken(resetHash string, now time.Time) (User, error) {
+	user, err := r.users.First(func(user User) bool {
+		return user.ResetToken == resetHash && user.ResetTokenExpires.After(now)
+	})
+	if err != nil {
+		return User{}, err
+	}
+
+	// only the call that clears the token gets to use it
+	err = r.users.Modify(user.ID, func(stored *User) error {
+		if stored.ResetToken != resetHash {
+			return repository.ErrNotFound
+		}
+		stored.ResetToken = ""
+		return nil
+	})
+	if err != nil {
+		return User{}, err
+	}
+	user.ResetToken = ""
+	return user, nil
+}
+
+type memorySessions struct {
+	sessions *repository.Table[Session]
+	refreshTokens *repository.Table[RefreshToken]
+}
+
+func (r *memorySessions) Create(session *Session) error {
+	r.sessions.Insert(session)
+	return nil
+}
+
+func (r *memorySessions) FindByID(id uint) (Session, error) {
+	return r.sessions.Get(id)
+}
+
+func (r *memorySessions) Revoke(id uint) error {
+	err := r.sessions.Modify(id, revoke)
+	if err == repository.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+func (r *memorySessions) RevokeAllForUser(userId uint) error {
+	for _, session := range r.sessions.Where(func(session Session) bool {
+		return session.UserID == userId
+	}) {
+		if err := r.sessions.Modify(session.ID, revoke); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *memorySessions) CreateRefreshToken(token *RefreshToken) error {
+	r.refreshTokens.Insert(token)
+	return nil
+}
+
+func (r *memorySessions) FindRefreshTokenForUpdate(tokenHash string) (RefreshToken, error) {
+	return r.refreshTokens.First(func(token RefreshToken) bool {
+		return token.TokenHash == tokenHash
+	})
+}
+
+func (r *memorySessions) SaveRefreshToken(token *RefreshToken) error {
+	return r.refreshTokens.Save(token)
+}
+
+type memoryRoleRequests struct {
+	requests *repository.Table[RoleRequest]
+}
+
+func (r *memoryRoleRequests) Create(request *RoleRequest) error {
+	r.requests.Insert(request)
+	return nil
+}
+
+func (r *memoryRoleRequests) FindByIDForUpdate(id uint) (RoleRequest, error) {
+	return r.requests.Get(id)
+}
+
+func (r *memoryRoleRequests) CountPending(userId uint) (int64, error) {
+	return int64(r.requests.Count(func(request RoleRequest) bool {
+		return request.UserID == userId && request.Status == RoleRequestPending
+	})), nil
+}
+
+func (r *memoryRoleRequests) ListPending() ([]RoleRequest, error) {
+	return r.requests.Where(func(request RoleRequest) bool {
+		return request.Status == RoleRequestPending
+	}), nil
+}
+
+func (r *memoryRoleRequests) Save(request *RoleRequest) error {
+	return r.requests.Save(request)
+}
+
+func revoke(session *Session) error {
+	if session.RevokedAt == nil {
+		now := time.Now()
+		session.RevokedAt = &now
+	}
+	return nil
+}
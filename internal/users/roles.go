@@ -0,0 +1,74 @@
+package users
+
+const (
+	RoleAdmin string = "admin"
+	RoleOrganiser string = "organiser"
+	RoleStaff string = "staff"
+	// RoleAttendee is what every account starts with, it can only buy tickets
+	RoleAttendee string = "attendee"
+)
+
+const (
+	PermCreateEvent string = "event:create"
+	PermManageAnyEvent string = "event:manage_any"
+	PermBuyTicket string = "ticket:buy"
+	PermViewAnyAttendees string = "attendee:view_any"
+	PermCheckInAny string = "attendee:checkin_any"
+	PermManageUsers string = "user:manage"
+	PermModerateReviews string = "review:moderate"
+)
+
+// rolePermissions lists what each role may do beyond acting on its own records.
+var rolePermissions = map[string][]string{
+	RoleAdmin: {
+		PermCreateEvent,
+		PermManageAnyEvent,
+		PermBuyTicket,
+		PermViewAnyAttendees,
+		PermCheckInAny,
+		PermManageUsers,
+		PermModerateReviews,
+	},
+	RoleOrganiser: {
+		PermCreateEvent,
+		PermBuyTicket,
+	},
+	RoleStaff: {
+		PermBuyTicket,
+		PermViewAnyAttendees,
+		PermCheckInAny,
+	},
+	RoleAttendee: {
+		PermBuyTicket,
+	},
+}
+
+// requestableRoles are the roles a user may ask an admin to promote them to.
+var requestableRoles = []string{RoleOrganiser}
+
+func IsRequestableRole(role string) bool {
+	for _, requestable := range requestableRoles {
+		if requestable == role {
+			return true
+		}
+	}
+	return false
+}
+
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+func HasPermission(role, permission string) bool {
+	for _, granted := range rolePermissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func (u User) Can(permission string) bool {
+	return HasPermission(u.Role, permission)
+}
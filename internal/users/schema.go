@@ -1,22 +1,55 @@
 package users
 
 type CreateUserSchema struct {
-	Email string
-	Password string
-	FirstName string
-	LastName string
+	Email string			`binding:"required,email,max=254"`
+	Password string			`binding:"required,password"`
+	FirstName string		`binding:"required,max=100"`
+	LastName string			`binding:"required,max=100"`
 }
 
 type UserCredentials struct {
-	Email string
-	Password string
+	Email string			`binding:"required,email"`
+	Password string			`binding:"required"`
+}
+
+// ChangePasswordSchema sets a new password with the reset token a verified
+// code handed out, so unlike UserCredentials the password has to meet the
+// strength rule.
+type ChangePasswordSchema struct {
+	ResetToken string		`binding:"required"`
+	Password string			`binding:"required,password"`
 }
 
 type OtpCredentials struct {
-	Email string
-	Otp uint
+	Email string			`binding:"required,email"`
+	Otp string				`binding:"required,len=5,numeric"`
 }
 
 type UserEmail struct {
+	Email string			`binding:"required,email"`
+}
+
+type RefreshTokenSchema struct {
+	RefreshToken string		`binding:"required"`
+}
+
+type UpdateRoleSchema struct {
+	Role string				`binding:"required"`
+}
+
+type RoleRequestSchema struct {
+	Role string				`binding:"required,max=50"`
+	Note string				`binding:"max=1000"`
+}
+
+type DecideRoleRequestSchema struct {
+	Action string			`binding:"required,oneof=approve reject"`
+}
+
+type UserSummary struct {
+	ID uint
 	Email string
-}
\ No newline at end of file
+	FirstName string
+	LastName string
+	Role string
+}
@@ -5,14 +5,18 @@ import (
 	"time"
 )
 
-func ValidateDate(dateString string) (time.Time, error) {
-	// the format is in YY-MM-DD HH:MM:SS
-	// the hour is 24 hour format
-	format := "2006-01-02 15:04:05"
+// DateFormat is how every date in a request is written, YYYY-MM-DD HH:MM:SS
+// with a 24 hour clock.
+const DateFormat = "2006-01-02 15:04:05"
 
-  
+// ParseDate reads a date in DateFormat without checking when it falls.
+func ParseDate(dateString string) (time.Time, error) {
+	return time.Parse(DateFormat, dateString)
+}
+
+func ValidateDate(dateString string) (time.Time, error) {
 	// Parse the DoB string into a time.Time instance
-	t, err := time.Parse(format, dateString)
+	t, err := ParseDate(dateString)
   
 	// Check for parsing errors
 	if err != nil {
@@ -25,4 +29,3 @@ func ValidateDate(dateString string) (time.Time, error) {
 	// Return the parsed time.Time instance
 	return t, nil
 }
-
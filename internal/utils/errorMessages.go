@@ -18,4 +18,16 @@ const (
 	TicketExpiredError string = "Ticket has expired"
 	TicketAmountError string = "Ticket amount exceeds limit"
 	EventHeldError string = "This event has not held"
+	TicketSoldOutError string = "Ticket is sold out"
+	PaymentError string = "Unable to process the payment"
+	PaymentFailedError string = "The payment was not successful"
+	WebhookSignatureError string = "Invalid webhook signature"
+	ReservationExpiredError string = "The reservation has expired"
+	MailError string = "Unable to send the email"
+	MemberRoleError string = "Invalid team role"
+	OwnerRemovalError string = "The event owner cannot be removed"
+	OtpGenerationError string = "Unable to generate the code"
+	OtpInvalidError string = "The code is incorrect"
+	OtpExpiredError string = "The code has expired, request a new one"
+	OtpLockedError string = "Too many attempts, try again later"
 )
\ No newline at end of file
@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+// codeAlphabet leaves out characters that are easy to misread at the door,
+// 0 and O, 1 and I.
+const codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// GenerateCode draws a random code of the given length from codeAlphabet.
+func GenerateCode(length int) (string, error) {
+    code := make([]byte, length)
+    for i := range code {
+        n, err := rand.Int(rand.Reader, big.NewInt(int64(len(codeAlphabet))))
+        if err != nil {
+            return "", err
+        }
+        code[i] = codeAlphabet[n.Int64()]
+    }
+
+    return string(code), nil
+}
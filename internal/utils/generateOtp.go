@@ -1,20 +1,19 @@
 package utils
 
 import (
+	"crypto/rand"
 	"fmt"
-	"math/rand"
-	"time"
+	"math/big"
 )
 
 
-func GenerateOtp() string {
-    // Seed the random number generator with the current time
-    source := rand.NewSource(time.Now().UnixNano())
-    rng := rand.New(source)
+func GenerateOtp() (string, error) {
+    // Draw a uniform 5-digit number from the OS random source
+    otp, err := rand.Int(rand.Reader, big.NewInt(100000))
+    if err != nil {
+        return "", err
+    }
+    otpString := fmt.Sprintf("%05d", otp.Int64())
 
-    // Generate a random 5-digit number
-    otp := rng.Intn(100000)
-    otpString := fmt.Sprintf("%05d", otp)
-
-    return otpString
+    return otpString, nil
 }
@@ -0,0 +1,136 @@
+// Package validation registers avana's custom binding rules with gin's
+// validator and turns failed rules into messages a client can show.
+package validation
+
+import (
+	"avana/internal/utils"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	// PasswordMinLength is the shortest password accepted at signup or reset.
+	PasswordMinLength = 8
+	// PasswordMaxLength is where bcrypt stops reading the input.
+	PasswordMaxLength = 72
+)
+
+// Register adds the custom rules used by the request schemas:
+//
+//	password           at least PasswordMinLength characters with a letter and a digit
+//	date               a future date in utils.DateFormat
+//	datebefore=Field   a date earlier than the named sibling date field
+func Register() error {
+	validate, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return fmt.Errorf("validation: unexpected binding engine %T", binding.Validator.Engine())
+	}
+
+	if err := validate.RegisterValidation("password", validatePassword); err != nil {
+		return err
+	}
+	if err := validate.RegisterValidation("date", validateDate); err != nil {
+		return err
+	}
+	return validate.RegisterValidation("datebefore", validateDateBefore)
+}
+
+func validatePassword(fl validator.FieldLevel) bool {
+	password := fl.Field().String()
+	if len(password) < PasswordMinLength || len(password) > PasswordMaxLength {
+		return false
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	return hasLetter && hasDigit
+}
+
+func validateDate(fl validator.FieldLevel) bool {
+	_, err := utils.ValidateDate(fl.Field().String())
+	return err == nil
+}
+
+// validateDateBefore only compares two well formed dates, a missing or
+// malformed one is left for its own date rule to report.
+func validateDateBefore(fl validator.FieldLevel) bool {
+	other, _, _, ok := fl.GetStructFieldOKAdvanced2(fl.Parent(), fl.Param())
+	if !ok {
+		return true
+	}
+	if other.Kind() != fl.Field().Kind() {
+		return true
+	}
+
+	date, err := utils.ParseDate(fl.Field().String())
+	if err != nil {
+		return true
+	}
+	otherDate, err := utils.ParseDate(other.String())
+	if err != nil {
+		return true
+	}
+	return date.Before(otherDate)
+}
+
+// Field names the failed field relative to the request body, so nested
+// errors read as Tickets[0].Price rather than CreateEventSchema.Tickets[0].Price.
+func Field(fieldErr validator.FieldError) string {
+	namespace := fieldErr.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return fieldErr.Field()
+}
+
+// Message describes a failed rule in plain words.
+func Message(fieldErr validator.FieldError) string {
+	param := fieldErr.Param()
+	switch fieldErr.Tag() {
+	case "required", "required_if":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "password":
+		return fmt.Sprintf("must be %d to %d characters and contain a letter and a digit", PasswordMinLength, PasswordMaxLength)
+	case "date":
+		return "must be a future date formatted as YYYY-MM-DD HH:MM:SS"
+	case "datetime":
+		return "must be a date formatted as YYYY-MM-DD HH:MM:SS"
+	case "datebefore":
+		return "must be before " + param
+	case "min", "gte":
+		if fieldErr.Kind() == reflect.String {
+			return "must be at least " + param + " characters"
+		}
+		return "must be at least " + param
+	case "max", "lte":
+		if fieldErr.Kind() == reflect.String {
+			return "must be at most " + param + " characters"
+		}
+		return "must be at most " + param
+	case "gt":
+		return "must be greater than " + param
+	case "len":
+		return "must be exactly " + param + " characters"
+	case "numeric":
+		return "must contain only digits"
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(param, " ", ", ")
+	case "excluded_with":
+		return "cannot be set together with " + param
+	}
+	return "failed the " + fieldErr.Tag() + " rule"
+}
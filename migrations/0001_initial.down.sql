@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS payments;
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS attendees;
+DROP TABLE IF EXISTS reservations;
+DROP TABLE IF EXISTS tickets;
+DROP TABLE IF EXISTS event_members;
+DROP TABLE IF EXISTS events;
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS users;
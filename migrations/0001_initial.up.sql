@@ -0,0 +1,151 @@
+-- Baseline schema. Tables created earlier by AutoMigrate already match it,
+-- so every statement is idempotent and existing databases can adopt it.
+
+CREATE TABLE IF NOT EXISTS users (
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ,
+	deleted_at TIMESTAMPTZ,
+	email TEXT NOT NULL UNIQUE,
+	password TEXT NOT NULL,
+	first_name TEXT NOT NULL,
+	last_name TEXT NOT NULL,
+	role TEXT NOT NULL DEFAULT 'organiser',
+	otp TEXT,
+	otp_expires TIMESTAMPTZ,
+	otp_verified BOOLEAN DEFAULT false,
+	otp_attempts BIGINT NOT NULL DEFAULT 0,
+	otp_locked_until TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS sessions (
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ,
+	deleted_at TIMESTAMPTZ,
+	user_id BIGINT NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL,
+	revoked_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_sessions_deleted_at ON sessions (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ,
+	deleted_at TIMESTAMPTZ,
+	session_id BIGINT NOT NULL,
+	token_hash TEXT NOT NULL UNIQUE,
+	expires_at TIMESTAMPTZ NOT NULL,
+	used_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_deleted_at ON refresh_tokens (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_session_id ON refresh_tokens (session_id);
+
+CREATE TABLE IF NOT EXISTS events (
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ,
+	deleted_at TIMESTAMPTZ,
+	name TEXT NOT NULL UNIQUE,
+	organiser TEXT NOT NULL,
+	location TEXT NOT NULL,
+	is_paid_event BOOLEAN NOT NULL,
+	description TEXT NOT NULL,
+	is_limited BOOLEAN NOT NULL,
+	max_unit_reservation BIGINT NOT NULL DEFAULT 1,
+	event_date TIMESTAMPTZ NOT NULL,
+	registration_expiration_date TIMESTAMPTZ NOT NULL,
+	user_id BIGINT
+);
+CREATE INDEX IF NOT EXISTS idx_events_deleted_at ON events (deleted_at);
+
+CREATE TABLE IF NOT EXISTS event_members (
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ,
+	deleted_at TIMESTAMPTZ,
+	event_id BIGINT NOT NULL,
+	user_id BIGINT,
+	email TEXT NOT NULL,
+	role TEXT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'invited',
+	invited_by_id BIGINT
+);
+CREATE INDEX IF NOT EXISTS idx_event_members_deleted_at ON event_members (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_event_members_user_id ON event_members (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_event_member_email ON event_members (event_id, email);
+
+CREATE TABLE IF NOT EXISTS tickets (
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ,
+	deleted_at TIMESTAMPTZ,
+	name TEXT NOT NULL,
+	price DECIMAL NOT NULL,
+	total_available BIGINT NOT NULL,
+	sold BIGINT NOT NULL DEFAULT 0,
+	single_limit BIGINT NOT NULL,
+	expiry_time TIMESTAMPTZ NOT NULL,
+	event_id BIGINT
+);
+CREATE INDEX IF NOT EXISTS idx_tickets_deleted_at ON tickets (deleted_at);
+
+CREATE TABLE IF NOT EXISTS reservations (
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ,
+	deleted_at TIMESTAMPTZ,
+	user_id BIGINT,
+	ticket_id BIGINT,
+	units BIGINT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'held',
+	expires_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_reservations_deleted_at ON reservations (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_reservations_status ON reservations (status);
+CREATE INDEX IF NOT EXISTS idx_reservations_expires_at ON reservations (expires_at);
+
+CREATE TABLE IF NOT EXISTS attendees (
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ,
+	deleted_at TIMESTAMPTZ,
+	user_id BIGINT,
+	units BIGINT NOT NULL,
+	attended BOOLEAN NOT NULL DEFAULT false,
+	review TEXT,
+	rating BIGINT,
+	ticket_id BIGINT
+);
+CREATE INDEX IF NOT EXISTS idx_attendees_deleted_at ON attendees (deleted_at);
+
+CREATE TABLE IF NOT EXISTS orders (
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ,
+	deleted_at TIMESTAMPTZ,
+	user_id BIGINT,
+	ticket_id BIGINT,
+	units BIGINT NOT NULL,
+	amount DECIMAL NOT NULL,
+	currency TEXT NOT NULL DEFAULT 'NGN',
+	status TEXT NOT NULL DEFAULT 'pending'
+);
+CREATE INDEX IF NOT EXISTS idx_orders_deleted_at ON orders (deleted_at);
+
+CREATE TABLE IF NOT EXISTS payments (
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ,
+	deleted_at TIMESTAMPTZ,
+	order_id BIGINT,
+	provider TEXT NOT NULL,
+	reference TEXT NOT NULL UNIQUE,
+	amount DECIMAL NOT NULL,
+	status TEXT NOT NULL,
+	refunded_amount DECIMAL NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_payments_deleted_at ON payments (deleted_at);
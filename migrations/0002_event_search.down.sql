@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_tickets_event_id;
+DROP INDEX IF EXISTS idx_events_event_date;
+DROP INDEX IF EXISTS idx_events_search;
@@ -0,0 +1,5 @@
+-- full text search over event names and descriptions, plus the columns
+-- GET /event/all filters and sorts on
+CREATE INDEX IF NOT EXISTS idx_events_search ON events USING GIN (to_tsvector('english', name || ' ' || description));
+CREATE INDEX IF NOT EXISTS idx_events_event_date ON events (event_date);
+CREATE INDEX IF NOT EXISTS idx_tickets_event_id ON tickets (event_id);
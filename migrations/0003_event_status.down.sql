@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_events_status;
+ALTER TABLE events DROP COLUMN IF EXISTS status_reason;
+ALTER TABLE events DROP COLUMN IF EXISTS status;
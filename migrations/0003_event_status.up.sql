@@ -0,0 +1,6 @@
+-- events that already exist were public, so they start out published while
+-- new events start as drafts
+ALTER TABLE events ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'published';
+ALTER TABLE events ALTER COLUMN status SET DEFAULT 'draft';
+ALTER TABLE events ADD COLUMN IF NOT EXISTS status_reason TEXT;
+CREATE INDEX IF NOT EXISTS idx_events_status ON events (status);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_attendees_status;
+ALTER TABLE attendees DROP COLUMN IF EXISTS status;
+ALTER TABLE events DROP COLUMN IF EXISTS status_changed_at;
@@ -0,0 +1,4 @@
+-- cancelling an event keeps it, and its attendees, on record
+ALTER TABLE events ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMPTZ;
+ALTER TABLE attendees ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active';
+CREATE INDEX IF NOT EXISTS idx_attendees_status ON attendees (status);
@@ -0,0 +1,3 @@
+ALTER TABLE attendees ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMPTZ;
+-- reviews written before this column existed date from their last update
+UPDATE attendees SET reviewed_at = updated_at WHERE rating > 0 AND reviewed_at IS NULL;
@@ -0,0 +1,20 @@
+ALTER TABLE attendees ADD COLUMN IF NOT EXISTS review TEXT;
+ALTER TABLE attendees ADD COLUMN IF NOT EXISTS rating BIGINT;
+ALTER TABLE attendees ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMPTZ;
+
+-- moderation, flags and replies have nowhere to go on attendees and are lost.
+-- a review goes back onto one attendee row per user and event, the first
+UPDATE attendees SET review = reviews.body, rating = reviews.rating, reviewed_at = reviews.created_at
+FROM reviews
+JOIN (
+	SELECT tickets.event_id, attendees.user_id, MIN(attendees.id) AS attendee_id
+	FROM attendees
+	JOIN tickets ON tickets.id = attendees.ticket_id
+	WHERE attendees.deleted_at IS NULL
+	GROUP BY tickets.event_id, attendees.user_id
+) AS firsts ON firsts.event_id = reviews.event_id AND firsts.user_id = reviews.user_id
+WHERE attendees.id = firsts.attendee_id
+	AND reviews.deleted_at IS NULL;
+
+DROP TABLE IF EXISTS review_flags;
+DROP TABLE IF EXISTS reviews;
@@ -0,0 +1,53 @@
+-- reviews move off attendees into their own table so they can be moderated,
+-- flagged and answered
+CREATE TABLE IF NOT EXISTS reviews (
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ,
+	deleted_at TIMESTAMPTZ,
+	event_id BIGINT NOT NULL,
+	user_id BIGINT NOT NULL,
+	rating BIGINT NOT NULL,
+	body TEXT,
+	verified_attendee BOOLEAN NOT NULL DEFAULT false,
+	status TEXT NOT NULL DEFAULT 'published',
+	moderation_note TEXT,
+	moderated_by_id BIGINT,
+	moderated_at TIMESTAMPTZ,
+	reply TEXT,
+	replied_by_id BIGINT,
+	replied_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_reviews_deleted_at ON reviews (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_reviews_status ON reviews (status);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_review_event_user ON reviews (event_id, user_id);
+
+CREATE TABLE IF NOT EXISTS review_flags (
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ,
+	deleted_at TIMESTAMPTZ,
+	review_id BIGINT NOT NULL,
+	user_id BIGINT NOT NULL,
+	reason TEXT NOT NULL,
+	note TEXT,
+	resolved BOOLEAN NOT NULL DEFAULT false
+);
+CREATE INDEX IF NOT EXISTS idx_review_flags_deleted_at ON review_flags (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_review_flags_resolved ON review_flags (resolved);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_review_flag_user ON review_flags (review_id, user_id);
+
+-- a user's review sits on one of their attendee rows for the event
+INSERT INTO reviews (created_at, updated_at, event_id, user_id, rating, body, verified_attendee)
+SELECT DISTINCT ON (tickets.event_id, attendees.user_id)
+	attendees.reviewed_at, attendees.updated_at, tickets.event_id, attendees.user_id,
+	attendees.rating, attendees.review, attendees.attended
+FROM attendees
+JOIN tickets ON tickets.id = attendees.ticket_id
+WHERE attendees.reviewed_at IS NOT NULL AND attendees.deleted_at IS NULL
+ORDER BY tickets.event_id, attendees.user_id, attendees.reviewed_at
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE attendees DROP COLUMN IF EXISTS reviewed_at;
+ALTER TABLE attendees DROP COLUMN IF EXISTS rating;
+ALTER TABLE attendees DROP COLUMN IF EXISTS review;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS check_ins;
+DROP INDEX IF EXISTS idx_attendees_code;
+ALTER TABLE attendees DROP COLUMN IF EXISTS checked_in;
+ALTER TABLE attendees DROP COLUMN IF EXISTS code;
@@ -0,0 +1,23 @@
+ALTER TABLE attendees ADD COLUMN IF NOT EXISTS code TEXT;
+ALTER TABLE attendees ADD COLUMN IF NOT EXISTS checked_in BIGINT NOT NULL DEFAULT 0;
+-- purchases made before codes existed get one, attendees already marked as
+-- attended count as fully checked in
+UPDATE attendees SET code = upper(substr(md5(random()::text || id::text), 1, 10)) WHERE code IS NULL;
+UPDATE attendees SET checked_in = units WHERE attended;
+ALTER TABLE attendees ALTER COLUMN code SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_attendees_code ON attendees (code);
+
+CREATE TABLE IF NOT EXISTS check_ins (
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ,
+	deleted_at TIMESTAMPTZ,
+	attendee_id BIGINT NOT NULL,
+	event_id BIGINT NOT NULL,
+	units BIGINT NOT NULL,
+	checked_in_by_id BIGINT NOT NULL,
+	checked_in_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_check_ins_deleted_at ON check_ins (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_check_ins_attendee_id ON check_ins (attendee_id);
+CREATE INDEX IF NOT EXISTS idx_check_ins_event_id ON check_ins (event_id);
@@ -0,0 +1,2 @@
+ALTER TABLE check_ins DROP COLUMN IF EXISTS ticket_code_id;
+DROP TABLE IF EXISTS ticket_codes;
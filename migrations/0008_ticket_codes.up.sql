@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS ticket_codes (
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ,
+	deleted_at TIMESTAMPTZ,
+	attendee_id BIGINT NOT NULL,
+	event_id BIGINT NOT NULL,
+	unit BIGINT NOT NULL,
+	serial TEXT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'valid',
+	revoked_reason TEXT,
+	revoked_at TIMESTAMPTZ,
+	checked_in_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_ticket_codes_deleted_at ON ticket_codes (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_ticket_codes_attendee_id ON ticket_codes (attendee_id);
+CREATE INDEX IF NOT EXISTS idx_ticket_codes_event_id ON ticket_codes (event_id);
+CREATE INDEX IF NOT EXISTS idx_ticket_codes_status ON ticket_codes (status);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ticket_codes_serial ON ticket_codes (serial);
+
+ALTER TABLE check_ins ADD COLUMN IF NOT EXISTS ticket_code_id BIGINT;
+
+-- every unit bought before codes existed gets one, units already checked in
+-- count as used from the attendee's last update
+INSERT INTO ticket_codes (created_at, updated_at, attendee_id, event_id, unit, serial, status, checked_in_at)
+SELECT now(), now(), attendees.id, tickets.event_id, units.unit,
+	upper(substr(md5(random()::text || attendees.id::text || units.unit::text), 1, 12)),
+	CASE WHEN attendees.status = 'active' THEN 'valid' ELSE 'revoked' END,
+	CASE WHEN units.unit <= attendees.checked_in THEN attendees.updated_at END
+FROM attendees
+JOIN tickets ON tickets.id = attendees.ticket_id
+CROSS JOIN LATERAL generate_series(1, attendees.units) AS units(unit)
+WHERE attendees.deleted_at IS NULL
+	AND NOT EXISTS (SELECT 1 FROM ticket_codes WHERE ticket_codes.attendee_id = attendees.id);
+UPDATE ticket_codes SET revoked_reason = 'cancelled', revoked_at = now()
+WHERE status = 'revoked' AND revoked_at IS NULL;
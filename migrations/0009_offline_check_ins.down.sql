@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_check_ins_ticket_code_id;
+ALTER TABLE check_ins DROP COLUMN IF EXISTS device_id;
@@ -0,0 +1,3 @@
+ALTER TABLE check_ins ADD COLUMN IF NOT EXISTS device_id TEXT;
+-- syncing a scan looks up the check-in that used the unit's code
+CREATE INDEX IF NOT EXISTS idx_check_ins_ticket_code_id ON check_ins (ticket_code_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS role_requests;
+ALTER TABLE users ALTER COLUMN role SET DEFAULT 'organiser';
@@ -0,0 +1,20 @@
+-- new accounts start as attendees, existing users keep the role they have
+ALTER TABLE users ALTER COLUMN role SET DEFAULT 'attendee';
+
+CREATE TABLE IF NOT EXISTS role_requests (
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ,
+	deleted_at TIMESTAMPTZ,
+	user_id BIGINT NOT NULL,
+	role TEXT NOT NULL,
+	note TEXT,
+	status TEXT NOT NULL DEFAULT 'pending',
+	decided_by_id BIGINT,
+	decided_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_role_requests_deleted_at ON role_requests (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_role_requests_user_id ON role_requests (user_id);
+CREATE INDEX IF NOT EXISTS idx_role_requests_status ON role_requests (status);
+-- one open request per user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_role_requests_pending_user ON role_requests (user_id) WHERE status = 'pending';
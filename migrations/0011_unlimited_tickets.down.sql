@@ -0,0 +1,2 @@
+UPDATE tickets SET total_available = 0 WHERE total_available IS NULL;
+ALTER TABLE tickets ALTER COLUMN total_available SET NOT NULL;
@@ -0,0 +1,3 @@
+-- a ticket without a limit has no total instead of a total of 0
+ALTER TABLE tickets ALTER COLUMN total_available DROP NOT NULL;
+UPDATE tickets SET total_available = NULL WHERE total_available = 0;
@@ -0,0 +1,4 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS otp_verified BOOLEAN DEFAULT false;
+DROP INDEX IF EXISTS idx_users_reset_token;
+ALTER TABLE users DROP COLUMN IF EXISTS reset_token_expires;
+ALTER TABLE users DROP COLUMN IF EXISTS reset_token;
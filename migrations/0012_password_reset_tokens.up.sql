@@ -0,0 +1,5 @@
+-- a verified code hands out a single-use reset token instead of flagging the account
+ALTER TABLE users ADD COLUMN IF NOT EXISTS reset_token TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS reset_token_expires TIMESTAMPTZ;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_reset_token ON users (reset_token) WHERE reset_token <> '';
+ALTER TABLE users DROP COLUMN IF EXISTS otp_verified;